@@ -0,0 +1,318 @@
+// Package ast holds the syntax tree of scripts and the static types of
+// its annotations.
+package ast
+
+type NodeType string
+
+const (
+	// STATEMENTS
+	NodeTypeProgram             = "Program"
+	NodeTypeVariableDeclaration = "VariableDeclaration"
+	NodeTypeFunctionDeclaration = "FunctionDeclaration"
+	NodeTypeIfExpression        = "IfExpressions"
+	NodeTypeForExpression       = "ForExpression"
+	NodeTypeForEachExpression   = "ForEachExpression"
+	NodeTypeSwitchExpression    = "SwitchExpression"
+	NodeTypeImportDeclaration   = "ImportDeclaration"
+	NodeTypeExportDeclaration   = "ExportDeclaration"
+	NodeTypeMatchExpression     = "MatchExpression"
+	NodeTypeObjectPattern       = "ObjectPattern"
+	NodeTypeArrayPattern        = "ArrayPattern"
+
+	// EXPRESSIONS
+	NodeTypeBinaryExpession     = "BinaryExpession"
+	NodeTypeUnaryExpression     = "UnaryExpression"
+	NodeTypeAssigmentExpression = "AssignmentExpr"
+	NodeTypeMemberExpression    = "MemberExpression"
+	NodeTypeCallExpression      = "CallExpression"
+	NodeTypeConditionExpression = "ConditionDeclaration"
+	NodeTypeBreakExpression     = "BreakExpression"
+	NodeTypeContinueExpression  = "ContinueExpression"
+
+	// Literals
+	NodeTypeProperty       = "Property"
+	NodeTypeObjectLiteral  = "ObjectLiteral"
+	NodeTypeArrayLiteral   = "ArrayLiteral"
+	NodeTypeNumericLiteral = "NumericLiteral"
+	NodeTypeIntegerLiteral = "IntegerLiteral"
+	NodeTypeStringLIteral  = "StringLiteral"
+	NodeTypeIdentifier     = "Identifier"
+
+	// Types
+	NodeTypeTypeAnnotation = "TypeAnnotation"
+)
+
+type Stmter interface {
+	Kind() NodeType
+	Pos() int
+	End() int
+}
+
+// Stmt is the kind and the source span of a node, the characters from pos
+// to end, end excluded.
+type Stmt struct {
+	kind NodeType
+	pos  int
+	end  int
+}
+
+// NewStmt makes the base of a node made outside of the parser, like the
+// literals the optimizer folds expressions to.
+func NewStmt(kind NodeType, pos int, end int) *Stmt {
+	return &Stmt{kind: kind, pos: pos, end: end}
+}
+
+func (s *Stmt) Kind() NodeType {
+	return s.kind
+}
+
+func (s *Stmt) Pos() int {
+	return s.pos
+}
+
+func (s *Stmt) End() int {
+	return s.end
+}
+
+type Program struct {
+	*Stmt
+	Body []Stmter
+}
+
+type VariableDeclaration struct {
+	*Stmt
+	Constant       bool
+	Identifier     string
+	Pattern        Stmter
+	Value          Stmter
+	TypeAnnotation *TypeAnnotation
+	Slot           *Slot
+}
+
+// FunctionDeclaration has an entry in parameterTypes for every parameter,
+// nil when the parameter is not annotated.
+type FunctionDeclaration struct {
+	*Stmt
+	Parameters     []Stmter
+	ParameterTypes []*TypeAnnotation
+	ReturnType     *TypeAnnotation
+	Name           string
+	Body           []Stmter
+	Slot           *Slot
+	// Locals is the number of slots of the call scope
+	Locals int
+}
+
+// TypeAnnotation is the optional `: number` following a declared name, a
+// parameter or a parameter list, checked before the script runs.
+type TypeAnnotation struct {
+	*Stmt
+	Name StaticType
+}
+
+// DestructuringPattern is `{ x, y: { z }, w = 1 }` or `[first, second = 2]`,
+// the kind tells which one.
+type DestructuringPattern struct {
+	*Stmt
+	Elements []*PatternElement
+}
+
+// PatternElement binds target, an *Identifier or a nested pattern, to the
+// property key of an object pattern or the position of an array pattern.
+type PatternElement struct {
+	Key          string
+	Target       Stmter
+	DefaultValue Stmter
+}
+
+// ImportDeclaration is `import { a, b as c } from "./lib.gl";`, or
+// `import "std/strings";` binding the exports to the namespace strings.
+type ImportDeclaration struct {
+	*Stmt
+	Names     []ImportName
+	Namespace string
+	Path      string
+}
+
+type ImportName struct {
+	Name  string
+	Alias string
+}
+
+type ExportDeclaration struct {
+	*Stmt
+	Declaration Stmter
+}
+
+type IfExpression struct {
+	*Stmt
+	Condition      Stmter
+	Body           []Stmter
+	ElseExpression Stmter
+}
+
+type ForExpression struct {
+	*Stmt
+	Declaration           Stmter
+	Condition             Stmter
+	IncrementalExpression Stmter
+	AfterCondition        Stmter
+	Body                  []Stmter
+}
+
+// ForEachExpression is `for (let item of collection)`, or with keys set
+// `for (let key in collection)`.
+type ForEachExpression struct {
+	*Stmt
+	Identifier string
+	Constant   bool
+	Keys       bool
+	Iterable   Stmter
+	Body       []Stmter
+	Slot       *Slot
+	// Locals is the number of slots of the scope of an iteration
+	Locals int
+}
+
+type SwitchExpression struct {
+	*Stmt
+	Value Stmter
+	Body  []SwitchCaseExpression
+}
+
+type SwitchCaseExpression struct {
+	Condition *CaseCondition
+	Pos       int
+	Body      []Stmter
+}
+
+// CaseCondition is shared by switch cases and match arms, it matches when
+// wildcard is set, or any of the values equals the subject, or with binding
+// the subject is bound to the name, or the subject has the shape of the
+// pattern. The guard must hold in all cases.
+type CaseCondition struct {
+	Values   []Stmter
+	Binding  string
+	Pattern  Stmter
+	Guard    Stmter
+	Wildcard bool
+	// BindingSlot and locals place the binding and the names of the pattern
+	// in the scope of the body
+	BindingSlot *Slot
+	Locals      int
+}
+
+type MatchExpression struct {
+	*Stmt
+	Value Stmter
+	Arms  []*MatchArm
+}
+
+type MatchArm struct {
+	Condition *CaseCondition
+	Pos       int
+	Body      Stmter
+}
+
+type BreakExpression struct {
+	*Stmt
+}
+
+type ContinueExpression struct {
+	*Stmt
+}
+
+type Expr struct {
+	Stmt
+}
+
+type AssignmentExpr struct {
+	*Stmt
+	Assigne Stmter
+	Value   Stmter
+}
+
+type BinaryExpession struct {
+	*Stmt
+	Left     Stmter
+	Right    Stmter
+	Operator string
+}
+
+type UnaryExpression struct {
+	*Stmt
+	Operand  Stmter
+	Operator string
+}
+
+type Identifier struct {
+	*Stmt
+	Symbol string
+	Slot   *Slot
+}
+
+// Slot is where the resolver placed a local variable, at index in the
+// scope depth levels above the one evaluating the node. Nodes without a
+// slot look the name up in the variables maps, like the names of the
+// module root and everything typed at the prompt.
+type Slot struct {
+	Depth int
+	Index int
+}
+
+type NumericLiteral struct {
+	*Stmt
+	Value float64
+}
+
+type IntegerLiteral struct {
+	*Stmt
+	Value int64
+}
+
+type StringLiteral struct {
+	*Stmt
+	Value string
+}
+
+type Property struct {
+	*Stmt
+	Key         string
+	ComputedKey Stmter
+	Value       Stmter
+	// Slot is the variable of a shorthand property
+	Slot *Slot
+}
+
+type ObjectLiteral struct {
+	*Stmt
+	Properties []*Property
+}
+
+type ArrayLiteral struct {
+	*Stmt
+	Elements []Stmter
+}
+
+type CallExpression struct {
+	*Stmt
+	Args   []*Stmter
+	Caller Stmter
+	// Tail is set for calls giving the value of the function they are in,
+	// the callee runs in place of the function instead of on top of it
+	Tail bool
+}
+
+type MemberExpression struct {
+	*Stmt
+	Object   Stmter
+	Propert  Stmter
+	Computed bool
+}
+
+type ConditionDeclaration struct {
+	*Stmt
+	Left     Stmter
+	Right    Stmter
+	Operator string
+}
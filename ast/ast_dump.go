@@ -0,0 +1,112 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"aolbrich/lexer/diag"
+)
+
+// astDumper prints a syntax tree one node per line, nodes show their kind
+// and source span as line:column-line:column, the end column is the one
+// following the node. Children are indented under their parent and
+// labelled with the field holding them.
+type astDumper struct {
+	out    io.Writer
+	source string
+}
+
+// DumpAST prints the tree of node, source is the source it was parsed from.
+func DumpAST(out io.Writer, node Stmter, source string) {
+	d := &astDumper{out: out, source: source}
+	d.dump(reflect.ValueOf(node), "", 0)
+}
+
+// dump walks the fields with reflection, values are only read so the fields
+// do not need to be exported. Fields are labelled lowercase whether they are
+// exported or not.
+func (d *astDumper) dump(v reflect.Value, label string, depth int) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	header := []string{v.Type().Name()}
+	type child struct {
+		label string
+		value reflect.Value
+	}
+	var children []child
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+		name := fieldLabel(field.Name)
+
+		if field.Anonymous && field.Type == reflect.TypeOf(&Stmt{}) {
+			if !value.IsNil() {
+				stmt := value.Elem()
+				header[0] = stmt.FieldByName("kind").String()
+				header = append(header, d.span(int(stmt.FieldByName("pos").Int()), int(stmt.FieldByName("end").Int())))
+			}
+			continue
+		}
+
+		switch value.Kind() {
+		case reflect.String:
+			if value.String() != "" {
+				header = append(header, fmt.Sprintf("%s=%q", name, value.String()))
+			}
+		case reflect.Bool:
+			if value.Bool() {
+				header = append(header, name)
+			}
+		case reflect.Int:
+			if name == "pos" {
+				line, column, _ := diag.Locate(d.source, int(value.Int()))
+				header = append(header, fmt.Sprintf("%d:%d", line, column))
+			} else {
+				header = append(header, fmt.Sprintf("%s=%d", name, value.Int()))
+			}
+		case reflect.Int64:
+			header = append(header, fmt.Sprintf("%s=%d", name, value.Int()))
+		case reflect.Float64:
+			header = append(header, fmt.Sprintf("%s=%v", name, value.Float()))
+		case reflect.Slice:
+			for j := 0; j < value.Len(); j++ {
+				children = append(children, child{label: fmt.Sprintf("%s[%d]", name, j), value: value.Index(j)})
+			}
+		default:
+			children = append(children, child{label: name, value: value})
+		}
+	}
+
+	prefix := strings.Repeat("  ", depth)
+	if label != "" {
+		prefix += label + ": "
+	}
+	fmt.Fprintln(d.out, prefix+strings.Join(header, " "))
+
+	for _, c := range children {
+		d.dump(c.value, c.label, depth+1)
+	}
+}
+
+func (d *astDumper) span(pos int, end int) string {
+	line, column, _ := diag.Locate(d.source, pos)
+	endLine, endColumn, _ := diag.Locate(d.source, end)
+
+	return fmt.Sprintf("%d:%d-%d:%d", line, column, endLine, endColumn)
+}
+
+func fieldLabel(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -0,0 +1,57 @@
+package ast
+
+// StaticType is a type the checker knows before the script runs, TypeAny is
+// assumed for everything it cannot tell, like unannotated variables.
+type StaticType string
+
+const (
+	TypeAny StaticType = "any"
+	// TypeNumber is an integer or a float
+	TypeNumber   StaticType = "number"
+	TypeInt      StaticType = "int"
+	TypeFloat    StaticType = "float"
+	TypeString   StaticType = "string"
+	TypeBool     StaticType = "bool"
+	TypeNull     StaticType = "null"
+	TypeArray    StaticType = "array"
+	TypeObject   StaticType = "object"
+	TypeFunction StaticType = "fn"
+	TypeRange    StaticType = "range"
+)
+
+func StaticTypeNames() []string {
+	return []string{
+		string(TypeAny), string(TypeNumber), string(TypeInt), string(TypeFloat), string(TypeString), string(TypeBool),
+		string(TypeNull), string(TypeArray), string(TypeObject), string(TypeFunction), string(TypeRange),
+	}
+}
+
+func (t StaticType) Known() bool {
+	for _, name := range StaticTypeNames() {
+		if string(t) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t StaticType) Numeric() bool {
+	return t == TypeNumber || t == TypeInt || t == TypeFloat
+}
+
+// Accepts tells whether a value of type value can be stored where t is
+// declared. A number may hold an integer or a float so it is accepted by
+// both, while an integer is not a float since it is not converted.
+func (t StaticType) Accepts(value StaticType) bool {
+	switch {
+	case t == TypeAny || value == TypeAny || t == value:
+		return true
+	case t == TypeNumber:
+		return value == TypeInt || value == TypeFloat
+	case value == TypeNumber:
+		return t == TypeInt || t == TypeFloat
+	}
+
+	return false
+}
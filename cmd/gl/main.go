@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/engine"
+	"aolbrich/lexer/lexer"
+	"aolbrich/lexer/parser"
+	"aolbrich/lexer/runtime"
+)
+
+var noColor = flag.Bool("no-color", false, "print errors without ANSI colors")
+var printAST = flag.Bool("dump-ast", false, "print the syntax tree with the source span of every node instead of running the script")
+var optimize = flag.Bool("optimize", false, "fold constant expressions, drop branches that never run and inline constants before running")
+var dumpOptimized = flag.Bool("dump-optimized", false, "print the syntax tree once checked and optimized instead of running the script")
+var allocStats = flag.Bool("alloc-stats", false, "print the heap allocations made while running to stderr")
+var maxCallDepth = flag.Int("max-call-depth", runtime.DefaultMaxCallDepth, "user function calls that may be in progress at once, deeper recursion fails with a stack overflow")
+var timeout = flag.Duration("timeout", 0, "stop the script once it ran this long, like 500ms or 2s, 0 for no limit")
+var maxSteps = flag.Int64("max-steps", 0, "stop the script past this many steps, nodes evaluated or instructions run, 0 for no limit")
+var maxAlloc = flag.Int64("max-alloc", 0, "stop the script once its strings, arrays and objects took about this many bytes, 0 for no limit")
+var sandbox = flag.Bool("sandbox", false, "run the script without any capability but the ones granted with the --allow flags, which turn the sandbox on as well")
+var allowFlags = capabilityFlags()
+var engineName = flag.String("engine", string(runtime.EngineTree), "engine running the scripts, "+string(runtime.EngineTree)+" walks the syntax tree, "+string(runtime.EngineVM)+" compiles to bytecode for the virtual machine")
+var searchPath = flag.String("path", "", "module search path, directories separated by "+string(os.PathListSeparator)+", searched before "+runtime.SearchPathEnv)
+
+// runContext bounds the scripts run by the command, set from --timeout.
+var runContext = context.Background()
+
+func main() {
+	flag.Parse()
+
+	if runtime.Engine(*engineName) != runtime.EngineTree && runtime.Engine(*engineName) != runtime.EngineVM {
+		fmt.Printf("Unknown engine %s, expected %s or %s\n", *engineName, runtime.EngineTree, runtime.EngineVM)
+		os.Exit(2)
+	}
+
+	if *maxCallDepth < 1 {
+		fmt.Printf("Invalid max call depth %d, expected at least 1\n", *maxCallDepth)
+		os.Exit(2)
+	}
+
+	if *timeout < 0 || *maxSteps < 0 || *maxAlloc < 0 {
+		fmt.Println("Invalid limit, timeout, max steps and max alloc cannot be negative")
+		os.Exit(2)
+	}
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		runContext, cancel = context.WithTimeout(runContext, *timeout)
+		defer cancel()
+	}
+
+	permissions, permErr := scriptPermissions()
+	if permErr != nil {
+		fmt.Printf("Invalid permission, %s\n", permErr)
+		os.Exit(2)
+	}
+
+	e := newScriptEngine(permissions)
+	mode := 2
+
+	if flag.NArg() > 0 {
+		if flag.Arg(0) == "prompt" {
+			mode = 1
+		}
+		if flag.Arg(0) == "compile" {
+			mode = 5
+		}
+	}
+
+	if *allocStats {
+		defer printAllocStats(readAllocStats())
+	}
+
+	switch mode {
+	case 1:
+		propmt(e)
+	case 2:
+		executeScript(e)
+	case 3:
+		testing(e)
+	case 4:
+		testTokenizer()
+	case 5:
+		compileScripts(e, flag.Args()[1:])
+	default:
+		propmt(e)
+	}
+}
+
+func testTokenizer() {
+	t := lexer.NewTokenizer()
+	tokens, err := t.Tokenize("x >= 10")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(tokens)
+}
+
+func testing(e *engine.Engine) {
+
+	s, _ := runtime.ReadFile("./examples/switch.gl")
+
+	_, err := e.Eval(runContext, s)
+	if err != nil {
+		displayError(e, err, s)
+		return
+	}
+}
+
+func propmt(e *engine.Engine) {
+	for {
+		s := readFromConsole()
+		if s == "\n" {
+			break
+		}
+
+		_, err := e.Eval(runContext, s)
+		if err != nil {
+			displayError(e, err, s)
+			continue
+		}
+	}
+}
+
+func executeScript(e *engine.Engine) {
+	if flag.NArg() < 1 {
+		fmt.Println()
+		fmt.Println("Please provide the file name to run.")
+		return
+	}
+
+	if *printAST || *dumpOptimized {
+		dumpScript(e, flag.Arg(0))
+		return
+	}
+
+	_, err := e.RunFile(runContext, flag.Arg(0))
+	if err != nil {
+		displayError(e, err, "")
+	}
+}
+
+// dumpScript prints the syntax tree of a script, or of the source of a
+// compiled program, checked and optimized for --dump-optimized.
+func dumpScript(e *engine.Engine, name string) {
+	file, err := filepath.Abs(name)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	compiledFile := ""
+	if strings.HasSuffix(file, runtime.CompiledExt) {
+		compiledFile, file = file, runtime.SourcePath(file)
+	}
+
+	s, err := runtime.ReadFile(file)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if compiledFile != "" {
+		compiled, err := runtime.ReadCompiled(compiledFile)
+		if err == nil && !compiled.CompiledFrom(s) {
+			err = runtime.ErrStaleBytecode
+		}
+		if err != nil {
+			warning := diag.NewCustomError(fmt.Sprintf("Ignoring %s, %s, running the source", runtime.DisplayFileName(compiledFile), err))
+			e.ReportWarning(os.Stderr, warning, !*noColor)
+		}
+	}
+
+	if *printAST {
+		parsed, pErr := parser.NewParser().ProduceAST(s)
+		if pErr != nil {
+			displayError(e, pErr.InFile(file), s)
+			return
+		}
+
+		ast.DumpAST(os.Stdout, parsed, s)
+		return
+	}
+
+	parsed, err := e.Check(file, s)
+	if err != nil {
+		displayError(e, err, s)
+		return
+	}
+
+	ast.DumpAST(os.Stdout, parsed, s)
+}
+
+// compileScripts checks and compiles every script to bytecode written next
+// to it, the VM runs the compiled file instead of parsing the script.
+func compileScripts(e *engine.Engine, files []string) {
+	if len(files) == 0 {
+		fmt.Println("Please provide the files to compile.")
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, name := range files {
+		if err := e.Compile(name); err != nil {
+			displayError(e, err, "")
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func readAllocStats() goruntime.MemStats {
+	var stats goruntime.MemStats
+	goruntime.ReadMemStats(&stats)
+
+	return stats
+}
+
+// printAllocStats prints the allocations made since before, the garbage
+// collector does not change the counts.
+func printAllocStats(before goruntime.MemStats) {
+	after := readAllocStats()
+	fmt.Fprintf(os.Stderr, "allocations: %d, %d bytes\n", after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc)
+}
+
+func readFromConsole() string {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Println("Aty programming language")
+	fmt.Println("-------------------------")
+
+	fmt.Print("-> ")
+	text, _ := reader.ReadString('\n')
+
+	return text
+}
+
+// capabilityFlag is an --allow flag granting a capability to the script,
+// the read and write ones take the roots of the files they reach.
+type capabilityFlag struct {
+	capability runtime.Capability
+	granted    bool
+	paths      []string
+}
+
+func capabilityFlags() []*capabilityFlag {
+	var flags []*capabilityFlag
+	for _, c := range runtime.Capabilities {
+		f := &capabilityFlag{capability: c}
+		usage := "grant the " + string(c) + " capability to the script"
+		if c == runtime.CapRead || c == runtime.CapWrite {
+			usage += ", to the files under the directories given separated by " + string(os.PathListSeparator) + " or to any file without"
+		}
+		flag.Var(f, "allow-"+string(c), usage)
+		flags = append(flags, f)
+	}
+
+	return flags
+}
+
+func (f *capabilityFlag) String() string {
+	return strings.Join(f.paths, string(os.PathListSeparator))
+}
+
+func (f *capabilityFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.granted = true
+		return nil
+	case "false":
+		f.granted = false
+		f.paths = nil
+		return nil
+	}
+
+	if f.capability != runtime.CapRead && f.capability != runtime.CapWrite {
+		return fmt.Errorf("the %s capability does not take paths", f.capability)
+	}
+
+	f.granted = true
+	f.paths = append(f.paths, filepath.SplitList(value)...)
+
+	return nil
+}
+
+func (f *capabilityFlag) IsBoolFlag() bool {
+	return true
+}
+
+// scriptPermissions grants every capability to scripts, unless sandboxed
+// with --sandbox or an --allow flag, granting the capabilities allowed.
+func scriptPermissions() (*runtime.Permissions, error) {
+	sandboxed := *sandbox
+	for _, f := range allowFlags {
+		sandboxed = sandboxed || f.granted
+	}
+	if !sandboxed {
+		return runtime.AllowAll(), nil
+	}
+
+	permissions := runtime.DenyAll()
+	for _, f := range allowFlags {
+		if !f.granted {
+			continue
+		}
+		if err := permissions.Allow(f.capability, f.paths...); err != nil {
+			return nil, err
+		}
+	}
+
+	return permissions, nil
+}
+
+// newScriptEngine creates an engine with the module search path of the
+// --path flag followed by the GLPATH environment variable, the engine of
+// the --engine flag, the call depth of --max-call-depth, the limits and the
+// optimizer when asked for, warnings are printed to stderr.
+func newScriptEngine(permissions *runtime.Permissions) *engine.Engine {
+	var e *engine.Engine
+	e = engine.New(engine.Options{
+		Engine:       runtime.Engine(*engineName),
+		Optimize:     *optimize || *dumpOptimized,
+		MaxCallDepth: *maxCallDepth,
+		MaxSteps:     *maxSteps,
+		MaxAlloc:     *maxAlloc,
+		Permissions:  permissions,
+		SearchPath:   runtime.SplitSearchPath(*searchPath),
+		Warn: func(warning error) {
+			e.ReportWarning(os.Stderr, warning, !*noColor)
+		},
+	})
+
+	return e
+}
+
+// displayError prints err to stdout, src is the source of the errors not
+// raised in a file.
+func displayError(e *engine.Engine, err error, src string) {
+	e.Report(os.Stdout, err, src, !*noColor)
+}
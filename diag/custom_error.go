@@ -0,0 +1,162 @@
+// Package diag holds the errors raised while parsing, checking and
+// running scripts, with the source spans they point to.
+package diag
+
+import "fmt"
+
+type CustomError struct {
+	Message string
+	kind    ErrorKind
+	Trace   []TraceEntry
+	Stack   []StackFrame
+	// Errors are reported one by one before the message, set when a
+	// parse collected more than one error
+	Errors []*CustomError
+	// cause is the Go error a host function failed with
+	cause error
+}
+
+// TraceEntry is a source span, file is set once the error leaves the
+// module the span belongs to, empty means the script being run.
+type TraceEntry struct {
+	Pos  int
+	End  int
+	File string
+}
+
+// StackFrame is a user function the error went through and where it was called.
+type StackFrame struct {
+	Function string
+	Call     TraceEntry
+}
+
+// ErrorKind tells apart the errors a host running scripts may want to
+// handle from the errors of the script itself.
+type ErrorKind int
+
+const (
+	ErrorScript ErrorKind = iota
+	// ErrorCanceled is a run whose context was canceled
+	ErrorCanceled
+	// ErrorDeadline is a run still going when its deadline passed
+	ErrorDeadline
+	// ErrorStepLimit is a run going past its step budget
+	ErrorStepLimit
+	// ErrorAllocationLimit is a run going past its allocation budget
+	ErrorAllocationLimit
+	// ErrorStackOverflow is a run going past the maximum call depth
+	ErrorStackOverflow
+	// ErrorPermission is a native called without the capability it needs
+	ErrorPermission
+	// ErrorUndefined is a function called from Go which no script declared
+	ErrorUndefined
+)
+
+func NewCustomError(m string) *CustomError {
+	return &CustomError{Message: m}
+}
+
+// NewKindError is an error of another kind than the errors of the script,
+// like a limit hit or a permission denied.
+func NewKindError(kind ErrorKind, m string) *CustomError {
+	return &CustomError{Message: m, kind: kind}
+}
+
+// NewCausedError is a script error raised by a Go error, errors.Is and
+// errors.As find cause through it.
+func NewCausedError(m string, cause error) *CustomError {
+	return &CustomError{Message: m, cause: cause}
+}
+
+func (cm *CustomError) Error() string {
+	return cm.Message
+}
+
+func (cm *CustomError) Unwrap() error {
+	return cm.cause
+}
+
+// Kind returns what caused the error, ErrorScript for the errors of the
+// script itself.
+func (cm *CustomError) Kind() ErrorKind {
+	return cm.kind
+}
+
+// NewErrorList wraps the errors collected by a pass over the source, total
+// counts the errors past the ones kept. A single error is returned as it is.
+func NewErrorList(errors []*CustomError, total int, kind string) *CustomError {
+	if total == 1 && len(errors) == 1 {
+		return errors[0]
+	}
+
+	message := fmt.Sprintf("Found %d %s", total, kind)
+	if total > len(errors) {
+		message += fmt.Sprintf(", showing the first %d", len(errors))
+	}
+
+	return &CustomError{Message: message, Errors: errors}
+}
+
+func (cm *CustomError) AddTrace(pos int) *CustomError {
+	return cm.AddSpan(pos, pos)
+}
+
+func (cm *CustomError) AddSpan(pos int, end int) *CustomError {
+	cm.Trace = append(cm.Trace, TraceEntry{Pos: pos, End: end})
+	return cm
+}
+
+func (cm *CustomError) AddFrame(function string, callPos int) *CustomError {
+	cm.Stack = append(cm.Stack, StackFrame{Function: function, Call: TraceEntry{Pos: callPos, End: callPos}})
+	return cm
+}
+
+// InFile assigns the file to the trace entries and call sites not yet
+// assigned to one.
+func (cm *CustomError) InFile(file string) *CustomError {
+	for i := range cm.Trace {
+		if cm.Trace[i].File == "" {
+			cm.Trace[i].File = file
+		}
+	}
+
+	for i := range cm.Stack {
+		if cm.Stack[i].Call.File == "" {
+			cm.Stack[i].Call.File = file
+		}
+	}
+
+	for _, e := range cm.Errors {
+		e.InFile(file)
+	}
+
+	return cm
+}
+
+// Locate returns the 1 based line and column of pos and the text of the
+// line, positions count characters as the tokenizer does.
+func Locate(source string, pos int) (int, int, string) {
+	chars := []rune(source)
+	if pos > len(chars) {
+		pos = len(chars)
+	}
+	if pos < 0 {
+		pos = 0
+	}
+
+	line := 1
+	lineStart := 0
+	for i := 0; i < pos; i++ {
+		if chars[i] == '\n' {
+			line++
+			lineStart = i + 1
+		}
+	}
+
+	lineEnd := lineStart
+	for lineEnd < len(chars) && chars[lineEnd] != '\n' {
+		lineEnd++
+	}
+
+	return line, pos - lineStart + 1, string(chars[lineStart:lineEnd])
+}
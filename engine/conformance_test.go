@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aolbrich/lexer/runtime"
+)
+
+// skippedExamples print the current time or a random number, their output
+// differs between any two runs.
+var skippedExamples = map[string]bool{
+	"times.gl": true,
+	"game2.gl": true,
+}
+
+// runCaptured runs a script on the engine and returns what it printed, its
+// warnings and its error rendered like the gl command does, the console
+// answers "o". The script runs in a temporary directory so the files it
+// writes are dropped.
+func runCaptured(t *testing.T, engine runtime.Engine, run func(ctx context.Context, e *Engine) (string, error)) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	out, err := os.Create(filepath.Join(dir, "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	in, err := os.Create(filepath.Join(dir, "input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if _, err := in.WriteString("o\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stdin := os.Stdout, os.Stdin
+	os.Stdout, os.Stdin = out, in
+	defer func() {
+		os.Stdout, os.Stdin = stdout, stdin
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var e *Engine
+	e = New(Options{
+		Engine:      engine,
+		Permissions: runtime.AllowAll(),
+		Warn: func(warning error) {
+			e.ReportWarning(out, warning, false)
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if source, err := run(ctx, e); err != nil {
+		e.Report(out, err, source, false)
+	}
+
+	output, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(output)
+}
+
+func TestExamplesConformance(t *testing.T) {
+	scripts, err := filepath.Glob("../examples/*.gl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no examples found")
+	}
+
+	for _, script := range scripts {
+		if skippedExamples[filepath.Base(script)] {
+			continue
+		}
+		file, err := filepath.Abs(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var outputs [2]string
+		for index, engine := range []runtime.Engine{runtime.EngineTree, runtime.EngineVM} {
+			outputs[index] = runCaptured(t, engine, func(ctx context.Context, e *Engine) (string, error) {
+				_, err := e.RunFile(ctx, file)
+				return "", err
+			})
+		}
+
+		if outputs[0] != outputs[1] {
+			t.Errorf("%s: the engines differ\ntree:\n%s\nvm:\n%s", script, outputs[0], outputs[1])
+		}
+	}
+}
+
+func TestErrorsConformance(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		report string
+	}{
+		{
+			"if condition",
+			"fn check(x) {\n    if (x) { 1 }\n}\ncheck(1)",
+			"error: Condition must be a bool, got 1\n  --> 2:9\n  |\n2 |     if (x) { 1 }\n  |         ^\n",
+		},
+		{
+			"for condition",
+			"fn loop(x) {\n    for (x) { 1 }\n}\nloop(\"a\")",
+			"error: Condition must be a bool, got \"a\"\n  --> 2:10\n  |\n2 |     for (x) { 1 }\n  |          ^\n",
+		},
+		{
+			"shift overflow",
+			"println(1 << 63)",
+			"error: Integer overflow in 1 << 63\n  --> 1:9\n  |\n1 | println(1 << 63)\n  |         ^^^^^^^\n",
+		},
+		{
+			"nested operator",
+			"let big = 1 << 62;\nlet x = 1 + big * 2;\nx",
+			"error: Integer overflow in 4611686018427387904 * 2\n  --> 2:13\n  |\n2 | let x = 1 + big * 2;\n  |             ^^^^^^^\n",
+		},
+		{
+			"declaration in a loop",
+			"for (let i = 0; i < 2; i = i + 1) {\n    let y = i;\n    y\n}",
+			"error: Variable y already exists\n  --> 2:5\n  |\n2 |     let y = i;\n  |     ^^^^^^^^^^\n",
+		},
+	}
+
+	for _, test := range tests {
+		var outputs [2]string
+		for index, engine := range []runtime.Engine{runtime.EngineTree, runtime.EngineVM} {
+			outputs[index] = runCaptured(t, engine, func(ctx context.Context, e *Engine) (string, error) {
+				_, err := e.Run(ctx, test.source)
+				return test.source, err
+			})
+
+			if !strings.Contains(outputs[index], test.report) {
+				t.Errorf("%s %s: expected the report\n%s\ngot\n%s", engine, test.name, test.report, outputs[index])
+			}
+		}
+
+		if outputs[0] != outputs[1] {
+			t.Errorf("%s: the engines differ\ntree:\n%s\nvm:\n%s", test.name, outputs[0], outputs[1])
+		}
+	}
+}
@@ -0,0 +1,413 @@
+// Package engine runs scripts from Go programs. An Engine keeps the root
+// environment its scripts run in, the declarations of one run are seen by
+// the next ones:
+//
+//	e := engine.New(engine.Options{MaxSteps: 1000000})
+//	result, err := e.Run(ctx, `1 + 2`)
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+	"aolbrich/lexer/runtime"
+)
+
+// Options configure an engine, the zero value runs scripts on the tree
+// engine without limits and with every capability but exec and env.
+type Options struct {
+	// Engine runs the scripts, runtime.EngineTree when empty
+	Engine runtime.Engine
+	// Optimize runs the optimizer over scripts and modules once checked
+	Optimize bool
+	// MaxCallDepth is runtime.DefaultMaxCallDepth when 0
+	MaxCallDepth int
+	// MaxSteps and MaxAlloc bound every run, 0 for no limit
+	MaxSteps int64
+	MaxAlloc int64
+	// Permissions granted to the scripts. When nil every capability is
+	// granted but exec and env, a host lets scripts start processes or read
+	// its environment only by granting them.
+	Permissions *runtime.Permissions
+	// SearchPath is searched for modules before the GLPATH directories
+	SearchPath []string
+	// Warn receives the warnings of the checks, they are dropped when nil
+	Warn func(warning error)
+}
+
+// Engine runs scripts in a root environment of its own.
+type Engine struct {
+	interpreter *runtime.Interpreter
+	env         *runtime.Environments
+	// registered are the names and values given to Register, declared
+	// again in the environments scripts are compiled in
+	registered []registration
+	// err is the error making the root environment, returned by the runs
+	err error
+}
+
+type registration struct {
+	name  string
+	value interface{}
+}
+
+// New creates an engine, the errors making its root environment are
+// returned by its runs.
+func New(opts Options) *Engine {
+	i := runtime.NewInterpreter()
+	if opts.Engine != "" {
+		i.Engine = opts.Engine
+	}
+	i.Optimize = opts.Optimize
+	if opts.MaxCallDepth > 0 {
+		i.MaxCallDepth = opts.MaxCallDepth
+	}
+	i.MaxSteps = opts.MaxSteps
+	i.MaxAlloc = opts.MaxAlloc
+	i.Modules.SearchPath = append(append([]string{}, opts.SearchPath...), i.Modules.SearchPath...)
+	if opts.Warn != nil {
+		i.Modules.Warn = func(warning *diag.CustomError) {
+			opts.Warn(warning)
+		}
+	}
+
+	permissions := opts.Permissions
+	if permissions == nil {
+		permissions = defaultPermissions()
+	}
+
+	e := &Engine{interpreter: i}
+	env, err := runtime.NewSandboxEnvironments(permissions)
+	if err != nil {
+		e.err = err
+	}
+	e.env = env
+
+	return e
+}
+
+// defaultPermissions grant every capability but starting processes and
+// reading the environment of the host.
+func defaultPermissions() *runtime.Permissions {
+	p := runtime.DenyAll()
+	for _, c := range runtime.Capabilities {
+		if c != runtime.CapExec && c != runtime.CapEnv {
+			// Known capabilities without paths are always granted
+			_ = p.Allow(c)
+		}
+	}
+
+	return p
+}
+
+// Register declares a Go function or value in the root environment of the
+// engine, see runtime.Environments.Register for the conversions.
+func (e *Engine) Register(name string, value interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	if err := e.env.Register(name, value); err != nil {
+		return err
+	}
+	e.registered = append(e.registered, registration{name: name, value: value})
+
+	return nil
+}
+
+// Call calls the function named name declared by the scripts the engine
+// ran, like a hook of a file run by RunFile, and returns its value as
+// runtime.GoValue converts it. The arguments are converted like the
+// values given to Register. A function may be called any number of times,
+// each call with the budgets of a run, but a call made by a host function
+// during a run counts against the budgets of that run.
+func (e *Engine) Call(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	result, err := e.call(ctx, name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return runtime.GoValue(result), nil
+}
+
+// CallInto calls a function like Call and stores its value in the Go
+// variable result points to, converted like the arguments of a registered
+// function.
+func (e *Engine) CallInto(ctx context.Context, result interface{}, name string, args ...interface{}) error {
+	value, err := e.call(ctx, name, args)
+	if err != nil {
+		return err
+	}
+
+	if err := runtime.FromValue(value, result); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (e *Engine) call(ctx context.Context, name string, args []interface{}) (_ runtime.RuntimeVal, recovered error) {
+	defer recoverPanic(&recovered)
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	fn, err := e.env.LookupFunction(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := e.interpreter.Call(ctx, fn, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Run checks and runs source in the root environment of the engine and
+// returns the value of its last statement. The run stops once ctx is done.
+func (e *Engine) Run(ctx context.Context, source string) (runtime.RuntimeVal, error) {
+	program, err := e.check("", source, e.env)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.run(ctx, program)
+}
+
+// Eval runs source in the root environment without the checks, for input
+// typed at a prompt which builds on the previous one.
+func (e *Engine) Eval(ctx context.Context, source string) (runtime.RuntimeVal, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	program, err := parser.NewParser().ProduceAST(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.run(ctx, program)
+}
+
+func (e *Engine) run(ctx context.Context, program *ast.Program) (_ runtime.RuntimeVal, recovered error) {
+	defer recoverPanic(&recovered)
+
+	result, err := e.interpreter.WithContext(ctx).Run(program, e.env)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RunFile runs a script file as the module root, or the program compiled
+// from it next to it when the VM runs it. Files with the compiled extension
+// run on the VM, or their source when compiled from another version of it.
+func (e *Engine) RunFile(ctx context.Context, name string) (runtime.RuntimeVal, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	if strings.HasSuffix(name, runtime.CompiledExt) {
+		return e.runCompiledFile(ctx, name)
+	}
+
+	source, err := runtime.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if chunk := e.interpreter.LoadPrecompiled(file, source); chunk != nil {
+		return e.runCompiled(ctx, file, source, chunk)
+	}
+
+	return e.runSource(ctx, file, source)
+}
+
+// runCompiledFile runs a compiled program on the VM, the source next to it
+// runs instead when the program was compiled from another version of it or
+// by another version of the compiler.
+func (e *Engine) runCompiledFile(ctx context.Context, name string) (runtime.RuntimeVal, error) {
+	compiledFile, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only this run and the modules it imports run on the VM
+	previous := e.interpreter.Engine
+	e.interpreter.Engine = runtime.EngineVM
+	defer func() {
+		e.interpreter.Engine = previous
+	}()
+
+	file := runtime.SourcePath(compiledFile)
+	compiled, err := runtime.ReadCompiled(compiledFile)
+
+	source, sourceErr := runtime.ReadFile(file)
+	if sourceErr != nil {
+		// Deployed without the source, errors quote the compiled copy
+		if err != nil {
+			return nil, err
+		}
+		return e.runCompiled(ctx, file, compiled.Source, compiled.Chunk)
+	}
+
+	if err == nil && !compiled.CompiledFrom(source) {
+		err = runtime.ErrStaleBytecode
+	}
+	if err != nil {
+		e.warn(diag.NewCustomError(fmt.Sprintf("Ignoring %s, %s, running the source", runtime.DisplayFileName(compiledFile), err)))
+		return e.runSource(ctx, file, source)
+	}
+
+	return e.runCompiled(ctx, file, source, compiled.Chunk)
+}
+
+func (e *Engine) runCompiled(ctx context.Context, file string, source string, chunk *runtime.Chunk) (_ runtime.RuntimeVal, recovered error) {
+	defer recoverPanic(&recovered)
+
+	e.interpreter.Modules.Sources[file] = source
+	result, err := e.interpreter.WithContext(ctx).EvaluateCompiledModule(file, source, chunk, e.env)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (e *Engine) runSource(ctx context.Context, file string, source string) (_ runtime.RuntimeVal, recovered error) {
+	defer recoverPanic(&recovered)
+
+	program, err := e.check(file, source, e.env)
+	if err != nil {
+		return nil, err
+	}
+
+	result, cErr := e.interpreter.WithContext(ctx).EvaluateModule(file, source, program, e.env)
+	if cErr != nil {
+		return nil, cErr
+	}
+
+	return result, nil
+}
+
+// Check parses, checks and optimizes when asked to the script of a file
+// without running it, source is its content.
+func (e *Engine) Check(file string, source string) (*ast.Program, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return e.check(file, source, e.env)
+}
+
+func (e *Engine) check(file string, source string, env *runtime.Environments) (*ast.Program, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	e.interpreter.Modules.Sources[file] = source
+	program, err := parser.NewParser().ProduceAST(source)
+	if err != nil {
+		return nil, err.InFile(file)
+	}
+
+	if err := e.interpreter.CheckProgram(file, program, env); err != nil {
+		return nil, err
+	}
+	e.interpreter.OptimizeProgram(program)
+
+	return program, nil
+}
+
+// Compile checks a script file and writes its compiled program next to
+// it, the VM runs the compiled program instead of parsing the script.
+func (e *Engine) Compile(name string) error {
+	file, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+
+	source, err := runtime.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	// Every script is checked in a root environment of its own with the
+	// functions of the host, compiling runs none of it
+	env, cErr := runtime.NewEnvironments(nil)
+	if cErr != nil {
+		return cErr
+	}
+	for _, r := range e.registered {
+		if cErr := env.Register(r.name, r.value); cErr != nil {
+			return cErr
+		}
+	}
+
+	program, err := e.check(file, source, env)
+	if err != nil {
+		return err
+	}
+
+	chunk, cErr := runtime.CompileProgram(program)
+	if cErr != nil {
+		return cErr.InFile(file)
+	}
+
+	return runtime.WriteCompiled(runtime.CompiledPath(file), source, chunk)
+}
+
+// Report renders an error returned by the engine with the source line it
+// points to, source is the one given to Run or Eval, the errors of files
+// and modules find theirs. Errors not raised by a script are printed as
+// they are.
+func (e *Engine) Report(out io.Writer, err error, source string, color bool) {
+	var scriptErr *diag.CustomError
+	if !errors.As(err, &scriptErr) {
+		fmt.Fprintln(out, err)
+		return
+	}
+
+	runtime.NewErrorReporter(out, color, e.interpreter.Modules).Report(scriptErr, source)
+}
+
+// ReportWarning renders a warning given to the Warn hook.
+func (e *Engine) ReportWarning(out io.Writer, warning error, color bool) {
+	var scriptErr *diag.CustomError
+	if !errors.As(warning, &scriptErr) {
+		fmt.Fprintln(out, warning)
+		return
+	}
+
+	runtime.NewErrorReporter(out, color, e.interpreter.Modules).Warning(scriptErr, "")
+}
+
+// recoverPanic turns a panic of a run into its error, a script must not
+// crash the host running it.
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = diag.NewCustomError(fmt.Sprintf("Internal error running the script, %v", r))
+	}
+}
+
+func (e *Engine) warn(warning *diag.CustomError) {
+	if e.interpreter.Modules.Warn != nil {
+		e.interpreter.Modules.Warn(warning)
+	}
+}
@@ -0,0 +1,298 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/runtime"
+)
+
+const hooks = `
+fn burn(n) {
+  let i = 0;
+  for (i < n) { i = i + 1 }
+  i
+}
+
+fn outer(n) {
+  callback(n)
+  for (true) {}
+}
+`
+
+func newHookEngine(t *testing.T, opts Options) *Engine {
+	e := New(opts)
+	err := e.Register("callback", func(n int) (int, error) {
+		result, err := e.Call(context.Background(), "burn", n)
+		if err != nil {
+			return 0, err
+		}
+		return int(result.(int64)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Run(context.Background(), hooks); err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func errorKind(err error) diag.ErrorKind {
+	var scriptErr *diag.CustomError
+	if !errors.As(err, &scriptErr) {
+		return -1
+	}
+
+	return scriptErr.Kind()
+}
+
+func TestNestedCallKeepsDeadline(t *testing.T) {
+	for _, engine := range []runtime.Engine{runtime.EngineTree, runtime.EngineVM} {
+		e := newHookEngine(t, Options{Engine: engine})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		start := time.Now()
+		_, err := e.Call(ctx, "outer", 10)
+		cancel()
+
+		if kind := errorKind(err); kind != diag.ErrorDeadline {
+			t.Errorf("%s: expected a deadline error, got %v", engine, err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: stopped after %s", engine, elapsed)
+		}
+	}
+}
+
+func TestNestedCallCountsSteps(t *testing.T) {
+	for _, engine := range []runtime.Engine{runtime.EngineTree, runtime.EngineVM} {
+		e := newHookEngine(t, Options{Engine: engine, MaxSteps: 10000})
+
+		// The steps of the callback alone go past the budget of the call
+		_, err := e.Call(context.Background(), "outer", 100000)
+		if kind := errorKind(err); kind != diag.ErrorStepLimit {
+			t.Errorf("%s: expected a step limit error, got %v", engine, err)
+		}
+
+		_, err = e.Call(context.Background(), "outer", 10)
+		if kind := errorKind(err); kind != diag.ErrorStepLimit {
+			t.Errorf("%s: expected a step limit error, got %v", engine, err)
+		}
+
+		// The budgets start over once the call failed
+		result, err := e.Call(context.Background(), "burn", 100)
+		if err != nil || result != int64(100) {
+			t.Errorf("%s: expected 100, got %v %v", engine, result, err)
+		}
+	}
+}
+
+func TestEmptyLoopsStop(t *testing.T) {
+	loops := []string{
+		`for { }`,
+		`for (let i of range(0, 1000000000000)) { }`,
+	}
+
+	for _, engine := range []runtime.Engine{runtime.EngineTree, runtime.EngineVM} {
+		for _, loop := range loops {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			start := time.Now()
+			_, err := New(Options{Engine: engine}).Run(ctx, loop)
+			cancel()
+			if kind := errorKind(err); kind != diag.ErrorDeadline {
+				t.Errorf("%s: %s: expected a deadline error, got %v", engine, loop, err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("%s: %s: stopped after %s", engine, loop, elapsed)
+			}
+
+			_, err = New(Options{Engine: engine, MaxSteps: 1000}).Run(context.Background(), loop)
+			if kind := errorKind(err); kind != diag.ErrorStepLimit {
+				t.Errorf("%s: %s: expected a step limit error, got %v", engine, loop, err)
+			}
+		}
+	}
+}
+
+func TestBadScriptsReturnErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{`if (1) { 2 }`, "Condition must be a bool, got 1"},
+		{`for (1) {}`, "Condition must be a bool, got 1"},
+		{`for (let i = 0; "x"; i = i + 1) {}`, `Condition must be a bool, got "x"`},
+		{`[sleep(), fileRead(), numToStr(), strToNum(), round(), len()]`, ""},
+	}
+
+	for _, engine := range []runtime.Engine{runtime.EngineTree, runtime.EngineVM} {
+		for _, test := range tests {
+			e := New(Options{Engine: engine})
+
+			// Run without the checks, which report some of them first
+			_, err := e.Eval(context.Background(), test.source)
+			if test.err == "" && err != nil {
+				t.Errorf("%s: %s: %v", engine, test.source, err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("%s: %s: expected %q, got %v", engine, test.source, test.err, err)
+			}
+		}
+	}
+}
+
+func TestWaitingNativesStopWithRun(t *testing.T) {
+	sources := []string{`sleep(3000)`, `nap()`, `wait()`}
+	if _, err := exec.LookPath("sleep"); err == nil {
+		sources = append(sources, `exec("sleep", "3")`)
+	}
+
+	for _, engine := range []runtime.Engine{runtime.EngineTree, runtime.EngineVM} {
+		e := New(Options{Engine: engine, Permissions: runtime.AllowAll()})
+		// wait naps in a run of its own, which stops with the outer one
+		err := e.Register("wait", func() error {
+			_, err := e.Call(context.Background(), "nap")
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Run(context.Background(), `fn nap() { sleep(3000) }`); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, source := range sources {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			start := time.Now()
+			_, err := e.Run(ctx, source)
+			cancel()
+
+			if kind := errorKind(err); kind != diag.ErrorDeadline {
+				t.Errorf("%s: %s: expected a deadline error, got %v", engine, source, err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("%s: %s: stopped after %s", engine, source, elapsed)
+			}
+		}
+	}
+}
+
+func TestDefaultPermissions(t *testing.T) {
+	for _, source := range []string{`exec("true")`, `getEnv("HOME")`} {
+		_, err := New(Options{}).Run(context.Background(), source)
+		if kind := errorKind(err); kind != diag.ErrorPermission {
+			t.Errorf("%s: expected a permission error, got %v", source, err)
+		}
+
+		_, err = New(Options{Permissions: runtime.AllowAll()}).Run(context.Background(), source)
+		if err != nil {
+			t.Errorf("%s: %v", source, err)
+		}
+	}
+
+	if _, err := New(Options{}).Run(context.Background(), `time()`); err != nil {
+		t.Errorf("time: %v", err)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	run := func() (_ runtime.RuntimeVal, recovered error) {
+		defer recoverPanic(&recovered)
+		panic("boom")
+	}
+
+	if _, err := run(); err == nil || !strings.Contains(err.Error(), "Internal error running the script, boom") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+}
+
+func TestRunCompiledFileKeepsEngine(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hooks.gl")
+	if err := os.WriteFile(file, []byte("fn burn(n) { n }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(Options{Engine: runtime.EngineTree})
+	if err := e.Compile(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.RunFile(context.Background(), runtime.CompiledPath(file)); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.interpreter.Engine != runtime.EngineTree {
+		t.Errorf("running a compiled file left the engine on %s", e.interpreter.Engine)
+	}
+	if result, err := e.Call(context.Background(), "burn", 3); err != nil || result != int64(3) {
+		t.Errorf("expected 3, got %v %v", result, err)
+	}
+}
+
+func TestCompileSeesHostFunctions(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hooks.gl")
+	if err := os.WriteFile(file, []byte(hooks), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(Options{Engine: runtime.EngineVM})
+	if err := e.Register("callback", func(n int) int { return n }); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Compile(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.RunFile(context.Background(), runtime.CompiledPath(file)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunDamagedCompiledFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "script.gl")
+	if err := os.WriteFile(file, []byte("fn twice(n) { n * 2 }\ntwice(21)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []error
+	options := Options{Engine: runtime.EngineVM, Warn: func(warning error) {
+		warnings = append(warnings, warning)
+	}}
+	if err := New(options).Compile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	compiled := runtime.CompiledPath(file)
+	content, err := os.ReadFile(compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[len(content)-20] ^= 0xff
+	if err := os.WriteFile(compiled, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{compiled, file} {
+		warnings = nil
+		result, err := New(options).RunFile(context.Background(), name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if n, ok := result.(*runtime.IntVal); !ok || n.Value != 42 {
+			t.Errorf("%s: expected 42 from the source, got %v", name, result)
+		}
+		if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "corrupt compiled program") {
+			t.Errorf("%s: expected a warning about the damaged file, got %v", name, warnings)
+		}
+	}
+}
@@ -1,8 +1,11 @@
-package main
+// Package lexer turns the source of a script into tokens.
+package lexer
 
 import (
 	"fmt"
 	"strings"
+
+	"aolbrich/lexer/diag"
 )
 
 type TokenType int
@@ -44,24 +47,30 @@ const (
 	TokenTypeDefault
 	TokenTypeBreak
 	TokenTypeContinue
+	TokenTypeMatch
+	TokenTypeImport
+	TokenTypeExport
+	TokenTypeArrow
 	TokenTypeEOF
 )
 
+// Token spans the characters from Pos to End, End excluded.
 type Token struct {
 	Value string
 	Type  TokenType
 	Pos   int
+	End   int
 }
 
 type Tokenizer struct {
 	keywords map[string]TokenType
 }
 
-func newTokenizer() *Tokenizer {
+func NewTokenizer() *Tokenizer {
 	return &Tokenizer{}
 }
 
-func (t *Tokenizer) tokenize(sourceCode string) ([]Token, *CustomError) {
+func (t *Tokenizer) Tokenize(sourceCode string) ([]Token, *diag.CustomError) {
 	t.keywords = map[string]TokenType{
 		"let":      TokenTypeLet,
 		"const":    TokenTypeConst,
@@ -75,6 +84,9 @@ func (t *Tokenizer) tokenize(sourceCode string) ([]Token, *CustomError) {
 		"default":  TokenTypeDefault,
 		"break":    TokenTypeBreak,
 		"continue": TokenTypeContinue,
+		"match":    TokenTypeMatch,
+		"import":   TokenTypeImport,
+		"export":   TokenTypeExport,
 	}
 
 	var tokens []Token
@@ -87,27 +99,33 @@ func (t *Tokenizer) tokenize(sourceCode string) ([]Token, *CustomError) {
 			break
 		}
 
+		count := len(tokens)
+
 		switch src[i] {
 		case "(":
-			tokens = append(tokens, Token{Type: TokenTypeOpenParen, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeOpenParen, Value: "(", Pos: i})
 			i++
 		case ")":
-			tokens = append(tokens, Token{Type: TokenTypeCloseParen, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeCloseParen, Value: ")", Pos: i})
 			i++
 		case "{":
-			tokens = append(tokens, Token{Type: TokenTypeOpenBrace, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeOpenBrace, Value: "{", Pos: i})
 			i++
 		case "}":
-			tokens = append(tokens, Token{Type: TokenTypeCloseBrace, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeCloseBrace, Value: "}", Pos: i})
 			i++
 		case "[":
-			tokens = append(tokens, Token{Type: TokenTypeOpenBracket, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeOpenBracket, Value: "[", Pos: i})
 			i++
 		case "]":
-			tokens = append(tokens, Token{Type: TokenTypeCloseBracket, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeCloseBracket, Value: "]", Pos: i})
 			i++
 		case "+", "-", "/", "*", "%":
-			if i < srcLen-1 && src[i+1] == "/" {
+			if src[i] == "*" && i < srcLen-1 && src[i+1] == "*" {
+				tokens = append(tokens, Token{Type: TokenTypeBinaryOperator, Value: "**", Pos: i})
+				i++
+				i++
+			} else if i < srcLen-1 && src[i+1] == "/" {
 				for {
 					if i == len(src) || src[i] == "\n" {
 						break
@@ -122,24 +140,30 @@ func (t *Tokenizer) tokenize(sourceCode string) ([]Token, *CustomError) {
 			if i < srcLen-1 && src[i+1] == "=" {
 				tokens = append(tokens, Token{Type: TokenTypeDoubeEqual, Value: "=", Pos: i})
 				i++
+			} else if i < srcLen-1 && src[i+1] == ">" {
+				tokens = append(tokens, Token{Type: TokenTypeArrow, Value: "=>", Pos: i})
+				i++
 			} else {
-				tokens = append(tokens, Token{Type: TokenTypeEquals, Pos: i})
+				tokens = append(tokens, Token{Type: TokenTypeEquals, Value: "=", Pos: i})
 			}
 			i++
 		case ";":
-			tokens = append(tokens, Token{Type: TokenTypeSemicolon, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeSemicolon, Value: ";", Pos: i})
 			i++
 		case ":":
-			tokens = append(tokens, Token{Type: TokenTypeColon, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeColon, Value: ":", Pos: i})
 			i++
 		case ",":
-			tokens = append(tokens, Token{Type: TokenTypeComma, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeComma, Value: ",", Pos: i})
 			i++
 		case ".":
-			tokens = append(tokens, Token{Type: TokenTypeDot, Pos: i})
+			tokens = append(tokens, Token{Type: TokenTypeDot, Value: ".", Pos: i})
 			i++
 		case "<":
-			if i < srcLen-1 && src[i+1] == "=" {
+			if i < srcLen-1 && src[i+1] == "<" {
+				tokens = append(tokens, Token{Type: TokenTypeBinaryOperator, Value: "<<", Pos: i})
+				i++
+			} else if i < srcLen-1 && src[i+1] == "=" {
 				tokens = append(tokens, Token{Type: TokenTypeSmallerEqual, Value: "<=", Pos: i})
 				i++
 			} else if i < srcLen-1 && src[i+1] == ">" {
@@ -159,7 +183,10 @@ func (t *Tokenizer) tokenize(sourceCode string) ([]Token, *CustomError) {
 			}
 			i++
 		case ">":
-			if i < srcLen-1 && src[i+1] == "=" {
+			if i < srcLen-1 && src[i+1] == ">" {
+				tokens = append(tokens, Token{Type: TokenTypeBinaryOperator, Value: ">>", Pos: i})
+				i++
+			} else if i < srcLen-1 && src[i+1] == "=" {
 				tokens = append(tokens, Token{Type: TokenTypeGreaterEqual, Value: ">=", Pos: i})
 				i++
 			} else {
@@ -172,7 +199,8 @@ func (t *Tokenizer) tokenize(sourceCode string) ([]Token, *CustomError) {
 				i++
 				i++
 			} else {
-				return nil, newCustomError("Condition requries doube &").addTrace(i)
+				tokens = append(tokens, Token{Type: TokenTypeBinaryOperator, Value: "&", Pos: i})
+				i++
 			}
 		case "|":
 			if i < srcLen-1 && src[i+1] == "|" {
@@ -180,8 +208,20 @@ func (t *Tokenizer) tokenize(sourceCode string) ([]Token, *CustomError) {
 				i++
 				i++
 			} else {
-				return nil, newCustomError("Condition requries doube |").addTrace(i)
+				tokens = append(tokens, Token{Type: TokenTypeBinaryOperator, Value: "|", Pos: i})
+				i++
+			}
+		case "^":
+			tokens = append(tokens, Token{Type: TokenTypeBinaryOperator, Value: "^", Pos: i})
+			i++
+		case "~":
+			if i < srcLen-1 && src[i+1] == "/" {
+				tokens = append(tokens, Token{Type: TokenTypeBinaryOperator, Value: "~/", Pos: i})
+				i++
+			} else {
+				tokens = append(tokens, Token{Type: TokenTypeBinaryOperator, Value: "~", Pos: i})
 			}
+			i++
 		default:
 			tk, index, err := t.tokenizeComplex(src, i)
 			if err != nil {
@@ -194,14 +234,21 @@ func (t *Tokenizer) tokenize(sourceCode string) ([]Token, *CustomError) {
 
 			i = index
 		}
+
+		// Every case leaves i after the characters of the token it added
+		if len(tokens) > count {
+			tokens[len(tokens)-1].End = i
+		}
 	}
 
-	tokens = append(tokens, Token{Type: TokenTypeEOF, Value: "EndOfFile", Pos: i})
+	tokens = append(tokens, Token{Type: TokenTypeEOF, Value: "EndOfFile", Pos: i, End: i})
 
 	return tokens, nil
 }
 
-func (t *Tokenizer) tokenizeComplex(src []string, i int) (*Token, int, *CustomError) {
+func (t *Tokenizer) tokenizeComplex(src []string, i int) (*Token, int, *diag.CustomError) {
+	start := i
+
 	if t.isInt(src[i]) {
 		num := ""
 		for {
@@ -212,7 +259,35 @@ func (t *Tokenizer) tokenizeComplex(src []string, i int) (*Token, int, *CustomEr
 			i++
 		}
 
-		return &Token{Type: TokenTypeNumber, Value: num, Pos: i}, i, nil
+		// Fraction part, a dot not followed by a digit is a member access
+		if i < len(src)-1 && src[i] == "." && t.isInt(src[i+1]) {
+			num += src[i]
+			i++
+			for i < len(src) && t.isInt(src[i]) {
+				num += src[i]
+				i++
+			}
+		}
+
+		// Exponent part, like 1e9 or 2.5e-3
+		if i < len(src)-1 && (src[i] == "e" || src[i] == "E") {
+			exp := i + 1
+			if exp < len(src)-1 && (src[exp] == "-" || src[exp] == "+") {
+				exp++
+			}
+
+			if t.isInt(src[exp]) {
+				for ; i < exp; i++ {
+					num += src[i]
+				}
+				for i < len(src) && t.isInt(src[i]) {
+					num += src[i]
+					i++
+				}
+			}
+		}
+
+		return &Token{Type: TokenTypeNumber, Value: num, Pos: start}, i, nil
 	}
 
 	if t.isAlpha(src[i]) {
@@ -226,17 +301,17 @@ func (t *Tokenizer) tokenizeComplex(src []string, i int) (*Token, int, *CustomEr
 		}
 
 		if keywordTokenType, exist := t.keywords[alpha]; exist {
-			return &Token{Type: keywordTokenType, Value: alpha, Pos: i}, i, nil
+			return &Token{Type: keywordTokenType, Value: alpha, Pos: start}, i, nil
 		}
 
-		return &Token{Type: TokenTypeIdentifier, Value: alpha, Pos: i}, i, nil
+		return &Token{Type: TokenTypeIdentifier, Value: alpha, Pos: start}, i, nil
 	}
 
 	if src[i] == "\"" {
 		str := ""
 		if i == len(src) {
-			err := newCustomError("After opening quote there should be at least one closing quote")
-			err.addTrace(i)
+			err := diag.NewCustomError("After opening quote there should be at least one closing quote")
+			err.AddTrace(i)
 			return nil, i, err
 		}
 		i++
@@ -259,7 +334,7 @@ func (t *Tokenizer) tokenizeComplex(src []string, i int) (*Token, int, *CustomEr
 			i++
 		}
 
-		return &Token{Type: TokenTypeString, Value: str, Pos: i}, i, nil
+		return &Token{Type: TokenTypeString, Value: str, Pos: start}, i, nil
 	}
 
 	if t.isSkippable(src[i]) {
@@ -267,7 +342,15 @@ func (t *Tokenizer) tokenizeComplex(src []string, i int) (*Token, int, *CustomEr
 		return nil, i, nil
 	}
 
-	return nil, i, newCustomError(fmt.Sprintf("Uncrecoginized charecter found in source %s", src[i])).addTrace(i)
+	return nil, i, diag.NewCustomError(fmt.Sprintf("Uncrecoginized charecter found in source %s", src[i])).AddTrace(i)
+}
+
+// IsIdentifier tells whether name is read as a single identifier, letters
+// and underscores only and not a keyword.
+func IsIdentifier(name string) bool {
+	tokens, err := NewTokenizer().Tokenize(name)
+
+	return err == nil && len(tokens) == 2 && tokens[0].Type == TokenTypeIdentifier && tokens[0].Value == name
 }
 
 func (t *Tokenizer) isSkippable(s string) bool {
@@ -279,5 +362,5 @@ func (t *Tokenizer) isInt(s string) bool {
 }
 
 func (t *Tokenizer) isAlpha(s string) bool {
-	return strings.ToLower(s) != strings.ToUpper(s)
+	return s == "_" || strings.ToLower(s) != strings.ToUpper(s)
 }
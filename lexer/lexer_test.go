@@ -0,0 +1,52 @@
+package lexer
+
+import "testing"
+
+func TestTokenSpans(t *testing.T) {
+	tests := []struct {
+		source string
+		spans  []string
+	}{
+		{"let x = 10;", []string{"let", "x", "=", "10", ";"}},
+		{"a==b != c <> d", []string{"a", "==", "b", "!=", "c", "<>", "d"}},
+		{"x<=y>=z<<1>>2", []string{"x", "<=", "y", ">=", "z", "<<", "1", ">>", "2"}},
+		{"2 ** 3 ~/ 4 && p || q", []string{"2", "**", "3", "~/", "4", "&&", "p", "||", "q"}},
+		{"1.5e-3 + 2.x", []string{"1.5e-3", "+", "2", ".", "x"}},
+		{`"say ""hi""" + ""`, []string{`"say ""hi"""`, "+", `""`}},
+		{"match v { 1 => a }", []string{"match", "v", "{", "1", "=>", "a", "}"}},
+		{"f(a, [b]) // comment\n.c", []string{"f", "(", "a", ",", "[", "b", "]", ")", ".", "c"}},
+		// Positions count characters, not bytes
+		{`"héllo" + x`, []string{`"héllo"`, "+", "x"}},
+		{`"open`, []string{`"open`}},
+	}
+
+	for _, test := range tests {
+		tokens, err := NewTokenizer().Tokenize(test.source)
+		if err != nil {
+			t.Errorf("%q: %v", test.source, err)
+			continue
+		}
+
+		source := []rune(test.source)
+		last := tokens[len(tokens)-1]
+		if last.Type != TokenTypeEOF || last.Pos != len(source) || last.End != len(source) {
+			t.Errorf("%q: expected the end of file at %d, got %+v", test.source, len(source), last)
+		}
+
+		tokens = tokens[:len(tokens)-1]
+		if len(tokens) != len(test.spans) {
+			t.Errorf("%q: expected %d tokens, got %d: %+v", test.source, len(test.spans), len(tokens), tokens)
+			continue
+		}
+
+		for index, token := range tokens {
+			if token.Pos < 0 || token.Pos > token.End || token.End > len(source) {
+				t.Errorf("%q: token %d %q has an invalid span %d-%d", test.source, index, token.Value, token.Pos, token.End)
+				continue
+			}
+			if got := string(source[token.Pos:token.End]); got != test.spans[index] {
+				t.Errorf("%q: token %d expected to span %q, got %q", test.source, index, test.spans[index], got)
+			}
+		}
+	}
+}
@@ -0,0 +1,1744 @@
+// Package parser builds the syntax tree of a script from its tokens.
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/lexer"
+)
+
+// maxParseErrors is the number of syntax errors kept for reporting, the
+// parser keeps counting past it so the summary shows the total.
+const maxParseErrors = 20
+
+type Parser struct {
+	tokens []lexer.Token
+	index  int
+	errors []*diag.CustomError
+	total  int
+	// last is the end of the last consumed token, where the node being
+	// parsed ends
+	last int
+	// lineStarts tells the tokens starting a line, recovery resumes at them
+	lineStarts []bool
+}
+
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+func (p *Parser) ProduceAST(sourceCode string) (*ast.Program, *diag.CustomError) {
+	t := lexer.NewTokenizer()
+	tokens, err := t.Tokenize(sourceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	p.tokens = tokens
+	p.lineStarts = lineStarts(sourceCode, tokens)
+	p.index = 0
+	p.errors = nil
+	p.total = 0
+	p.last = 0
+
+	pr := &ast.Program{Stmt: ast.NewStmt(ast.NodeTypeProgram, 0, 0)}
+
+	for {
+		if p.eof() {
+			break
+		}
+
+		start := p.index
+		node, err := p.parseStmt()
+		if err != nil {
+			p.recover(err, start)
+			continue
+		}
+
+		pr.Body = append(pr.Body, node)
+
+	}
+
+	if p.total > 0 {
+		return nil, diag.NewErrorList(p.errors, p.total, "syntax errors")
+	}
+
+	pr.Stmt = ast.NewStmt(ast.NodeTypeProgram, 0, p.at().End)
+
+	return pr, nil
+}
+
+// parseBlockStmt parses a statement inside a block, a syntax error is
+// recorded and the statement skipped so the rest of the block is still checked.
+func (p *Parser) parseBlockStmt() ast.Stmter {
+	start := p.index
+	s, err := p.parseStmt()
+	if err != nil {
+		p.recover(err, start)
+		return nil
+	}
+
+	return s
+}
+
+// recover records err and skips to the next statement, the end of the
+// enclosing block, past the next semicolon or to the next line. Braces,
+// parentheses and brackets the failed statement opened and nested ones are
+// skipped as a whole. At least one token is consumed when the failed
+// statement did not consume any, a stray semicolon is then all skipped.
+func (p *Parser) recover(err *diag.CustomError, start int) {
+	p.total++
+	if len(p.errors) < maxParseErrors {
+		p.errors = append(p.errors, err)
+	}
+
+	if p.index == start && !p.eof() {
+		if p.next().Type == lexer.TokenTypeSemicolon {
+			return
+		}
+	}
+
+	depth, nested := 0, 0
+	for _, token := range p.tokens[start:p.index] {
+		switch token.Type {
+		case lexer.TokenTypeOpenBrace:
+			depth++
+		case lexer.TokenTypeCloseBrace:
+			if depth > 0 {
+				depth--
+			}
+		case lexer.TokenTypeOpenParen, lexer.TokenTypeOpenBracket:
+			nested++
+		case lexer.TokenTypeCloseParen, lexer.TokenTypeCloseBracket:
+			if nested > 0 {
+				nested--
+			}
+		}
+	}
+
+	for !p.eof() {
+		// Statements without a semicolon end with their line
+		if depth == 0 && nested == 0 && p.lineStarts[p.index] {
+			return
+		}
+
+		switch p.at().Type {
+		case lexer.TokenTypeOpenBrace:
+			depth++
+		case lexer.TokenTypeCloseBrace:
+			if depth == 0 {
+				return
+			}
+			depth--
+		case lexer.TokenTypeOpenParen, lexer.TokenTypeOpenBracket:
+			nested++
+		case lexer.TokenTypeCloseParen, lexer.TokenTypeCloseBracket:
+			if nested > 0 {
+				nested--
+			}
+		case lexer.TokenTypeSemicolon:
+			if depth == 0 {
+				p.next()
+				return
+			}
+		case lexer.TokenTypeLet, lexer.TokenTypeConst, lexer.TokenTypeFn, lexer.TokenTypeIf, lexer.TokenTypeFor, lexer.TokenTypeSwitch,
+			lexer.TokenTypeBreak, lexer.TokenTypeContinue, lexer.TokenTypeImport, lexer.TokenTypeExport:
+			if depth == 0 {
+				return
+			}
+		}
+		p.next()
+	}
+}
+
+// lineStarts tells for each token whether a line break comes before it.
+func lineStarts(source string, tokens []lexer.Token) []bool {
+	runes := []rune(source)
+	starts := make([]bool, len(tokens))
+	end := 0
+	for index, token := range tokens {
+		if token.Pos > end && token.Pos <= len(runes) {
+			starts[index] = strings.ContainsRune(string(runes[end:token.Pos]), '\n')
+		}
+		if token.End > end {
+			end = token.End
+		}
+	}
+
+	return starts
+}
+
+func (p *Parser) eof() bool {
+	return p.tokens[p.index].Type == lexer.TokenTypeEOF
+}
+
+func (p *Parser) at() lexer.Token {
+	return p.tokens[p.index]
+}
+
+func (p *Parser) next() lexer.Token {
+	token := p.tokens[p.index]
+	// The EOF token is never consumed so a failed statement cannot read past it
+	if token.Type != lexer.TokenTypeEOF {
+		p.index++
+		p.last = token.End
+	}
+	return token
+}
+
+// span is the header of a node of kind from start to the end of the last
+// consumed token, call it once the node is parsed.
+func (p *Parser) span(kind ast.NodeType, start int) *ast.Stmt {
+	return ast.NewStmt(kind, start, p.last)
+}
+
+// expect consumes the next token when it is of type t, a token of another
+// type is left for recover, it may start the next statement.
+func (p *Parser) expect(t lexer.TokenType, errMsg string) (*lexer.Token, *diag.CustomError) {
+	token := p.at()
+	if token.Type != t {
+		return nil, diag.NewCustomError(errMsg).AddSpan(token.Pos, token.End)
+	}
+	p.next()
+
+	return &token, nil
+}
+
+func (p *Parser) parseStmt() (ast.Stmter, *diag.CustomError) {
+	switch p.at().Type {
+	case lexer.TokenTypeLet, lexer.TokenTypeConst:
+		return p.parseVarDeclaration()
+	case lexer.TokenTypeFn:
+		return p.parseFunctionDeclaration()
+	case lexer.TokenTypeIf:
+		return p.parseIfExpression()
+	case lexer.TokenTypeFor:
+		return p.parseForExpression()
+	case lexer.TokenTypeBreak:
+		return p.parseBreakExpression()
+	case lexer.TokenTypeContinue:
+		return p.parseContinueExpression()
+	case lexer.TokenTypeSwitch:
+		return p.parseSwitchExpression()
+	case lexer.TokenTypeImport:
+		return p.parseImportDeclaration()
+	case lexer.TokenTypeExport:
+		return p.parseExportDeclaration()
+	default:
+		return p.parseExpr()
+	}
+}
+
+func (p *Parser) parseVarDeclaration() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	tokenType := p.next().Type
+	token := p.at()
+	isConstant := tokenType == lexer.TokenTypeConst
+
+	if token.Type == lexer.TokenTypeOpenBrace || token.Type == lexer.TokenTypeOpenBracket {
+		return p.parseDestructuringDeclaration(start, isConstant)
+	}
+
+	_, err := p.expect(lexer.TokenTypeIdentifier, "Expected identifier name following let or const keywords")
+	if err != nil {
+		return nil, err
+	}
+
+	annotation, err := p.parseTypeAnnotation()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.at().Type == lexer.TokenTypeSemicolon {
+		p.next()
+		if isConstant {
+			return nil, diag.NewCustomError("Must assign value to constant experssion, no value provided").AddSpan(start, p.last)
+		}
+
+		return &ast.VariableDeclaration{
+			Stmt:           p.span(ast.NodeTypeVariableDeclaration, start),
+			Identifier:     token.Value,
+			Constant:       false,
+			TypeAnnotation: annotation,
+		}, nil
+
+	}
+
+	_, err = p.expect(lexer.TokenTypeEquals, "Expected equals token following identifier in var declaration")
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeSemicolon, "Variable declaration must end with semilolon")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.VariableDeclaration{
+		Stmt:           p.span(ast.NodeTypeVariableDeclaration, start),
+		Value:          expr,
+		Identifier:     token.Value,
+		Constant:       isConstant,
+		TypeAnnotation: annotation,
+	}, nil
+}
+
+func (p *Parser) parseDestructuringDeclaration(start int, isConstant bool) (ast.Stmter, *diag.CustomError) {
+	pattern, err := p.parsePattern()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeEquals, "Destructuring declaration must be initialised")
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeSemicolon, "Variable declaration must end with semilolon")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.VariableDeclaration{
+		Stmt:     p.span(ast.NodeTypeVariableDeclaration, start),
+		Value:    expr,
+		Pattern:  pattern,
+		Constant: isConstant,
+	}, nil
+}
+
+// parsePattern parses a binding target, an identifier or a destructuring
+// pattern of objects `{ a, b: { c }, d = 1 }` and arrays `[x, y = 2]`.
+func (p *Parser) parsePattern() (ast.Stmter, *diag.CustomError) {
+	switch p.at().Type {
+	case lexer.TokenTypeIdentifier:
+		token := p.next()
+		return &ast.Identifier{Stmt: p.span(ast.NodeTypeIdentifier, token.Pos), Symbol: token.Value}, nil
+	case lexer.TokenTypeOpenBrace:
+		return p.parseObjectPattern(p.parsePattern)
+	case lexer.TokenTypeOpenBracket:
+		return p.parseArrayPattern(p.parsePattern)
+	default:
+		return nil, diag.NewCustomError("Expected identifier or destructuring pattern").AddTrace(p.at().Pos)
+	}
+}
+
+// parseMatchPattern parses the pattern of a match arm, like a destructuring
+// pattern but string and number literals, true, false and null compare
+// against the value instead of binding, and `_` matches anything.
+func (p *Parser) parseMatchPattern() (ast.Stmter, *diag.CustomError) {
+	switch p.at().Type {
+	case lexer.TokenTypeString, lexer.TokenTypeNumber:
+		return p.parsePrimaryExpr()
+	case lexer.TokenTypeOpenBrace:
+		return p.parseObjectPattern(p.parseMatchPattern)
+	case lexer.TokenTypeOpenBracket:
+		return p.parseArrayPattern(p.parseMatchPattern)
+	default:
+		return p.parsePattern()
+	}
+}
+
+func (p *Parser) parseObjectPattern(parseElement func() (ast.Stmter, *diag.CustomError)) (ast.Stmter, *diag.CustomError) {
+	start := p.next().Pos
+
+	var elements []*ast.PatternElement
+	for {
+		if p.eof() || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+
+		t, err := p.expect(lexer.TokenTypeIdentifier, "Property name expected in object pattern")
+		if err != nil {
+			return nil, err
+		}
+
+		element := &ast.PatternElement{
+			Key:    t.Value,
+			Target: &ast.Identifier{Stmt: ast.NewStmt(ast.NodeTypeIdentifier, t.Pos, t.End), Symbol: t.Value},
+		}
+
+		if p.at().Type == lexer.TokenTypeColon {
+			p.next()
+			element.Target, err = parseElement()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		element.DefaultValue, err = p.parsePatternDefault()
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, element)
+
+		if p.at().Type != lexer.TokenTypeCloseBrace {
+			_, err := p.expect(lexer.TokenTypeComma, "Expected comma or closing brace following pattern property")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err := p.expect(lexer.TokenTypeCloseBrace, "Object pattern missing closing brace")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.DestructuringPattern{Stmt: p.span(ast.NodeTypeObjectPattern, start), Elements: elements}, nil
+}
+
+func (p *Parser) parseArrayPattern(parseElement func() (ast.Stmter, *diag.CustomError)) (ast.Stmter, *diag.CustomError) {
+	start := p.next().Pos
+
+	var elements []*ast.PatternElement
+	for {
+		if p.eof() || p.at().Type == lexer.TokenTypeCloseBracket {
+			break
+		}
+
+		target, err := parseElement()
+		if err != nil {
+			return nil, err
+		}
+
+		element := &ast.PatternElement{Target: target}
+		element.DefaultValue, err = p.parsePatternDefault()
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, element)
+
+		if p.at().Type != lexer.TokenTypeCloseBracket {
+			_, err := p.expect(lexer.TokenTypeComma, "Expected comma or closing bracket following pattern element")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err := p.expect(lexer.TokenTypeCloseBracket, "Array pattern missing closing bracket")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.DestructuringPattern{Stmt: p.span(ast.NodeTypeArrayPattern, start), Elements: elements}, nil
+}
+
+func (p *Parser) parsePatternDefault() (ast.Stmter, *diag.CustomError) {
+	if p.at().Type != lexer.TokenTypeEquals {
+		return nil, nil
+	}
+
+	p.next()
+
+	return p.parseObjectExpr()
+}
+
+// parseParameters returns the parameters and their type annotations, nil
+// for the parameters without one. Destructuring parameters cannot be annotated.
+func (p *Parser) parseParameters() ([]ast.Stmter, []*ast.TypeAnnotation, *diag.CustomError) {
+	_, err := p.expect(lexer.TokenTypeOpenParen, "Expected open parantesis")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var params []ast.Stmter
+	var types []*ast.TypeAnnotation
+	for {
+		if p.eof() || p.at().Type == lexer.TokenTypeCloseParen {
+			break
+		}
+
+		param, err := p.parsePattern()
+		if err != nil {
+			return nil, nil, err
+		}
+		params = append(params, param)
+
+		var annotation *ast.TypeAnnotation
+		if param.Kind() == ast.NodeTypeIdentifier {
+			annotation, err = p.parseTypeAnnotation()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		types = append(types, annotation)
+
+		if p.at().Type != lexer.TokenTypeCloseParen {
+			_, err := p.expect(lexer.TokenTypeComma, "Expected comma or closing parenthesis following parameter")
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseParen, "Missing closing parenthesis inside parameter list")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return params, types, nil
+}
+
+// parseTypeAnnotation parses `: type` when the next token is a colon,
+// otherwise there is no annotation and nil is returned.
+func (p *Parser) parseTypeAnnotation() (*ast.TypeAnnotation, *diag.CustomError) {
+	if p.at().Type != lexer.TokenTypeColon {
+		return nil, nil
+	}
+
+	p.next()
+	token := p.next()
+	// fn is a keyword, the other type names are identifiers
+	if token.Type != lexer.TokenTypeIdentifier && token.Type != lexer.TokenTypeFn {
+		return nil, diag.NewCustomError("Expected type name following colon").AddSpan(token.Pos, token.End)
+	}
+
+	name := ast.StaticType(token.Value)
+	if !name.Known() {
+		return nil, diag.NewCustomError(fmt.Sprintf("Unknown type %s, expected one of %s", token.Value, strings.Join(ast.StaticTypeNames(), ", "))).AddSpan(token.Pos, token.End)
+	}
+
+	return &ast.TypeAnnotation{Stmt: p.span(ast.NodeTypeTypeAnnotation, token.Pos), Name: name}, nil
+}
+
+// toPattern turns the left side of an assignment like `[a, b] = [b, a]`
+// parsed as a literal into a destructuring pattern.
+func (p *Parser) toPattern(expr ast.Stmter) (ast.Stmter, *diag.CustomError) {
+	switch node := expr.(type) {
+	case *ast.Identifier:
+		return node, nil
+	case *ast.ArrayLiteral:
+		pattern := &ast.DestructuringPattern{Stmt: ast.NewStmt(ast.NodeTypeArrayPattern, node.Pos(), node.End())}
+		for _, element := range node.Elements {
+			target, defaultValue, err := p.toPatternElement(element)
+			if err != nil {
+				return nil, err
+			}
+			pattern.Elements = append(pattern.Elements, &ast.PatternElement{Target: target, DefaultValue: defaultValue})
+		}
+		return pattern, nil
+	case *ast.ObjectLiteral:
+		pattern := &ast.DestructuringPattern{Stmt: ast.NewStmt(ast.NodeTypeObjectPattern, node.Pos(), node.End())}
+		for _, property := range node.Properties {
+			if property.ComputedKey != nil {
+				return nil, diag.NewCustomError("Computed keys are not allowed in destructuring assignment").AddTrace(property.Pos())
+			}
+
+			var target ast.Stmter = &ast.Identifier{Stmt: ast.NewStmt(ast.NodeTypeIdentifier, property.Pos(), property.End()), Symbol: property.Key}
+			var defaultValue ast.Stmter
+			if property.Value != nil {
+				var err *diag.CustomError
+				target, defaultValue, err = p.toPatternElement(property.Value)
+				if err != nil {
+					return nil, err
+				}
+			}
+			pattern.Elements = append(pattern.Elements, &ast.PatternElement{Key: property.Key, Target: target, DefaultValue: defaultValue})
+		}
+		return pattern, nil
+	case *ast.DestructuringPattern:
+		// A nested pattern with a default, converted as the assignment was parsed
+		return node, nil
+	}
+
+	return nil, diag.NewCustomError("Invalid destructuring assignment target").AddTrace(expr.Pos())
+}
+
+// toPatternElement turns an element of a literal into the target of a
+// pattern, an assignment like the `y = 7` of `[x, y = 7] = [5]` gives the
+// default value of the target.
+func (p *Parser) toPatternElement(element ast.Stmter) (ast.Stmter, ast.Stmter, *diag.CustomError) {
+	var defaultValue ast.Stmter
+	if assignment, ok := element.(*ast.AssignmentExpr); ok {
+		element, defaultValue = assignment.Assigne, assignment.Value
+	}
+
+	target, err := p.toPattern(element)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return target, defaultValue, nil
+}
+
+func (p *Parser) parseFunctionDeclaration() (ast.Stmter, *diag.CustomError) {
+	start := p.next().Pos
+	token, err := p.expect(lexer.TokenTypeIdentifier, "Expected function name following fn keyword")
+	if err != nil {
+		return nil, err
+	}
+
+	name := token.Value
+
+	params, paramTypes, err := p.parseParameters()
+	if err != nil {
+		return nil, err
+	}
+
+	returnType, err := p.parseTypeAnnotation()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeOpenBrace, "Expected function body declaration ")
+	if err != nil {
+		return nil, err
+	}
+
+	var body []ast.Stmter
+
+	for {
+		if p.at().Type == lexer.TokenTypeEOF || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+		s := p.parseBlockStmt()
+		if s == nil {
+			continue
+		}
+
+		body = append(body, s)
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseBrace, "Closing brace expected inside function declaration")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.FunctionDeclaration{
+		Stmt:           p.span(ast.NodeTypeFunctionDeclaration, start),
+		Parameters:     params,
+		ParameterTypes: paramTypes,
+		ReturnType:     returnType,
+		Name:           name,
+		Body:           body,
+	}, nil
+}
+
+func (p *Parser) parseIfExpression() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	tType := p.next().Type
+	var cond ast.Stmter
+	var err *diag.CustomError
+
+	if tType != lexer.TokenTypeElse {
+		_, err := p.expect(lexer.TokenTypeOpenParen, "Open parenthesis expected after if statement")
+		if err != nil {
+			return nil, err
+		}
+
+		cond, err = p.parseConditionalExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = p.expect(lexer.TokenTypeCloseParen, "Close parenthesis expected after if statement conditions")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = p.expect(lexer.TokenTypeOpenBrace, "Expected open brace after if condition")
+	if err != nil {
+		return nil, err
+	}
+
+	var body []ast.Stmter
+
+	for {
+		if p.at().Type == lexer.TokenTypeEOF || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+		s := p.parseBlockStmt()
+		if s == nil {
+			continue
+		}
+
+		body = append(body, s)
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseBrace, "Closing brace expected inside function declaration")
+	if err != nil {
+		return nil, err
+	}
+
+	var elseExpression ast.Stmter
+
+	if p.at().Type == lexer.TokenTypeElse || p.at().Type == lexer.TokenTypeElseIf {
+		elseExpression, err = p.parseIfExpression()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ast.IfExpression{
+		Stmt:           p.span(ast.NodeTypeIfExpression, start),
+		Condition:      cond,
+		Body:           body,
+		ElseExpression: elseExpression,
+	}, nil
+}
+
+func (p *Parser) parseForExpression() (ast.Stmter, *diag.CustomError) {
+	// @TODO refactor to decrease complexity, factor out syntax variations phraser
+	var err *diag.CustomError
+	var afterCondition, declaration, condition, incrementalExpression ast.Stmter
+	start := p.next().Pos
+
+	if p.at().Type != lexer.TokenTypeOpenBrace {
+		_, err = p.expect(lexer.TokenTypeOpenParen, "Open parenthesis expected after for statement")
+		if err != nil {
+			return nil, err
+		}
+
+		if p.isForEach() {
+			return p.parseForEachExpression(start)
+		}
+
+		parCount := p.countFor(lexer.TokenTypeSemicolon)
+
+		if parCount == 0 {
+			condition, err = p.parseConditionalExpr()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if parCount > 0 {
+			declaration, err = p.parseVarDeclaration()
+			if err != nil {
+				return nil, err
+			}
+
+			condition, err = p.parseConditionalExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = p.expect(lexer.TokenTypeSemicolon, "Semicolon expected after for variable condition")
+			if err != nil {
+				return nil, err
+			}
+
+			incrementalExpression, err = p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err := p.expect(lexer.TokenTypeCloseParen, "Close parenthesis expected after for statement conditions")
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = p.expect(lexer.TokenTypeOpenBrace, "Expected open brace after if condition")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.next()
+	}
+
+	var body []ast.Stmter
+
+	for {
+		if p.at().Type == lexer.TokenTypeEOF || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+		s := p.parseBlockStmt()
+		if s == nil {
+			continue
+		}
+
+		body = append(body, s)
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseBrace, "Closing brace expected inside function declaration")
+	if err != nil {
+		return nil, err
+	}
+
+	if p.at().Type == lexer.TokenTypeOpenParen {
+		p.next()
+		afterCondition, err = p.parseConditionalExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = p.expect(lexer.TokenTypeCloseParen, "Close parenthesis expected after if statement closing conditions")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ast.ForExpression{
+		Stmt:                  p.span(ast.NodeTypeForExpression, start),
+		Declaration:           declaration,
+		Condition:             condition,
+		AfterCondition:        afterCondition,
+		IncrementalExpression: incrementalExpression,
+		Body:                  body,
+	}, nil
+}
+
+// isForEach looks ahead for `let item of` or `let key in`, of and in are
+// not reserved words so they are only recognised in this position.
+func (p *Parser) isForEach() bool {
+	if p.index+2 >= len(p.tokens) {
+		return false
+	}
+
+	declaration := p.tokens[p.index]
+	variable := p.tokens[p.index+1]
+	iteration := p.tokens[p.index+2]
+
+	return (declaration.Type == lexer.TokenTypeLet || declaration.Type == lexer.TokenTypeConst) &&
+		variable.Type == lexer.TokenTypeIdentifier &&
+		iteration.Type == lexer.TokenTypeIdentifier &&
+		(iteration.Value == "of" || iteration.Value == "in")
+}
+
+func (p *Parser) parseForEachExpression(start int) (ast.Stmter, *diag.CustomError) {
+	isConstant := p.next().Type == lexer.TokenTypeConst
+	identifier := p.next().Value
+	iteration := p.next().Value
+
+	iterable, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseParen, "Close parenthesis expected after for "+iteration+" collection")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeOpenBrace, "Expected open brace after for "+iteration+" collection")
+	if err != nil {
+		return nil, err
+	}
+
+	var body []ast.Stmter
+
+	for {
+		if p.at().Type == lexer.TokenTypeEOF || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+		s := p.parseBlockStmt()
+		if s == nil {
+			continue
+		}
+
+		body = append(body, s)
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseBrace, "Closing brace expected after for "+iteration+" body")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.ForEachExpression{
+		Stmt:       p.span(ast.NodeTypeForEachExpression, start),
+		Identifier: identifier,
+		Constant:   isConstant,
+		Keys:       iteration == "in",
+		Iterable:   iterable,
+		Body:       body,
+	}, nil
+}
+
+func (p *Parser) parseSwitchExpression() (ast.Stmter, *diag.CustomError) {
+	start := p.next().Pos
+	_, err := p.expect(lexer.TokenTypeOpenParen, "Open parenthesis expected after switch")
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseParen, "Close parenthesis expected after switch statement value definition")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeOpenBrace, "Open brace expected after switch condition")
+	if err != nil {
+		return nil, err
+	}
+
+	var body []ast.SwitchCaseExpression
+	for {
+		if p.at().Type == lexer.TokenTypeEOF || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+
+		if p.at().Type != lexer.TokenTypeCase && p.at().Type != lexer.TokenTypeDefault {
+			return nil, diag.NewCustomError("Expected case or default inside switch").AddTrace(p.at().Pos)
+		}
+
+		var condition *ast.CaseCondition
+		casePos := p.at().Pos
+		if p.next().Type == lexer.TokenTypeDefault {
+			condition = &ast.CaseCondition{Wildcard: true}
+		} else {
+			condition, err = p.parseCaseCondition(lexer.TokenTypeColon)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, err := p.expect(lexer.TokenTypeColon, "Colon expected after case value")
+		if err != nil {
+			return nil, err
+		}
+
+		var swBody []ast.Stmter
+
+		for {
+			if p.at().Type == lexer.TokenTypeEOF || p.at().Type == lexer.TokenTypeCase || p.at().Type == lexer.TokenTypeDefault || p.at().Type == lexer.TokenTypeCloseBrace {
+				break
+			}
+
+			s := p.parseBlockStmt()
+			if s == nil {
+				continue
+			}
+
+			swBody = append(swBody, s)
+
+		}
+		body = append(body, ast.SwitchCaseExpression{Condition: condition, Body: swBody, Pos: casePos})
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseBrace, "Closing brace expected after switch cases")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.SwitchExpression{
+		Stmt:  p.span(ast.NodeTypeSwitchExpression, start),
+		Value: v,
+		Body:  body,
+	}, nil
+}
+
+// parseCaseCondition parses what follows case in a switch or starts a match
+// arm, up to the terminator: `_`, a comma separated list of expressions,
+// a binding `name if guard` or in match arms an object or array pattern.
+// Expression lists and patterns can have a guard too.
+func (p *Parser) parseCaseCondition(terminator lexer.TokenType) (*ast.CaseCondition, *diag.CustomError) {
+	condition := &ast.CaseCondition{}
+
+	if p.at().Type == lexer.TokenTypeIdentifier && p.at().Value == "_" && p.tokens[p.index+1].Type == terminator {
+		p.next()
+		condition.Wildcard = true
+		return condition, nil
+	}
+
+	isStructure := p.at().Type == lexer.TokenTypeOpenBrace || p.at().Type == lexer.TokenTypeOpenBracket
+
+	if isStructure && terminator == lexer.TokenTypeArrow {
+		pattern, err := p.parseMatchPattern()
+		if err != nil {
+			return nil, err
+		}
+		condition.Pattern = pattern
+	} else if p.at().Type == lexer.TokenTypeIdentifier && p.tokens[p.index+1].Type == lexer.TokenTypeIf {
+		condition.Binding = p.next().Value
+	} else {
+		for {
+			value, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			condition.Values = append(condition.Values, value)
+
+			if p.at().Type != lexer.TokenTypeComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.at().Type == lexer.TokenTypeIf {
+		p.next()
+		guard, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		condition.Guard = guard
+	}
+
+	return condition, nil
+}
+
+// parseMatchExpr parses the value producing form of switch:
+//
+//	match (value) {
+//	    1, 2 => "small",
+//	    n if n > 10 => "big",
+//	    {type: "order", id} => id,
+//	    [x, y] => x + y,
+//	    _ => "other",
+//	}
+func (p *Parser) parseMatchExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.next().Pos
+	_, err := p.expect(lexer.TokenTypeOpenParen, "Open parenthesis expected after match")
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseParen, "Close parenthesis expected after match value")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeOpenBrace, "Open brace expected after match value")
+	if err != nil {
+		return nil, err
+	}
+
+	var arms []*ast.MatchArm
+	for {
+		if p.eof() || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+
+		pos := p.at().Pos
+		condition, err := p.parseCaseCondition(lexer.TokenTypeArrow)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = p.expect(lexer.TokenTypeArrow, "Expected => following match pattern")
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		arms = append(arms, &ast.MatchArm{Condition: condition, Body: result, Pos: pos})
+
+		if p.at().Type != lexer.TokenTypeCloseBrace {
+			_, err := p.expect(lexer.TokenTypeComma, "Expected comma or closing brace following match arm")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseBrace, "Closing brace expected after match arms")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.MatchExpression{
+		Stmt:  p.span(ast.NodeTypeMatchExpression, start),
+		Value: v,
+		Arms:  arms,
+	}, nil
+}
+
+func (p *Parser) parseImportDeclaration() (ast.Stmter, *diag.CustomError) {
+	pos := p.next().Pos
+
+	if p.at().Type == lexer.TokenTypeString {
+		path := p.next().Value
+		_, err := p.expect(lexer.TokenTypeSemicolon, "Import declaration must end with semicolon")
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.ImportDeclaration{
+			Stmt:      p.span(ast.NodeTypeImportDeclaration, pos),
+			Namespace: strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".gl"),
+			Path:      path,
+		}, nil
+	}
+
+	_, err := p.expect(lexer.TokenTypeOpenBrace, "Expected open brace following import")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []ast.ImportName
+	for {
+		if p.eof() || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+
+		t, err := p.expect(lexer.TokenTypeIdentifier, "Expected name to import")
+		if err != nil {
+			return nil, err
+		}
+
+		name := ast.ImportName{Name: t.Value, Alias: t.Value}
+		if p.at().Type == lexer.TokenTypeIdentifier && p.at().Value == "as" {
+			p.next()
+			alias, err := p.expect(lexer.TokenTypeIdentifier, "Expected alias name following as")
+			if err != nil {
+				return nil, err
+			}
+			name.Alias = alias.Value
+		}
+		names = append(names, name)
+
+		if p.at().Type != lexer.TokenTypeCloseBrace {
+			_, err := p.expect(lexer.TokenTypeComma, "Expected comma or closing brace following imported name")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseBrace, "Import list missing closing brace")
+	if err != nil {
+		return nil, err
+	}
+
+	from := p.next()
+	if from.Type != lexer.TokenTypeIdentifier || from.Value != "from" {
+		return nil, diag.NewCustomError("Expected from following import list").AddTrace(from.Pos)
+	}
+
+	path, err := p.expect(lexer.TokenTypeString, "Expected module path following from")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.expect(lexer.TokenTypeSemicolon, "Import declaration must end with semicolon")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.ImportDeclaration{
+		Stmt:  p.span(ast.NodeTypeImportDeclaration, pos),
+		Names: names,
+		Path:  path.Value,
+	}, nil
+}
+
+func (p *Parser) parseExportDeclaration() (ast.Stmter, *diag.CustomError) {
+	pos := p.next().Pos
+
+	var declaration ast.Stmter
+	var err *diag.CustomError
+	switch p.at().Type {
+	case lexer.TokenTypeLet, lexer.TokenTypeConst:
+		declaration, err = p.parseVarDeclaration()
+	case lexer.TokenTypeFn:
+		declaration, err = p.parseFunctionDeclaration()
+	default:
+		return nil, diag.NewCustomError("Expected let, const or fn following export").AddTrace(p.at().Pos)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.ExportDeclaration{
+		Stmt:        p.span(ast.NodeTypeExportDeclaration, pos),
+		Declaration: declaration,
+	}, nil
+}
+
+func (p *Parser) parseBreakExpression() (ast.Stmter, *diag.CustomError) {
+	start := p.next().Pos
+	return &ast.BreakExpression{
+		Stmt: p.span(ast.NodeTypeBreakExpression, start),
+	}, nil
+}
+
+func (p *Parser) parseContinueExpression() (ast.Stmter, *diag.CustomError) {
+	start := p.next().Pos
+	return &ast.ContinueExpression{
+		Stmt: p.span(ast.NodeTypeContinueExpression, start),
+	}, nil
+}
+
+func (p *Parser) parseExpr() (ast.Stmter, *diag.CustomError) {
+	return p.parseConditionalExpr()
+}
+
+func (p *Parser) parseConditionalExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	left, err := p.parseAssignmentExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.at().Type == lexer.TokenTypeSmaller ||
+		p.at().Type == lexer.TokenTypeSmallerEqual ||
+		p.at().Type == lexer.TokenTypeGreater ||
+		p.at().Type == lexer.TokenTypeGreaterEqual ||
+		p.at().Type == lexer.TokenTypeDoubeEqual ||
+		p.at().Type == lexer.TokenTypeNotEqual ||
+		p.at().Type == lexer.TokenTypeAnd ||
+		p.at().Type == lexer.TokenTypeOr ||
+		p.at().Type == lexer.TokenTypeNot {
+		operator := p.next().Value
+		right, err := p.parseAssignmentExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.ConditionDeclaration{
+			Stmt:     p.span(ast.NodeTypeConditionExpression, start),
+			Left:     left,
+			Right:    right,
+			Operator: operator,
+		}, nil
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseAssignmentExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	left, err := p.parseObjectExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.at().Type == lexer.TokenTypeEquals {
+		p.next()
+		value, err := p.parseObjectExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		if left.Kind() == ast.NodeTypeArrayLiteral || left.Kind() == ast.NodeTypeObjectLiteral {
+			left, err = p.toPattern(left)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &ast.AssignmentExpr{Stmt: p.span(ast.NodeTypeAssigmentExpression, start), Value: value, Assigne: left}, nil
+
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseObjectExpr() (ast.Stmter, *diag.CustomError) {
+
+	if p.at().Type != lexer.TokenTypeOpenBrace {
+		return p.parseBitwiseOrExpr()
+	}
+
+	start := p.next().Pos
+
+	var properties []*ast.Property
+
+	for {
+		if p.eof() || p.at().Type == lexer.TokenTypeCloseBrace {
+			break
+		}
+
+		var key string
+		var computedKey ast.Stmter
+		var err *diag.CustomError
+		keyPos := p.at().Pos
+
+		switch p.at().Type {
+		case lexer.TokenTypeOpenBracket:
+			p.next()
+			computedKey, err = p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = p.expect(lexer.TokenTypeCloseBracket, "Missing closing bracket following computed object key")
+			if err != nil {
+				return nil, err
+			}
+		case lexer.TokenTypeString, lexer.TokenTypeNumber:
+			computedKey, err = p.parsePrimaryExpr()
+			if err != nil {
+				return nil, err
+			}
+		default:
+			t, err := p.expect(lexer.TokenTypeIdentifier, "Object literal key expected")
+			if err != nil {
+				return nil, err
+			}
+			key = t.Value
+		}
+
+		if computedKey != nil && p.at().Type != lexer.TokenTypeColon {
+			return nil, diag.NewCustomError("Missing colon following computed or quoted object key").AddTrace(p.at().Pos)
+		}
+
+		if p.at().Type == lexer.TokenTypeComma {
+			properties = append(properties, &ast.Property{Stmt: p.span(ast.NodeTypeProperty, keyPos), Key: key})
+			p.next()
+			continue
+		}
+
+		if p.at().Type == lexer.TokenTypeCloseBrace {
+			properties = append(properties, &ast.Property{Stmt: p.span(ast.NodeTypeProperty, keyPos), Key: key})
+			continue
+		}
+
+		_, err = p.expect(lexer.TokenTypeColon, "Missing colon following in object expression")
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		properties = append(properties, &ast.Property{Stmt: p.span(ast.NodeTypeProperty, keyPos), Key: key, ComputedKey: computedKey, Value: value})
+
+		if p.at().Type != lexer.TokenTypeCloseBrace {
+			_, err := p.expect(lexer.TokenTypeComma, "Expected comma or closing bracket following property")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	}
+
+	_, err := p.expect(lexer.TokenTypeCloseBrace, "Objects literal missing closing brace.")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.ObjectLiteral{Stmt: p.span(ast.NodeTypeObjectLiteral, start), Properties: properties}, nil
+}
+
+func (p *Parser) parseBitwiseOrExpr() (ast.Stmter, *diag.CustomError) {
+	return p.parseBinaryLevel(p.parseBitwiseXorExpr, "|")
+}
+
+func (p *Parser) parseBitwiseXorExpr() (ast.Stmter, *diag.CustomError) {
+	return p.parseBinaryLevel(p.parseBitwiseAndExpr, "^")
+}
+
+func (p *Parser) parseBitwiseAndExpr() (ast.Stmter, *diag.CustomError) {
+	return p.parseBinaryLevel(p.parseShiftExpr, "&")
+}
+
+func (p *Parser) parseShiftExpr() (ast.Stmter, *diag.CustomError) {
+	return p.parseBinaryLevel(p.parseAdditiveExpr, "<<", ">>")
+}
+
+// parseBinaryLevel parses a left associative chain of binary operators
+// sharing the same precedence, operands are parsed by the next level.
+func (p *Parser) parseBinaryLevel(next func() (ast.Stmter, *diag.CustomError), operators ...string) (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isBinaryOperator(operators...) {
+		operator := p.next().Value
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryExpession{
+			Stmt:     p.span(ast.NodeTypeBinaryExpession, start),
+			Left:     left,
+			Right:    right,
+			Operator: operator,
+		}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) isBinaryOperator(operators ...string) bool {
+	if p.at().Type != lexer.TokenTypeBinaryOperator {
+		return false
+	}
+
+	for _, operator := range operators {
+		if p.at().Value == operator {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Parser) parseAdditiveExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	left, err := p.parseMultiplicativeExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		v := p.at().Value
+		if v == "+" || v == "-" {
+			operator := p.next().Value
+			right, err := p.parseMultiplicativeExpr()
+			if err != nil {
+				return nil, err
+			}
+			left = &ast.BinaryExpession{
+				Stmt:     p.span(ast.NodeTypeBinaryExpession, start),
+				Left:     left,
+				Right:    right,
+				Operator: operator,
+			}
+			continue
+		}
+		break
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseMultiplicativeExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	left, err := p.parseExponentExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		v := p.at().Value
+		if v == "/" || v == "*" || v == "%" || v == "~/" {
+			operator := p.next().Value
+			right, err := p.parseExponentExpr()
+			if err != nil {
+				return nil, err
+			}
+			left = &ast.BinaryExpession{
+				Stmt:     p.span(ast.NodeTypeBinaryExpession, start),
+				Left:     left,
+				Right:    right,
+				Operator: operator,
+			}
+			continue
+		}
+		break
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseExponentExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	left, err := p.parseUnaryExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isBinaryOperator("**") {
+		operator := p.next().Value
+		// Exponentiation is right associative, 2 ** 3 ** 2 is 2 ** (3 ** 2)
+		right, err := p.parseExponentExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.BinaryExpession{
+			Stmt:     p.span(ast.NodeTypeBinaryExpession, start),
+			Left:     left,
+			Right:    right,
+			Operator: operator,
+		}, nil
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseUnaryExpr() (ast.Stmter, *diag.CustomError) {
+	if p.isBinaryOperator("~") {
+		start := p.at().Pos
+		operator := p.next().Value
+		operand, err := p.parseUnaryExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		return &ast.UnaryExpression{
+			Stmt:     p.span(ast.NodeTypeUnaryExpression, start),
+			Operand:  operand,
+			Operator: operator,
+		}, nil
+	}
+
+	return p.parseCallMemberExpr()
+}
+
+func (p *Parser) parseCallMemberExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	member, err := p.parseMemberExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.at().Type == lexer.TokenTypeOpenParen {
+		return p.parseCallExpr(member, start)
+	}
+
+	return member, nil
+}
+
+// parseCallExpr parses the arguments of a call of caller, start is where
+// the caller begins, before the parentheses around it.
+func (p *Parser) parseCallExpr(caller ast.Stmter, start int) (ast.Stmter, *diag.CustomError) {
+
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	callExpr := &ast.CallExpression{
+		Stmt:   p.span(ast.NodeTypeCallExpression, start),
+		Caller: caller,
+		Args:   args,
+	}
+
+	if p.at().Type == lexer.TokenTypeOpenBrace {
+
+		e, err := p.parseCallExpr(callExpr, start)
+		if err != nil {
+			return nil, err
+
+		}
+		callExpr = e.(*ast.CallExpression)
+	}
+
+	return callExpr, nil
+}
+
+func (p *Parser) parseMemberExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.at().Pos
+	object, err := p.parsePrimaryExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if p.at().Type != lexer.TokenTypeDot && p.at().Type != lexer.TokenTypeOpenBracket {
+			break
+		}
+
+		operator := p.next()
+
+		var property ast.Stmter
+		var computed bool
+
+		if operator.Type == lexer.TokenTypeDot {
+			computed = false
+			property, err = p.parsePrimaryExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			if property.Kind() != ast.NodeTypeIdentifier {
+				return nil, diag.NewCustomError("Cannot use operatior without right hand side being an identifier").AddSpan(property.Pos(), property.End())
+			}
+		} else {
+			computed = true
+
+			property, err = p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			_, err := p.expect(lexer.TokenTypeCloseBracket, "Missing cosing bracket in computed value")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		object = &ast.MemberExpression{
+			Stmt:     p.span(ast.NodeTypeMemberExpression, start),
+			Object:   object,
+			Propert:  property,
+			Computed: computed,
+		}
+	}
+
+	return object, nil
+}
+
+func (p *Parser) parseArgs() ([]*ast.Stmter, *diag.CustomError) {
+	var err *diag.CustomError
+	_, err = p.expect(lexer.TokenTypeOpenParen, "Expected open parantesis")
+	if err != nil {
+		return nil, err
+	}
+	var args []*ast.Stmter
+
+	if p.at().Type != lexer.TokenTypeCloseParen {
+		args, err = p.parseArgumentsLists()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = p.expect(lexer.TokenTypeCloseParen, "Missing closing parenthesis inside arguement list")
+	if err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+func (p *Parser) parseArgumentsLists() ([]*ast.Stmter, *diag.CustomError) {
+	var args []*ast.Stmter
+	arg, err := p.parseAssignmentExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, &arg)
+
+	for {
+		if p.at().Type != lexer.TokenTypeComma {
+			break
+		}
+
+		p.next()
+		fArg, err := p.parseAssignmentExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, &fArg)
+	}
+
+	return args, nil
+}
+
+func (p *Parser) parsePrimaryExpr() (ast.Stmter, *diag.CustomError) {
+	tk := p.at().Type
+	pos := p.at().Pos
+
+	switch tk {
+	case lexer.TokenTypeIdentifier:
+		token := p.next()
+		return &ast.Identifier{Stmt: p.span(ast.NodeTypeIdentifier, token.Pos), Symbol: token.Value}, nil
+	case lexer.TokenTypeNumber:
+		token := p.next()
+		if p.isIntegerLiteral(token.Value) {
+			value, err := strconv.ParseInt(token.Value, 10, 64)
+			if err != nil {
+				return nil, diag.NewCustomError(fmt.Sprintf("Integer literal %s is out of range", token.Value)).AddSpan(pos, token.End)
+			}
+			return &ast.IntegerLiteral{Stmt: p.span(ast.NodeTypeIntegerLiteral, pos), Value: value}, nil
+		}
+
+		value, err := strconv.ParseFloat(token.Value, 64)
+		if err != nil {
+			return nil, diag.NewCustomError(err.Error()).AddSpan(pos, token.End)
+		}
+		return &ast.NumericLiteral{Stmt: p.span(ast.NodeTypeNumericLiteral, pos), Value: value}, nil
+	case lexer.TokenTypeString:
+		token := p.next()
+		return &ast.StringLiteral{Stmt: p.span(ast.NodeTypeStringLIteral, pos), Value: token.Value}, nil
+	case lexer.TokenTypeOpenBracket:
+		return p.parseArrayExpr()
+	case lexer.TokenTypeMatch:
+		return p.parseMatchExpr()
+	case lexer.TokenTypeOpenParen:
+		p.next()
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(lexer.TokenTypeCloseParen, "Unexpected token found inside parenthesised expression, expected closing parenthesis")
+		if err != nil {
+			return nil, err
+		}
+
+		return value, nil
+	default:
+		if p.eof() {
+			return nil, diag.NewCustomError("Unexpected end of file, expression expected").AddTrace(pos)
+		}
+		return nil, diag.NewCustomError(fmt.Sprintf("Unexpected token %q, expression expected", p.at().Value)).AddTrace(pos)
+	}
+}
+
+func (p *Parser) parseArrayExpr() (ast.Stmter, *diag.CustomError) {
+	start := p.next().Pos
+
+	var elements []ast.Stmter
+	for {
+		if p.eof() || p.at().Type == lexer.TokenTypeCloseBracket {
+			break
+		}
+
+		element, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+
+		if p.at().Type != lexer.TokenTypeCloseBracket {
+			_, err := p.expect(lexer.TokenTypeComma, "Expected comma or closing bracket following array element")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	_, err := p.expect(lexer.TokenTypeCloseBracket, "Array literal missing closing bracket")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ast.ArrayLiteral{Stmt: p.span(ast.NodeTypeArrayLiteral, start), Elements: elements}, nil
+}
+
+func (p *Parser) isIntegerLiteral(value string) bool {
+	return !strings.ContainsAny(value, ".eE")
+}
+
+func (p *Parser) countFor(divider lexer.TokenType) int {
+	i := p.index
+	c := 0
+
+	for {
+		if p.tokens[i].Type == lexer.TokenTypeEOF || p.tokens[i].Type == lexer.TokenTypeCloseParen {
+			break
+		}
+
+		if p.tokens[i].Type == divider {
+			c++
+		}
+
+		i++
+	}
+
+	return c
+}
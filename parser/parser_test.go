@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"aolbrich/lexer/diag"
+)
+
+// parseErrors returns the syntax errors of source, with the source from
+// where each one points to.
+func parseErrors(t *testing.T, source string) ([]*diag.CustomError, []string) {
+	t.Helper()
+
+	_, err := NewParser().ProduceAST(source)
+	if err == nil {
+		t.Fatalf("expected syntax errors in %q", source)
+	}
+
+	errs := err.Errors
+	if errs == nil {
+		errs = []*diag.CustomError{err}
+	}
+
+	var texts []string
+	for _, e := range errs {
+		span := e.Trace[0]
+		texts = append(texts, source[span.Pos:])
+	}
+
+	return errs, texts
+}
+
+func TestRecoverKeepsNextStatement(t *testing.T) {
+	source := strings.Join([]string{
+		"let a = 1;",
+		"let b = 2",
+		"fn f() {",
+		"    let y = (;",
+		"}",
+	}, "\n")
+
+	errs, texts := parseErrors(t, source)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+
+	expected := []struct {
+		message string
+		text    string
+	}{
+		{"Variable declaration must end with semilolon", "fn"},
+		{`Unexpected token ";", expression expected`, ";"},
+	}
+	for index, e := range expected {
+		if errs[index].Message != e.message || !strings.HasPrefix(texts[index], e.text) {
+			t.Errorf("error %d: expected %q at %q, got %q at %.10q", index, e.message, e.text, errs[index].Message, texts[index])
+		}
+	}
+}
+
+func TestExpectLeavesMismatchedToken(t *testing.T) {
+	// The missing semicolons must not hide the declarations following them
+	source := "let a = 1\nlet b = (;\nconst c = 3\nlet d = ];"
+
+	errs, _ := parseErrors(t, source)
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestRecoverAtNextLine(t *testing.T) {
+	tests := []struct {
+		source string
+		lines  []string
+	}{
+		// Calls and assignments usually end with their line
+		{"println(1 +)\nprintln(2 +)\nprintln(3 +)", []string{")\nprintln(2", ")\nprintln(3", ")"}},
+		// A stray semicolon is skipped alone
+		{"x = 1;\ny = 2;\nz = 3;\nw = 4;", []string{";\ny", ";\nz", ";\nw", ";"}},
+		// Lines inside the parentheses of the failed statement are skipped
+		{"println(1 +,\n    2)\nx = ]", []string{",\n", "]"}},
+	}
+
+	for _, test := range tests {
+		errs, texts := parseErrors(t, test.source)
+		if len(errs) != len(test.lines) {
+			t.Errorf("%q: expected %d errors, got %d: %v", test.source, len(test.lines), len(errs), errs)
+			continue
+		}
+		for index, line := range test.lines {
+			if !strings.HasPrefix(texts[index], line) {
+				t.Errorf("%q: error %d at %q, expected at %q", test.source, index, texts[index], line)
+			}
+		}
+	}
+}
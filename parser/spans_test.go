@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"aolbrich/lexer/ast"
+)
+
+var stmterType = reflect.TypeOf((*ast.Stmter)(nil)).Elem()
+
+// collectSpans walks the nodes under value and lists every node as its kind
+// and the source it spans, checking each lies within the node holding it.
+func collectSpans(t *testing.T, source []rune, value reflect.Value, parent ast.Stmter, spans map[string]bool) {
+	switch value.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if value.IsNil() {
+			return
+		}
+		if value.Type().Implements(stmterType) {
+			if node, ok := value.Interface().(ast.Stmter); ok && value.Kind() == reflect.Ptr {
+				if node.Pos() < 0 || node.Pos() > node.End() || node.End() > len(source) {
+					t.Errorf("%s has an invalid span %d-%d", node.Kind(), node.Pos(), node.End())
+					return
+				}
+				if parent != nil && (node.Pos() < parent.Pos() || node.End() > parent.End()) {
+					t.Errorf("%s %q is outside of %s %q", node.Kind(), string(source[node.Pos():node.End()]), parent.Kind(), string(source[parent.Pos():parent.End()]))
+				}
+				spans[fmt.Sprintf("%s %s", node.Kind(), string(source[node.Pos():node.End()]))] = true
+				parent = node
+			}
+		}
+		collectSpans(t, source, value.Elem(), parent, spans)
+	case reflect.Struct:
+		for index := 0; index < value.NumField(); index++ {
+			field := value.Type().Field(index)
+			// The embedded Stmt is the span of the node itself
+			if field.Name == "Stmt" || field.PkgPath != "" {
+				continue
+			}
+			collectSpans(t, source, value.Field(index), parent, spans)
+		}
+	case reflect.Slice:
+		for index := 0; index < value.Len(); index++ {
+			collectSpans(t, source, value.Index(index), parent, spans)
+		}
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		source string
+		spans  []string
+	}{
+		{"12", []string{"IntegerLiteral 12"}},
+		{"1.5e3", []string{"NumericLiteral 1.5e3"}},
+		{`"héllo" + name`, []string{`StringLiteral "héllo"`, "Identifier name", `BinaryExpession "héllo" + name`}},
+		{"1 + 2 * 3", []string{"BinaryExpession 1 + 2 * 3", "BinaryExpession 2 * 3"}},
+		{"(1 + 2) * 3", []string{"BinaryExpession (1 + 2) * 3", "BinaryExpession 1 + 2"}},
+		{"(a + b).c < (g)(1)", []string{"ConditionDeclaration (a + b).c < (g)(1)", "MemberExpression (a + b).c", "CallExpression (g)(1)"}},
+		{"a < b", []string{"ConditionDeclaration a < b"}},
+		{"f(1, g(x))", []string{"CallExpression f(1, g(x))", "CallExpression g(x)", "Identifier f"}},
+		{"obj.items[0].name", []string{"MemberExpression obj.items[0].name", "MemberExpression obj.items[0]", "MemberExpression obj.items", "Identifier items"}},
+		{"x = y + 1", []string{"AssignmentExpr x = y + 1", "BinaryExpession y + 1"}},
+		{"[1, [2]]", []string{"ArrayLiteral [1, [2]]", "ArrayLiteral [2]"}},
+		{`{ a: 1, "b c": [2] }`, []string{`ObjectLiteral { a: 1, "b c": [2] }`, "Property a: 1", `Property "b c": [2]`}},
+		{"let total: int = 1 + 2;", []string{"VariableDeclaration let total: int = 1 + 2;", "TypeAnnotation int", "BinaryExpession 1 + 2"}},
+		{"const { x, y = 2 } = point;", []string{"VariableDeclaration const { x, y = 2 } = point;", "ObjectPattern { x, y = 2 }", "IntegerLiteral 2"}},
+		{"[a, b = 3] = [b]", []string{"AssignmentExpr [a, b = 3] = [b]", "ArrayPattern [a, b = 3]", "IntegerLiteral 3"}},
+		{"fn add(a, b) {\n    a + b\n}", []string{"FunctionDeclaration fn add(a, b) {\n    a + b\n}", "Identifier a", "BinaryExpession a + b"}},
+		{"if (x > 1) {\n    f(x)\n} else {\n    g()\n}", []string{"IfExpressions if (x > 1) {\n    f(x)\n} else {\n    g()\n}", "ConditionDeclaration x > 1", "CallExpression f(x)", "CallExpression g()"}},
+		{"for (let item of [1, 2]) {\n    println(item)\n}", []string{"ForEachExpression for (let item of [1, 2]) {\n    println(item)\n}", "ArrayLiteral [1, 2]", "CallExpression println(item)"}},
+		{"for (let i = 0; i < 3; i = i + 1) {\n    break\n}", []string{"ForExpression for (let i = 0; i < 3; i = i + 1) {\n    break\n}", "VariableDeclaration let i = 0;", "ConditionDeclaration i < 3", "AssignmentExpr i = i + 1", "BreakExpression break"}},
+		{"match (v) {\n    1 => \"one\",\n    _ => \"many\",\n}", []string{"MatchExpression match (v) {\n    1 => \"one\",\n    _ => \"many\",\n}", `StringLiteral "one"`, `StringLiteral "many"`}},
+		{"switch (v) {\n    case 1:\n        f()\n}", []string{"SwitchExpression switch (v) {\n    case 1:\n        f()\n}", "CallExpression f()"}},
+		{`import { a as b } from "./lib.gl";`, []string{`ImportDeclaration import { a as b } from "./lib.gl";`}},
+		{"export fn f() {\n    1\n}", []string{"ExportDeclaration export fn f() {\n    1\n}", "FunctionDeclaration fn f() {\n    1\n}"}},
+	}
+
+	for _, test := range tests {
+		program, err := NewParser().ProduceAST(test.source)
+		if err != nil {
+			t.Errorf("%q: %v", test.source, err)
+			continue
+		}
+
+		spans := map[string]bool{}
+		collectSpans(t, []rune(test.source), reflect.ValueOf(program), nil, spans)
+		for _, span := range test.spans {
+			if !spans[span] {
+				t.Errorf("%q: expected a node %q, got %v", test.source, span, spans)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+)
+
+// benchmarkScript runs a script of the benchmarks directory b.N times on
+// the tree engine, checked first when slots is set so local variables are
+// read from their slots, looked up by name otherwise. The output of the
+// script is dropped.
+func benchmarkScript(b *testing.B, file string, slots bool) {
+	source, err := os.ReadFile(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	program, cErr := parser.NewParser().ProduceAST(string(source))
+	if cErr != nil {
+		b.Fatal(cErr)
+	}
+
+	i := NewInterpreter()
+	if slots {
+		env, cErr := NewEnvironments(nil)
+		if cErr != nil {
+			b.Fatal(cErr)
+		}
+		if cErr := i.CheckProgram(file, program, env); cErr != nil {
+			b.Fatal(cErr)
+		}
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		env, cErr := NewEnvironments(nil)
+		if cErr != nil {
+			b.Fatal(cErr)
+		}
+		b.StartTimer()
+
+		if _, cErr := i.Run(program, env); cErr != nil {
+			b.Fatal(cErr)
+		}
+	}
+}
+
+func BenchmarkLocals(b *testing.B) {
+	b.Run("names", func(b *testing.B) {
+		benchmarkScript(b, "../benchmarks/locals.gl", false)
+	})
+	b.Run("slots", func(b *testing.B) {
+		benchmarkScript(b, "../benchmarks/locals.gl", true)
+	})
+}
+
+// values is the loop of BenchmarkValues, it mixes integer, float and
+// comparison operators on values the loop computes.
+const values = `
+fn count(n) {
+    let hits = 0;
+    let ratio = 0.5;
+    for (let i = 0; i < n; i = i + 1) {
+        if (i % 3 == 0) {
+            hits = hits + 1
+        }
+        ratio = ratio * 1.0
+    }
+    hits
+}
+`
+
+// BenchmarkValues runs b.N iterations of one loop on each engine, so the
+// allocations reported are those of an iteration.
+func BenchmarkValues(b *testing.B) {
+	for _, engine := range engines {
+		b.Run(string(engine), func(b *testing.B) {
+			program, cErr := parser.NewParser().ProduceAST(values + fmt.Sprintf("count(%d)\n", b.N))
+			if cErr != nil {
+				b.Fatal(cErr)
+			}
+			env, cErr := NewEnvironments(nil)
+			if cErr != nil {
+				b.Fatal(cErr)
+			}
+			i := NewInterpreter()
+			i.Engine = engine
+			if cErr := i.CheckProgram("", program, env); cErr != nil {
+				b.Fatal(cErr)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			result, cErr := i.Run(program, env)
+			if cErr != nil {
+				b.Fatal(cErr)
+			}
+			if hits := (b.N + 2) / 3; displayValue(result) != fmt.Sprint(hits) {
+				b.Fatalf("expected %d hits, got %s", hits, displayValue(result))
+			}
+		})
+	}
+}
+
+// BenchmarkOperators applies one operator to operands of each kind, boxed
+// as RuntimeVals like the values of arrays and natives, and tagged with
+// the numbers inline like the values of the operators, the slots and the
+// stack of the VM.
+func BenchmarkOperators(b *testing.B) {
+	i := NewInterpreter()
+	cases := []struct {
+		name     string
+		lhs, rhs RuntimeVal
+		operator string
+		compare  bool
+	}{
+		{"small integers", makeInteger(2), makeInteger(3), "+", false},
+		{"large integers", makeInteger(2000), makeInteger(3000), "+", false},
+		{"floats", makeNumber(0.5), makeNumber(1.0), "*", false},
+		{"integer and float", makeInteger(2), makeNumber(0.5), "*", false},
+		{"comparison", makeInteger(2000), makeNumber(0.5), "<", true},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name+"/boxed", func(b *testing.B) {
+			op := i.binaryOp
+			if c.compare {
+				op = i.compareOp
+			}
+
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if _, err := op(c.lhs, c.rhs, c.operator); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(c.name+"/tagged", func(b *testing.B) {
+			// The operands are numbers an operator made, without a box
+			lhs, rhs := tag(c.lhs), tag(c.rhs)
+			lhs.ref, rhs.ref = nil, nil
+
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				var err *diag.CustomError
+				if c.compare {
+					_, err = i.compareTagged(lhs, rhs, c.operator)
+				} else {
+					_, err = i.binaryTagged(lhs, rhs, c.operator)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+
+	"aolbrich/lexer/ast"
+)
+
+// Opcode is one instruction of the bytecode, its operands follow it as big
+// endian uint16 values. Stack offsets count from the base of the frame.
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // constant
+	OpNull
+	OpPop
+	OpPopN     // count
+	OpPopUnder // count, removes the values below the top one
+	OpDup
+	OpGetStack // offset
+	OpSetStack // offset, pops the value
+
+	// Variables, slots come from the resolver and names are constants
+	OpGetLocal     // depth, slot, name
+	OpSetLocal     // depth, slot, name
+	OpDeclareLocal // slot, name
+	OpGetName      // name
+	OpSetName      // name
+	OpDeclareName  // name, constant flag
+
+	// Operators are string constants
+	OpBinary  // operator
+	OpCompare // operator
+	OpUnary   // operator
+	OpEqual
+
+	OpObject
+	OpSetProperty
+	OpArray // count
+	OpGetMember
+	OpSetMember
+
+	OpClosure // function
+	OpArg     // index
+	OpCall    // argument count
+	OpReturn
+
+	// OpTailCall is OpCall replacing the frame of the function making it
+	OpTailCall // argument count
+
+	// Jumps go to an absolute offset, conditional jumps pop their operand
+	// unless noted
+	OpJump           // target
+	OpJumpIfFalse    // target, the value must be a bool
+	OpJumpIfTrue     // target
+	OpJumpIfNotTrue  // target, jumps for anything but true
+	OpJumpIfNotNull  // target, keeps the value
+	OpJumpIfBreak    // target, keeps the value
+	OpJumpIfContinue // target, keeps the value
+
+	OpBreak
+	OpContinue
+	OpPushScope // locals
+	OpPopScope
+	OpIterStart // keys flag
+	OpIterNext  // iterator offset, target once done
+
+	// Patterns, the value being matched or destructured stays on the stack
+	OpIsObject
+	OpArrayFits // length
+	OpCheckObject
+	OpCheckArray
+	OpPatternProperty // key, pushes the property and whether it exists
+	OpPatternIndex    // index, pushes the element and whether it exists
+	OpMatchFail
+
+	OpImport // import
+	OpExport // names constant
+	OpFail   // message constant
+)
+
+// operandCounts is the number of operands of every opcode.
+var operandCounts = map[Opcode]int{
+	OpConstant: 1, OpPopN: 1, OpPopUnder: 1, OpGetStack: 1, OpSetStack: 1,
+	OpGetLocal: 3, OpSetLocal: 3, OpDeclareLocal: 2, OpGetName: 1, OpSetName: 1, OpDeclareName: 2,
+	OpBinary: 1, OpCompare: 1, OpUnary: 1, OpArray: 1,
+	OpClosure: 1, OpArg: 1, OpCall: 1, OpTailCall: 1,
+	OpJump: 1, OpJumpIfFalse: 1, OpJumpIfTrue: 1, OpJumpIfNotTrue: 1, OpJumpIfNotNull: 1, OpJumpIfBreak: 1, OpJumpIfContinue: 1,
+	OpPushScope: 1, OpIterStart: 1, OpIterNext: 2,
+	OpArrayFits: 1, OpPatternProperty: 1, OpPatternIndex: 1,
+	OpImport: 1, OpExport: 1, OpFail: 1,
+}
+
+// Chunk is the bytecode of a program or of a function body.
+type Chunk struct {
+	code      []byte
+	constants []RuntimeVal
+	functions []*FunctionProto
+	imports   []*ast.ImportDeclaration
+	// spans map instructions to the source, an entry holds for the
+	// instructions from its offset up to the next entry
+	spans []codeSpan
+}
+
+type codeSpan struct {
+	offset int
+	pos    int
+	end    int
+}
+
+// FunctionProto is a compiled function declaration, calls bind the
+// arguments to the parameters in the code itself.
+type FunctionProto struct {
+	name   string
+	locals int
+	chunk  *Chunk
+}
+
+func (c *Chunk) mark(offset int, node ast.Stmter) {
+	if node == nil {
+		return
+	}
+
+	if last := len(c.spans) - 1; last >= 0 && c.spans[last].pos == node.Pos() && c.spans[last].end == node.End() {
+		return
+	}
+
+	c.spans = append(c.spans, codeSpan{offset: offset, pos: node.Pos(), end: node.End()})
+}
+
+// spanAt returns the source span of the instruction at offset.
+func (c *Chunk) spanAt(offset int) (int, int) {
+	index := sort.Search(len(c.spans), func(i int) bool {
+		return c.spans[i].offset > offset
+	}) - 1
+	if index < 0 {
+		return 0, 0
+	}
+
+	return c.spans[index].pos, c.spans[index].end
+}
+
+func (c *Chunk) operand(offset int) int {
+	return int(c.code[offset])<<8 | int(c.code[offset+1])
+}
+
+func (op Opcode) String() string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Op(%d)", byte(op))
+}
+
+var opcodeNames = map[Opcode]string{
+	OpConstant: "Constant", OpNull: "Null", OpPop: "Pop", OpPopN: "PopN", OpPopUnder: "PopUnder", OpDup: "Dup",
+	OpGetStack: "GetStack", OpSetStack: "SetStack",
+	OpGetLocal: "GetLocal", OpSetLocal: "SetLocal", OpDeclareLocal: "DeclareLocal",
+	OpGetName: "GetName", OpSetName: "SetName", OpDeclareName: "DeclareName",
+	OpBinary: "Binary", OpCompare: "Compare", OpUnary: "Unary", OpEqual: "Equal",
+	OpObject: "Object", OpSetProperty: "SetProperty", OpArray: "Array", OpGetMember: "GetMember", OpSetMember: "SetMember",
+	OpClosure: "Closure", OpArg: "Arg", OpCall: "Call", OpReturn: "Return", OpTailCall: "TailCall",
+	OpJump: "Jump", OpJumpIfFalse: "JumpIfFalse", OpJumpIfTrue: "JumpIfTrue", OpJumpIfNotTrue: "JumpIfNotTrue",
+	OpJumpIfNotNull: "JumpIfNotNull", OpJumpIfBreak: "JumpIfBreak", OpJumpIfContinue: "JumpIfContinue",
+	OpBreak: "Break", OpContinue: "Continue", OpPushScope: "PushScope", OpPopScope: "PopScope",
+	OpIterStart: "IterStart", OpIterNext: "IterNext",
+	OpIsObject: "IsObject", OpArrayFits: "ArrayFits", OpCheckObject: "CheckObject", OpCheckArray: "CheckArray",
+	OpPatternProperty: "PatternProperty", OpPatternIndex: "PatternIndex", OpMatchFail: "MatchFail",
+	OpImport: "Import", OpExport: "Export", OpFail: "Fail",
+}
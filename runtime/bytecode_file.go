@@ -0,0 +1,328 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"aolbrich/lexer/ast"
+)
+
+// Compiled programs are written next to their source with the .glc
+// extension. The file starts with a header:
+//
+//	magic    "GLBC"
+//	version  uint16, big endian
+//	checksum sha256 of the source the program was compiled from
+//	content  sha256 of the rest of the file
+//
+// followed by the source itself, kept for error messages, and the chunk of
+// the program. A chunk is its code, its constants pool, its function table
+// holding the chunks of the functions declared in it, its imports and its
+// debug line table mapping instructions to source spans. Numbers are
+// varints and strings are prefixed with their length.
+const (
+	bytecodeMagic = "GLBC"
+	// bytecodeVersion must change with the opcodes or the layout, files
+	// written by another version are stale
+	bytecodeVersion = 3
+	CompiledExt     = ".glc"
+)
+
+const (
+	constantInteger byte = iota
+	constantNumber
+	constantString
+	constantBool
+	constantArray
+)
+
+var ErrStaleBytecode = errors.New("compiled from another version of the source")
+
+// CompiledProgram is a program loaded from its compiled file, source is the
+// source it was compiled from.
+type CompiledProgram struct {
+	checksum [sha256.Size]byte
+	Source   string
+	Chunk    *Chunk
+}
+
+// CompiledPath returns where the compiled program of a source file is kept.
+func CompiledPath(file string) string {
+	return strings.TrimSuffix(file, moduleExt) + CompiledExt
+}
+
+// SourcePath returns the source file a compiled program was written for.
+func SourcePath(file string) string {
+	return strings.TrimSuffix(file, CompiledExt) + moduleExt
+}
+
+func WriteCompiled(file string, source string, chunk *Chunk) error {
+	var content bytes.Buffer
+	w := &bytecodeWriter{w: &content}
+	w.string(source)
+	w.chunk(chunk)
+
+	if w.err != nil {
+		return w.err
+	}
+
+	var buf bytes.Buffer
+	checksum := sha256.Sum256([]byte(source))
+	contentChecksum := sha256.Sum256(content.Bytes())
+	buf.WriteString(bytecodeMagic)
+	binary.Write(&buf, binary.BigEndian, uint16(bytecodeVersion))
+	buf.Write(checksum[:])
+	buf.Write(contentChecksum[:])
+	buf.Write(content.Bytes())
+
+	return os.WriteFile(file, buf.Bytes(), 0644)
+}
+
+// ReadCompiled loads a compiled program, files written by another version
+// of the compiler or damaged are rejected.
+func ReadCompiled(file string) (*CompiledProgram, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// The version comes first so files of other versions are told apart
+	// from damaged ones whatever their header holds after it
+	if len(content) < len(bytecodeMagic)+2 || string(content[:len(bytecodeMagic)]) != bytecodeMagic {
+		return nil, fmt.Errorf("not a compiled program")
+	}
+
+	version := binary.BigEndian.Uint16(content[len(bytecodeMagic):])
+	if version != bytecodeVersion {
+		return nil, fmt.Errorf("compiled to bytecode version %d, this interpreter runs version %d", version, bytecodeVersion)
+	}
+
+	header := len(bytecodeMagic) + 2 + 2*sha256.Size
+	if len(content) < header {
+		return nil, fmt.Errorf("corrupt compiled program, the header is truncated")
+	}
+	var contentChecksum [sha256.Size]byte
+	copy(contentChecksum[:], content[header-sha256.Size:header])
+	if contentChecksum != sha256.Sum256(content[header:]) {
+		return nil, fmt.Errorf("corrupt compiled program, its content does not match its checksum")
+	}
+
+	compiled := &CompiledProgram{}
+	copy(compiled.checksum[:], content[len(bytecodeMagic)+2:])
+	r := &bytecodeReader{r: bufio.NewReader(bytes.NewReader(content[header:]))}
+	compiled.Source = r.string()
+	compiled.Chunk = r.chunk()
+	if r.err == nil {
+		r.err = compiled.Chunk.verify()
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("corrupt compiled program, %s", r.err)
+	}
+
+	return compiled, nil
+}
+
+// CompiledFrom tells whether the program was compiled from source.
+func (p *CompiledProgram) CompiledFrom(source string) bool {
+	return p.checksum == sha256.Sum256([]byte(source))
+}
+
+type bytecodeWriter struct {
+	w   *bytes.Buffer
+	err error
+}
+
+func (w *bytecodeWriter) uint(n int) {
+	var buf [binary.MaxVarintLen64]byte
+	w.w.Write(buf[:binary.PutUvarint(buf[:], uint64(n))])
+}
+
+func (w *bytecodeWriter) string(s string) {
+	w.uint(len(s))
+	w.w.WriteString(s)
+}
+
+func (w *bytecodeWriter) chunk(chunk *Chunk) {
+	w.uint(len(chunk.code))
+	w.w.Write(chunk.code)
+
+	w.uint(len(chunk.constants))
+	for _, constant := range chunk.constants {
+		w.constant(constant)
+	}
+
+	w.uint(len(chunk.functions))
+	for _, function := range chunk.functions {
+		w.string(function.name)
+		w.uint(function.locals)
+		w.chunk(function.chunk)
+	}
+
+	w.uint(len(chunk.imports))
+	for _, declaration := range chunk.imports {
+		w.uint(declaration.Pos())
+		w.uint(declaration.End())
+		w.string(declaration.Path)
+		w.string(declaration.Namespace)
+		w.uint(len(declaration.Names))
+		for _, name := range declaration.Names {
+			w.string(name.Name)
+			w.string(name.Alias)
+		}
+	}
+
+	w.uint(len(chunk.spans))
+	for _, span := range chunk.spans {
+		w.uint(span.offset)
+		w.uint(span.pos)
+		w.uint(span.end)
+	}
+}
+
+func (w *bytecodeWriter) constant(constant RuntimeVal) {
+	switch v := constant.(type) {
+	case *IntVal:
+		var buf [binary.MaxVarintLen64]byte
+		w.w.WriteByte(constantInteger)
+		w.w.Write(buf[:binary.PutVarint(buf[:], v.Value)])
+	case *NumberVal:
+		w.w.WriteByte(constantNumber)
+		binary.Write(w.w, binary.BigEndian, math.Float64bits(v.Value))
+	case *StringVal:
+		w.w.WriteByte(constantString)
+		w.string(v.Value)
+	case *BoolVal:
+		w.w.WriteByte(constantBool)
+		if v.Value {
+			w.w.WriteByte(1)
+		} else {
+			w.w.WriteByte(0)
+		}
+	case *ArrayVal:
+		w.w.WriteByte(constantArray)
+		w.uint(len(v.elements))
+		for _, element := range v.elements {
+			w.constant(element)
+		}
+	default:
+		w.err = fmt.Errorf("cannot write constant %s", displayValue(constant))
+	}
+}
+
+// bytecodeReader keeps the first error, reads after it return zero values.
+type bytecodeReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (r *bytecodeReader) uint() int {
+	if r.err != nil {
+		return 0
+	}
+
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		r.err = err
+		return 0
+	}
+	if n > math.MaxInt32 {
+		r.err = fmt.Errorf("value %d out of range", n)
+		return 0
+	}
+
+	return int(n)
+}
+
+func (r *bytecodeReader) bytes() []byte {
+	length := r.uint()
+	if r.err != nil {
+		return nil
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r.r, content); err != nil {
+		r.err = err
+		return nil
+	}
+
+	return content
+}
+
+func (r *bytecodeReader) string() string {
+	return string(r.bytes())
+}
+
+func (r *bytecodeReader) chunk() *Chunk {
+	chunk := &Chunk{code: r.bytes()}
+
+	for count := r.uint(); r.err == nil && len(chunk.constants) < count; {
+		chunk.constants = append(chunk.constants, r.constant())
+	}
+
+	for count := r.uint(); r.err == nil && len(chunk.functions) < count; {
+		chunk.functions = append(chunk.functions, &FunctionProto{name: r.string(), locals: r.uint(), chunk: r.chunk()})
+	}
+
+	for count := r.uint(); r.err == nil && len(chunk.imports) < count; {
+		declaration := &ast.ImportDeclaration{Stmt: ast.NewStmt(ast.NodeTypeImportDeclaration, r.uint(), r.uint())}
+		declaration.Path = r.string()
+		declaration.Namespace = r.string()
+		for names := r.uint(); r.err == nil && len(declaration.Names) < names; {
+			declaration.Names = append(declaration.Names, ast.ImportName{Name: r.string(), Alias: r.string()})
+		}
+		chunk.imports = append(chunk.imports, declaration)
+	}
+
+	for count := r.uint(); r.err == nil && len(chunk.spans) < count; {
+		chunk.spans = append(chunk.spans, codeSpan{offset: r.uint(), pos: r.uint(), end: r.uint()})
+	}
+
+	return chunk
+}
+
+func (r *bytecodeReader) constant() RuntimeVal {
+	if r.err != nil {
+		return nil
+	}
+
+	tag, err := r.r.ReadByte()
+	if err != nil {
+		r.err = err
+		return nil
+	}
+
+	switch tag {
+	case constantInteger:
+		n, err := binary.ReadVarint(r.r)
+		r.err = err
+		return makeInteger(n)
+	case constantNumber:
+		var bits uint64
+		r.err = binary.Read(r.r, binary.BigEndian, &bits)
+		return makeNumber(math.Float64frombits(bits))
+	case constantString:
+		return makeString(r.string())
+	case constantBool:
+		b, err := r.r.ReadByte()
+		r.err = err
+		return makeBool(b == 1)
+	case constantArray:
+		var elements []RuntimeVal
+		for count := r.uint(); r.err == nil && len(elements) < count; {
+			elements = append(elements, r.constant())
+		}
+		return makeArray(elements)
+	}
+
+	r.err = fmt.Errorf("unknown constant tag %d", tag)
+
+	return nil
+}
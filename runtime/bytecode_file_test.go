@@ -0,0 +1,217 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+
+	"aolbrich/lexer/parser"
+)
+
+// damaged is compiled and then damaged by the tests, it uses every kind of
+// instruction the compiler emits.
+const damaged = `
+fn sum(items) {
+    let total = 0;
+    for (let item of items) {
+        if (item > 2) {
+            continue
+        }
+        total = total + item
+    }
+    total
+}
+
+fn describe(value) {
+    match (value) {
+        { kind: "point", x, y = 0 } => x + y,
+        [first, second] => first * second,
+        1 | 2 => "small",
+        n if n > 10 => "big",
+        _ => "other",
+    }
+}
+
+let { a, b: [c, d = 4] } = { a: 1, b: [3] };
+let count = 0;
+for (let i = 0; i < 3; i = i + 1) {
+    switch (i) {
+        case 1:
+            count = count + 10
+            break
+        default:
+            count = count + 1
+    }
+}
+let pair = [a, c];
+pair[0] = ~d << 1
+export const result = [sum([1, 2, 3]), describe({ kind: "point", x: 2 }), describe([2, 3]), describe(11), count, pair];
+result
+`
+
+// compileSource checks and compiles source in a root environment of its own.
+func compileSource(t *testing.T, source string) *Chunk {
+	t.Helper()
+
+	program, err := parser.NewParser().ProduceAST(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := NewEnvironments(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewInterpreter().CheckProgram("", program, env); err != nil {
+		t.Fatal(err)
+	}
+
+	chunk, err := CompileProgram(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return chunk
+}
+
+func TestVerifyCompiledPrograms(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.gl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	std, err := filepath.Glob("stdlib/std/*.gl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[string]string{"damaged": damaged}
+	for _, file := range append(files, std...) {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[file] = string(content)
+	}
+
+	for file, source := range sources {
+		program, cErr := parser.NewParser().ProduceAST(source)
+		if cErr != nil {
+			continue
+		}
+		env, cErr := NewEnvironments(nil)
+		if cErr != nil {
+			t.Fatal(cErr)
+		}
+		// Examples of errors found by the checks are not compiled
+		if NewInterpreter().CheckProgram(file, program, env) != nil {
+			continue
+		}
+
+		chunk, cErr := CompileProgram(program)
+		if cErr != nil {
+			t.Errorf("%s: %v", file, cErr)
+			continue
+		}
+		if err := chunk.verify(); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestReadCompiledRejectsDamagedFiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "damaged.glc")
+	if err := WriteCompiled(file, damaged, compileSource(t, damaged)); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compiled, err := ReadCompiled(file)
+	if err != nil || !compiled.CompiledFrom(damaged) {
+		t.Fatalf("expected the compiled program to load, got %v", err)
+	}
+
+	// Past the magic and the version every byte is covered by a checksum
+	for offset := len(bytecodeMagic) + 2; offset < len(content); offset++ {
+		corrupt := append([]byte{}, content...)
+		corrupt[offset] ^= 0x5a
+		if err := os.WriteFile(file, corrupt, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		compiled, err := ReadCompiled(file)
+		if err == nil && compiled.CompiledFrom(damaged) {
+			t.Fatalf("damaging byte %d was not noticed", offset)
+		}
+	}
+
+	for _, length := range []int{0, 3, len(bytecodeMagic) + 2, len(bytecodeMagic) + 40, len(content) - 1} {
+		if err := os.WriteFile(file, content[:length], 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadCompiled(file); err == nil {
+			t.Errorf("a file truncated to %d bytes was loaded", length)
+		}
+	}
+}
+
+// TestVerifyDamagedCode sets every byte of the code to every opcode and to
+// small and large operands, the chunks passing the verification must run
+// without panicking.
+func TestVerifyDamagedCode(t *testing.T) {
+	chunk := compileSource(t, damaged)
+	chunks := []*Chunk{chunk}
+	for _, function := range chunk.functions {
+		chunks = append(chunks, function.chunk)
+	}
+
+	run := func(at string) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("%s: verified code panicked: %v\n%s", at, r, debug.Stack())
+			}
+		}()
+
+		env, err := NewSandboxEnvironments(DenyAll())
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := NewInterpreter()
+		i.Engine = EngineVM
+		i.MaxSteps = 5000
+		i.MaxAlloc = 1 << 20
+		i.WithContext(context.Background()).EvaluateCompiledModule("damaged.gl", damaged, chunk, env)
+	}
+	run("undamaged")
+
+	verified := 0
+	for index, damagedChunk := range chunks {
+		for offset := range damagedChunk.code {
+			original := damagedChunk.code[offset]
+			values := []int{0x80, 0xff}
+			for value := 0; value <= len(opcodeNames)+8; value++ {
+				values = append(values, value)
+			}
+			for _, value := range values {
+				if byte(value) == original {
+					continue
+				}
+				damagedChunk.code[offset] = byte(value)
+				if chunk.verify() == nil {
+					verified++
+					run(fmt.Sprintf("chunk %d, byte %d set to %d", index, offset, value))
+				}
+			}
+			damagedChunk.code[offset] = original
+		}
+	}
+
+	t.Logf("%d damaged chunks verified", verified)
+	if verified == 0 {
+		t.Errorf("no damaged code passed the verification, the runs were not tested")
+	}
+}
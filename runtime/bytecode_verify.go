@@ -0,0 +1,444 @@
+package runtime
+
+import (
+	"fmt"
+)
+
+// nameOperands is the operand of the instructions naming a string constant.
+var nameOperands = map[Opcode]int{
+	OpGetLocal: 2, OpSetLocal: 2, OpDeclareLocal: 1, OpGetName: 0, OpSetName: 0, OpDeclareName: 0,
+	OpBinary: 0, OpCompare: 0, OpUnary: 0, OpPatternProperty: 0, OpFail: 0,
+}
+
+// stackKind is what the verifier knows of a value on the stack, the
+// instructions reading an iterator, an object or an array from the stack
+// without checking it need one of that kind.
+type stackKind byte
+
+const (
+	kindValue stackKind = iota
+	kindIterator
+	kindObject
+	kindArray
+	// kindIsObject and kindArrayFits are the results of OpIsObject and
+	// OpArrayFits, the value below them has the shape on the path a
+	// conditional jump takes when they hold
+	kindIsObject
+	kindArrayFits
+)
+
+// codeState is the stack of a frame before an instruction and the scopes it
+// runs in, scopes holds the slot count of every scope, the innermost last.
+type codeState struct {
+	stack  []stackKind
+	scopes []int
+}
+
+func (s *codeState) copy() *codeState {
+	return &codeState{stack: append([]stackKind{}, s.stack...), scopes: append([]int{}, s.scopes...)}
+}
+
+func (s *codeState) push(kind stackKind) {
+	s.stack = append(s.stack, kind)
+}
+
+func (s *codeState) pop() stackKind {
+	kind := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+
+	return kind
+}
+
+// refine gives the value on top of the stack the shape a test held for.
+func (s *codeState) refine(test stackKind) {
+	if len(s.stack) == 0 {
+		return
+	}
+
+	switch test {
+	case kindIsObject:
+		s.stack[len(s.stack)-1] = kindObject
+	case kindArrayFits:
+		s.stack[len(s.stack)-1] = kindArray
+	}
+}
+
+// merge joins state into s, values of different kinds become plain values.
+// It tells whether s changed.
+func (s *codeState) merge(state *codeState) (bool, error) {
+	if len(s.stack) != len(state.stack) {
+		return false, fmt.Errorf("%d values on the stack on one path and %d on another", len(s.stack), len(state.stack))
+	}
+	if !sameScopes(s.scopes, state.scopes) {
+		return false, fmt.Errorf("paths in different scopes")
+	}
+
+	changed := false
+	for index, kind := range state.stack {
+		if s.stack[index] != kind && s.stack[index] != kindValue {
+			s.stack[index] = kindValue
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+func sameScopes(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if a[index] != b[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// instruction is a decoded instruction of a chunk.
+type instruction struct {
+	op       Opcode
+	operands []int
+	next     int
+}
+
+// verify checks that a loaded chunk and its functions can run, so a damaged
+// file is rejected instead of failing while running: instructions refer to
+// constants, functions and imports the chunk has, jumps land on
+// instructions, every instruction finds the values it takes on the stack
+// and the same number of values whichever path reaches it, and locals are
+// in the scopes around them. The program runs in the module root, which
+// has no slots.
+func (c *Chunk) verify() error {
+	return c.verifyCode([]int{0})
+}
+
+// verifyCode verifies a chunk run in scopes, its functions in the scopes
+// their closure is made in.
+func (c *Chunk) verifyCode(scopes []int) error {
+	instructions, err := c.decode()
+	if err != nil {
+		return err
+	}
+
+	// functions holds the scopes every function was verified in
+	functions := make(map[int]string)
+	states := make([]*codeState, len(c.code))
+	if len(c.code) == 0 {
+		return fmt.Errorf("code runs past its end at 0")
+	}
+	states[0] = &codeState{scopes: scopes}
+	queue := []int{0}
+
+	for len(queue) > 0 {
+		offset := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+
+		in := instructions[offset]
+		state := states[offset].copy()
+		next, jump, target, err := c.stepState(in, state, len(scopes))
+		if err != nil {
+			return fmt.Errorf("instruction %s at %d %s", in.op, offset, err)
+		}
+
+		if in.op == OpClosure {
+			index := in.operands[0]
+			function := c.functions[index]
+			closure := append(append([]int{}, states[offset].scopes...), function.locals)
+			if verified, ok := functions[index]; !ok || verified != fmt.Sprint(closure) {
+				if err := function.chunk.verifyCode(closure); err != nil {
+					return fmt.Errorf("fn %s: %s", function.name, err)
+				}
+				functions[index] = fmt.Sprint(closure)
+			}
+		}
+
+		for _, successor := range []struct {
+			offset int
+			state  *codeState
+		}{{in.next, next}, {target, jump}} {
+			if successor.state == nil {
+				continue
+			}
+			if successor.offset >= len(instructions) || instructions[successor.offset] == nil {
+				return fmt.Errorf("instruction %s at %d continues at %d, which is not an instruction", in.op, offset, successor.offset)
+			}
+
+			known := states[successor.offset]
+			if known == nil {
+				states[successor.offset] = successor.state
+				queue = append(queue, successor.offset)
+				continue
+			}
+			changed, err := known.merge(successor.state)
+			if err != nil {
+				return fmt.Errorf("instruction at %d is reached with %s", successor.offset, err)
+			}
+			if changed {
+				queue = append(queue, successor.offset)
+			}
+		}
+	}
+
+	// Functions no closure is made of never run, their code is checked on
+	// its own
+	for index, function := range c.functions {
+		if _, ok := functions[index]; ok {
+			continue
+		}
+		if err := function.chunk.verifyCode([]int{function.locals}); err != nil {
+			return fmt.Errorf("fn %s: %s", function.name, err)
+		}
+	}
+
+	return nil
+}
+
+// decode reads the instructions of the chunk by offset, nil between them,
+// and checks their operands referring to the constants, functions and
+// imports of the chunk.
+func (c *Chunk) decode() ([]*instruction, error) {
+	instructions := make([]*instruction, len(c.code))
+
+	for offset := 0; offset < len(c.code); {
+		op := Opcode(c.code[offset])
+		if _, known := opcodeNames[op]; !known {
+			return nil, fmt.Errorf("unknown instruction %d at %d", op, offset)
+		}
+
+		count := operandCounts[op]
+		if offset+1+2*count > len(c.code) {
+			return nil, fmt.Errorf("instruction %s at %d is truncated", op, offset)
+		}
+
+		operands := make([]int, count)
+		for index := range operands {
+			operands[index] = c.operand(offset + 1 + 2*index)
+		}
+
+		if index, ok := nameOperands[op]; ok {
+			if operands[index] >= len(c.constants) {
+				return nil, fmt.Errorf("instruction %s at %d refers to a missing constant", op, offset)
+			}
+			if _, ok := c.constants[operands[index]].(*StringVal); !ok {
+				return nil, fmt.Errorf("instruction %s at %d expects a string constant", op, offset)
+			}
+		}
+
+		var missing bool
+		switch op {
+		case OpConstant:
+			missing = operands[0] >= len(c.constants)
+		case OpExport:
+			missing = operands[0] >= len(c.constants) || !isNameList(c.constants[operands[0]])
+		case OpClosure:
+			missing = operands[0] >= len(c.functions)
+		case OpImport:
+			missing = operands[0] >= len(c.imports)
+		}
+		if missing {
+			return nil, fmt.Errorf("instruction %s at %d refers to a missing target", op, offset)
+		}
+
+		next := offset + 1 + 2*count
+		instructions[offset] = &instruction{op: op, operands: operands, next: next}
+		offset = next
+	}
+
+	return instructions, nil
+}
+
+// isNameList tells whether a constant is an array of strings.
+func isNameList(constant RuntimeVal) bool {
+	names, ok := constant.(*ArrayVal)
+	if !ok {
+		return false
+	}
+
+	for _, name := range names.elements {
+		if _, ok := name.(*StringVal); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stepState runs an instruction over the state before it, leaving the state
+// of the next instruction. A jump returns the state at its target too, an
+// instruction ending the frame returns no next state. base is the number
+// of scopes the code starts in, the scopes it pushes come on top.
+func (c *Chunk) stepState(in *instruction, state *codeState, base int) (*codeState, *codeState, int, error) {
+	operands := in.operands
+	need := func(count int) error {
+		if len(state.stack) < count {
+			return fmt.Errorf("takes %d values from a stack of %d", count, len(state.stack))
+		}
+		return nil
+	}
+	local := func(depth int, slot int) error {
+		if depth >= len(state.scopes) || slot >= state.scopes[len(state.scopes)-1-depth] {
+			return fmt.Errorf("refers to slot %d of a missing scope or slot, %d scopes up", slot, depth)
+		}
+		return nil
+	}
+	expect := func(offset int, kind stackKind, what string) error {
+		if state.stack[offset] != kind {
+			return fmt.Errorf("expects %s at %d on the stack", what, offset)
+		}
+		return nil
+	}
+
+	var err error
+	switch in.op {
+	case OpConstant, OpNull, OpGetName, OpClosure, OpArg, OpBreak, OpContinue, OpImport, OpFail:
+		state.push(kindValue)
+	case OpObject:
+		state.push(kindObject)
+	case OpGetLocal:
+		if err = local(operands[0], operands[1]); err == nil {
+			state.push(kindValue)
+		}
+	case OpSetLocal:
+		if err = need(1); err == nil {
+			err = local(operands[0], operands[1])
+		}
+	case OpDeclareLocal:
+		if err = need(1); err == nil {
+			err = local(0, operands[0])
+		}
+	case OpSetName, OpDeclareName, OpMatchFail:
+		err = need(1)
+	case OpPop:
+		if err = need(1); err == nil {
+			state.pop()
+		}
+	case OpPopN:
+		if err = need(operands[0]); err == nil {
+			state.stack = state.stack[:len(state.stack)-operands[0]]
+		}
+	case OpPopUnder:
+		if err = need(operands[0] + 1); err == nil {
+			top := state.pop()
+			state.stack = state.stack[:len(state.stack)-operands[0]]
+			state.push(top)
+		}
+	case OpDup:
+		if err = need(1); err == nil {
+			state.push(state.stack[len(state.stack)-1])
+		}
+	case OpGetStack:
+		if err = need(operands[0] + 1); err == nil {
+			state.push(state.stack[operands[0]])
+		}
+	case OpSetStack:
+		if err = need(operands[0] + 2); err == nil {
+			state.stack[operands[0]] = state.pop()
+		}
+	case OpBinary, OpCompare, OpEqual, OpGetMember:
+		if err = need(2); err == nil {
+			state.stack = append(state.stack[:len(state.stack)-2], kindValue)
+		}
+	case OpUnary:
+		if err = need(1); err == nil {
+			state.stack[len(state.stack)-1] = kindValue
+		}
+	case OpSetProperty:
+		if err = need(3); err == nil {
+			if err = expect(len(state.stack)-3, kindObject, "an object"); err == nil {
+				state.stack = state.stack[:len(state.stack)-2]
+			}
+		}
+	case OpSetMember:
+		if err = need(3); err == nil {
+			state.stack = append(state.stack[:len(state.stack)-3], kindValue)
+		}
+	case OpArray:
+		if err = need(operands[0]); err == nil {
+			state.stack = append(state.stack[:len(state.stack)-operands[0]], kindValue)
+		}
+	case OpCall, OpTailCall:
+		if err = need(operands[0] + 1); err == nil {
+			state.stack = append(state.stack[:len(state.stack)-operands[0]-1], kindValue)
+		}
+	case OpReturn:
+		return nil, nil, 0, need(1)
+	case OpJump:
+		return nil, state, operands[0], nil
+	case OpJumpIfFalse, OpJumpIfTrue, OpJumpIfNotTrue:
+		if err = need(1); err != nil {
+			break
+		}
+		test := state.pop()
+		jump := state.copy()
+		if in.op == OpJumpIfTrue {
+			jump.refine(test)
+		} else {
+			state.refine(test)
+		}
+		return state, jump, operands[0], nil
+	case OpJumpIfNotNull, OpJumpIfBreak, OpJumpIfContinue:
+		if err = need(1); err != nil {
+			break
+		}
+		return state, state.copy(), operands[0], nil
+	case OpPushScope:
+		state.scopes = append(state.scopes, operands[0])
+	case OpPopScope:
+		if len(state.scopes) <= base {
+			err = fmt.Errorf("pops a scope it did not push")
+			break
+		}
+		state.scopes = state.scopes[:len(state.scopes)-1]
+	case OpIterStart:
+		if err = need(1); err == nil {
+			state.stack[len(state.stack)-1] = kindIterator
+		}
+	case OpIterNext:
+		if err = need(operands[0] + 1); err != nil {
+			break
+		}
+		if err = expect(operands[0], kindIterator, "an iterator"); err != nil {
+			break
+		}
+		done := state.copy()
+		state.push(kindValue)
+		return state, done, operands[1], nil
+	case OpIsObject, OpArrayFits:
+		if err = need(1); err == nil {
+			test := kindIsObject
+			if in.op == OpArrayFits {
+				test = kindArrayFits
+			}
+			state.push(test)
+		}
+	case OpCheckObject:
+		if err = need(1); err == nil {
+			state.stack[len(state.stack)-1] = kindObject
+		}
+	case OpCheckArray:
+		if err = need(1); err == nil {
+			state.stack[len(state.stack)-1] = kindArray
+		}
+	case OpPatternProperty, OpPatternIndex:
+		if err = need(1); err != nil {
+			break
+		}
+		kind, what := kindObject, "an object"
+		if in.op == OpPatternIndex {
+			kind, what = kindArray, "an array"
+		}
+		if err = expect(len(state.stack)-1, kind, what); err == nil {
+			state.push(kindValue)
+			state.push(kindValue)
+		}
+	case OpExport:
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return state, nil, 0, nil
+}
@@ -0,0 +1,56 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"aolbrich/lexer/diag"
+)
+
+// LookupFunction returns the function declared as name in env or the
+// scopes around it, like a function of a module once the module ran. The
+// error is of kind diag.ErrorUndefined when there is no such function.
+func (e *Environments) LookupFunction(name string) (*FnValue, *diag.CustomError) {
+	value, err := e.lookupVar(name)
+	if err != nil {
+		return nil, diag.NewKindError(diag.ErrorUndefined, fmt.Sprintf("Function %s is not declared", name))
+	}
+
+	fn, ok := value.(*FnValue)
+	if !ok {
+		return nil, diag.NewKindError(diag.ErrorUndefined, fmt.Sprintf("%s is not a function, got %s", name, displayNested(value)))
+	}
+
+	return fn, nil
+}
+
+// Call calls a function of a script with Go arguments, converted by
+// ToValue, and returns the value of its body. Every call stops once ctx is
+// done and has step and allocation budgets of its own, a function may be
+// called again after a call failed. A call made by a host function during
+// a run counts against the budgets and the context of that run.
+func (i *Interpreter) Call(ctx context.Context, fn *FnValue, args ...interface{}) (RuntimeVal, *diag.CustomError) {
+	values := make([]RuntimeVal, len(args))
+	for index, arg := range args {
+		value, err := toValue(fmt.Sprintf("argument %d of %s", index+1, fn.name), reflect.ValueOf(arg))
+		if err != nil {
+			return nil, err
+		}
+		values[index] = value
+	}
+
+	i.WithContext(ctx)
+	defer i.startRun()()
+
+	result, err := i.callFunction(fn, values, 0, 0)
+	if err != nil {
+		// The last frame is the call made from Go, it has no call site
+		if last := len(err.Stack) - 1; last >= 0 && err.Stack[last].Function == fn.name {
+			err.Stack = err.Stack[:last]
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
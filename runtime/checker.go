@@ -0,0 +1,436 @@
+package runtime
+
+import (
+	"fmt"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+// maxTypeErrors is the number of type errors reported, the summary shows
+// the total.
+const maxTypeErrors = 20
+
+// TypeChecker checks the type annotations of a program before it runs.
+// Types come from literals, annotations, user function return types and
+// the native function signatures, everything else is TypeAny and accepted
+// anywhere, so unannotated code is not checked.
+type TypeChecker struct {
+	scope  *typeScope
+	errors []*diag.CustomError
+}
+
+// typeScope follows the scopes of the resolver, functions are registered
+// when their block is entered so calls can be checked before the declaration.
+type typeScope struct {
+	parent    *typeScope
+	variables map[string]ast.StaticType
+	functions map[string]*ast.FunctionDeclaration
+}
+
+func newTypeChecker() *TypeChecker {
+	c := &TypeChecker{}
+	c.openScope()
+
+	return c
+}
+
+func (c *TypeChecker) checkProgram(program *ast.Program) *diag.CustomError {
+	c.checkBody(program.Body)
+
+	if len(c.errors) == 0 {
+		return nil
+	}
+
+	sortBySource(c.errors)
+	total := len(c.errors)
+	if total > maxTypeErrors {
+		c.errors = c.errors[:maxTypeErrors]
+	}
+
+	return diag.NewErrorList(c.errors, total, "type errors")
+}
+
+func (c *TypeChecker) openScope() {
+	c.scope = &typeScope{
+		parent:    c.scope,
+		variables: make(map[string]ast.StaticType),
+		functions: make(map[string]*ast.FunctionDeclaration),
+	}
+}
+
+func (c *TypeChecker) closeScope() {
+	c.scope = c.scope.parent
+}
+
+func (c *TypeChecker) mismatch(message string, node ast.Stmter) {
+	c.errors = append(c.errors, diag.NewCustomError(message).AddSpan(node.Pos(), node.End()))
+}
+
+// lookup returns the type of a name and the declaration when it is a user
+// function, names declared nowhere are native functions or unknown.
+func (c *TypeChecker) lookup(name string) (ast.StaticType, *ast.FunctionDeclaration) {
+	for scope := c.scope; scope != nil; scope = scope.parent {
+		if t, exist := scope.variables[name]; exist {
+			return t, nil
+		}
+		if fn, exist := scope.functions[name]; exist {
+			return ast.TypeFunction, fn
+		}
+	}
+
+	switch name {
+	case "true", "false":
+		return ast.TypeBool, nil
+	case "null":
+		return ast.TypeNull, nil
+	}
+
+	if _, native := nativeSignatures[name]; native {
+		return ast.TypeFunction, nil
+	}
+
+	return ast.TypeAny, nil
+}
+
+// checkBody registers the functions of the block, then checks the
+// statements and returns the type of the last one, the value of the block.
+func (c *TypeChecker) checkBody(body []ast.Stmter) ast.StaticType {
+	for _, statement := range body {
+		if export, ok := statement.(*ast.ExportDeclaration); ok {
+			statement = export.Declaration
+		}
+		if fn, ok := statement.(*ast.FunctionDeclaration); ok {
+			c.scope.functions[fn.Name] = fn
+		}
+	}
+
+	var result ast.StaticType = ast.TypeNull
+	for _, statement := range body {
+		result = c.check(statement)
+	}
+
+	return result
+}
+
+func (c *TypeChecker) declare(name string, t ast.StaticType) {
+	delete(c.scope.functions, name)
+	c.scope.variables[name] = t
+}
+
+func annotated(annotation *ast.TypeAnnotation) ast.StaticType {
+	if annotation == nil {
+		return ast.TypeAny
+	}
+
+	return annotation.Name
+}
+
+// check checks a node and returns its type.
+func (c *TypeChecker) check(node ast.Stmter) ast.StaticType {
+	switch n := node.(type) {
+	case nil:
+		return ast.TypeNull
+	case *ast.IntegerLiteral:
+		return ast.TypeInt
+	case *ast.NumericLiteral:
+		return ast.TypeFloat
+	case *ast.StringLiteral:
+		return ast.TypeString
+	case *ast.Identifier:
+		t, _ := c.lookup(n.Symbol)
+		return t
+	case *ast.ArrayLiteral:
+		for _, element := range n.Elements {
+			c.check(element)
+		}
+		return ast.TypeArray
+	case *ast.ObjectLiteral:
+		for _, property := range n.Properties {
+			c.check(property.ComputedKey)
+			c.check(property.Value)
+		}
+		return ast.TypeObject
+	case *ast.VariableDeclaration:
+		return c.checkVarDeclaration(n)
+	case *ast.FunctionDeclaration:
+		c.checkFunction(n)
+		return ast.TypeFunction
+	case *ast.AssignmentExpr:
+		return c.checkAssignment(n)
+	case *ast.BinaryExpession:
+		return c.checkBinary(n)
+	case *ast.UnaryExpression:
+		c.check(n.Operand)
+		return ast.TypeInt
+	case *ast.ConditionDeclaration:
+		c.check(n.Left)
+		c.check(n.Right)
+		return ast.TypeBool
+	case *ast.CallExpression:
+		return c.checkCall(n)
+	case *ast.MemberExpression:
+		c.check(n.Object)
+		if n.Computed {
+			c.check(n.Propert)
+		}
+		return ast.TypeAny
+	case *ast.IfExpression:
+		for branch := n; branch != nil; {
+			c.checkCondition(branch.Condition)
+			c.checkBody(branch.Body)
+			branch, _ = branch.ElseExpression.(*ast.IfExpression)
+		}
+		return ast.TypeAny
+	case *ast.ForExpression:
+		c.check(n.Declaration)
+		c.checkCondition(n.Condition)
+		c.checkBody(n.Body)
+		c.checkCondition(n.AfterCondition)
+		c.check(n.IncrementalExpression)
+		return ast.TypeAny
+	case *ast.ForEachExpression:
+		iterable := c.check(n.Iterable)
+		c.openScope()
+		item := ast.TypeAny
+		switch {
+		case iterable == ast.TypeRange:
+			item = ast.TypeInt
+		case iterable == ast.TypeString:
+			item = ast.TypeString
+		}
+		c.declare(n.Identifier, item)
+		c.checkBody(n.Body)
+		c.closeScope()
+		return ast.TypeAny
+	case *ast.SwitchExpression:
+		c.check(n.Value)
+		for _, swcase := range n.Body {
+			c.openScope()
+			c.checkCaseCondition(swcase.Condition)
+			c.checkBody(swcase.Body)
+			c.closeScope()
+		}
+		return ast.TypeNull
+	case *ast.MatchExpression:
+		return c.checkMatch(n)
+	case *ast.ExportDeclaration:
+		return c.check(n.Declaration)
+	case *ast.ImportDeclaration:
+		if n.Namespace != "" {
+			c.declare(n.Namespace, ast.TypeObject)
+		}
+		for _, name := range n.Names {
+			c.declare(name.Alias, ast.TypeAny)
+		}
+		return ast.TypeNull
+	}
+
+	return ast.TypeAny
+}
+
+// checkCondition reports the condition of an if or a for known not to be a
+// bool, which fails once the script runs. An else has no condition.
+func (c *TypeChecker) checkCondition(condition ast.Stmter) {
+	if condition == nil {
+		return
+	}
+
+	if t := c.check(condition); !ast.TypeBool.Accepts(t) {
+		c.mismatch(fmt.Sprintf("Condition must be a bool, got %s", t), condition)
+	}
+}
+
+func (c *TypeChecker) checkVarDeclaration(declaration *ast.VariableDeclaration) ast.StaticType {
+	value := c.check(declaration.Value)
+
+	if declaration.Pattern != nil {
+		c.declarePattern(declaration.Pattern)
+		return value
+	}
+
+	expected := annotated(declaration.TypeAnnotation)
+	if declaration.Value != nil && !expected.Accepts(value) {
+		c.mismatch(fmt.Sprintf("Variable %s is declared %s, the value is %s", declaration.Identifier, expected, value), declaration.Value)
+	}
+
+	if declaration.TypeAnnotation == nil && declaration.Constant {
+		// A constant keeps the type of its value
+		expected = value
+	}
+	c.declare(declaration.Identifier, expected)
+
+	return value
+}
+
+func (c *TypeChecker) declarePattern(pattern ast.Stmter) {
+	switch p := pattern.(type) {
+	case *ast.Identifier:
+		switch p.Symbol {
+		case "_", "true", "false", "null":
+			return
+		}
+		c.declare(p.Symbol, ast.TypeAny)
+	case *ast.DestructuringPattern:
+		for _, element := range p.Elements {
+			c.check(element.DefaultValue)
+			c.declarePattern(element.Target)
+		}
+	}
+}
+
+func (c *TypeChecker) checkFunction(fn *ast.FunctionDeclaration) {
+	c.openScope()
+	for index, param := range fn.Parameters {
+		var annotation *ast.TypeAnnotation
+		if index < len(fn.ParameterTypes) {
+			annotation = fn.ParameterTypes[index]
+		}
+
+		if identifier, ok := param.(*ast.Identifier); ok {
+			c.declare(identifier.Symbol, annotated(annotation))
+			continue
+		}
+		c.declarePattern(param)
+	}
+
+	result := c.checkBody(fn.Body)
+	c.closeScope()
+
+	expected := annotated(fn.ReturnType)
+	if expected.Accepts(result) {
+		return
+	}
+
+	// The last statement is the returned value
+	var at ast.Stmter = fn
+	if len(fn.Body) > 0 {
+		at = fn.Body[len(fn.Body)-1]
+	}
+	c.mismatch(fmt.Sprintf("Function %s must return %s, the last statement is %s", fn.Name, expected, result), at)
+}
+
+func (c *TypeChecker) checkAssignment(assignment *ast.AssignmentExpr) ast.StaticType {
+	value := c.check(assignment.Value)
+
+	switch target := assignment.Assigne.(type) {
+	case *ast.Identifier:
+		expected, _ := c.lookup(target.Symbol)
+		if !expected.Accepts(value) {
+			c.mismatch(fmt.Sprintf("Variable %s is declared %s, the value is %s", target.Symbol, expected, value), assignment.Value)
+		}
+	case *ast.DestructuringPattern:
+		for _, element := range target.Elements {
+			c.check(element.DefaultValue)
+		}
+	default:
+		c.check(target)
+	}
+
+	return value
+}
+
+func (c *TypeChecker) checkBinary(binop *ast.BinaryExpession) ast.StaticType {
+	left := c.check(binop.Left)
+	right := c.check(binop.Right)
+
+	switch binop.Operator {
+	case "+":
+		if left == ast.TypeString && right == ast.TypeString {
+			return ast.TypeString
+		}
+		if left.Numeric() && right.Numeric() {
+			return arithmeticType(left, right)
+		}
+	case "-", "*", "**", "%", "~/":
+		if left.Numeric() && right.Numeric() {
+			return arithmeticType(left, right)
+		}
+	case "/":
+		return ast.TypeFloat
+	case "&", "|", "^", "<<", ">>":
+		return ast.TypeInt
+	}
+
+	return ast.TypeAny
+}
+
+// checkCall checks the arguments against the parameter types of user
+// functions and native functions, the result is the declared return type.
+func (c *TypeChecker) checkCall(call *ast.CallExpression) ast.StaticType {
+	args := make([]ast.StaticType, len(call.Args))
+	for index, arg := range call.Args {
+		args[index] = c.check(*arg)
+	}
+
+	identifier, ok := call.Caller.(*ast.Identifier)
+	if !ok {
+		c.check(call.Caller)
+		return ast.TypeAny
+	}
+
+	t, fn := c.lookup(identifier.Symbol)
+	if fn != nil {
+		for index, annotation := range fn.ParameterTypes {
+			if annotation != nil && index < len(args) && !annotation.Name.Accepts(args[index]) {
+				c.mismatch(fmt.Sprintf("Argument %d of %s must be %s, got %s", index+1, fn.Name, annotation.Name, args[index]), *call.Args[index])
+			}
+		}
+		return annotated(fn.ReturnType)
+	}
+
+	if signature, native := nativeSignatures[identifier.Symbol]; native && t == ast.TypeFunction {
+		if !signature.accepts(len(args)) {
+			c.mismatch(fmt.Sprintf("Function %s takes %s, got %d", identifier.Symbol, signature.arity(), len(args)), call)
+		}
+		for index, param := range signature.params {
+			if index < len(args) && !param.Accepts(args[index]) {
+				c.mismatch(fmt.Sprintf("Argument %d of %s must be %s, got %s", index+1, identifier.Symbol, param, args[index]), *call.Args[index])
+			}
+		}
+		return signature.result
+	}
+
+	return ast.TypeAny
+}
+
+func (c *TypeChecker) checkCaseCondition(condition *ast.CaseCondition) {
+	for _, value := range condition.Values {
+		c.check(value)
+	}
+
+	if condition.Binding != "" {
+		c.declare(condition.Binding, ast.TypeAny)
+	}
+
+	if condition.Pattern != nil {
+		c.declarePattern(condition.Pattern)
+	}
+
+	c.check(condition.Guard)
+}
+
+// checkMatch returns the type shared by all arms, or TypeAny when they differ.
+func (c *TypeChecker) checkMatch(match *ast.MatchExpression) ast.StaticType {
+	c.check(match.Value)
+
+	var result ast.StaticType
+	for _, arm := range match.Arms {
+		c.openScope()
+		c.checkCaseCondition(arm.Condition)
+		t := c.check(arm.Body)
+		c.closeScope()
+
+		if result == "" {
+			result = t
+		} else if result != t {
+			result = ast.TypeAny
+		}
+	}
+
+	if result == "" {
+		return ast.TypeAny
+	}
+
+	return result
+}
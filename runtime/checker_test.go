@@ -0,0 +1,76 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+)
+
+// typeErrors returns the errors of the type checker for a script.
+func typeErrors(t *testing.T, source string) []string {
+	t.Helper()
+
+	program, err := parser.NewParser().ProduceAST(source)
+	if err != nil {
+		t.Fatalf("%q: %v", source, err)
+	}
+
+	err = newTypeChecker().checkProgram(program)
+	if err == nil {
+		return nil
+	}
+
+	return spans(source, []*diag.CustomError{err})
+}
+
+func TestTypeChecker(t *testing.T) {
+	tests := []struct {
+		source string
+		errors []string
+	}{
+		{"let count: number = 0;", nil},
+		{"let count: int = 1.5;", []string{"Variable count is declared int, the value is float: 1.5"}},
+		{"let name: string = 1;", []string{"Variable name is declared string, the value is int: 1"}},
+		{"let ratio: float = 1 / 2;", nil},
+		{"let ratio: number = 2 * 0.5;", nil},
+		{"let done: bool = 1 < 2;", nil},
+		{"let name: string = 1;\nlet count: int = \"a\";", []string{
+			"Variable name is declared string, the value is int: 1",
+			"Variable count is declared int, the value is string: \"a\"",
+		}},
+		{"let count: int = 0;\ncount = \"a\"", []string{"Variable count is declared int, the value is string: \"a\""}},
+		{"fn add(a: number, b: number): number {\n    a + b\n}\nadd(1, \"b\")", []string{"Argument 2 of add must be number, got string: \"b\""}},
+		{"fn name(): string {\n    1\n}", []string{"Function name must return string, the last statement is int: 1"}},
+		{"let n = 1;\nlet s: string = n;", nil},
+		{"let n: int = 1;\nlet s: string = n;", []string{"Variable s is declared string, the value is int: n"}},
+		{"fn f(x) {\n    let s: string = x;\n}", nil},
+		{"substr(1, 0, 1)", []string{"Argument 1 of substr must be string, got int: 1"}},
+		{"let s: string = numToStr(1);", nil},
+		{"let n: string = strToNum(\"1\");", []string{"Variable n is declared string, the value is number: strToNum(\"1\")"}},
+		{"fileRead()", []string{"Function fileRead takes 1 argument, got 0: fileRead()"}},
+		{"sleep()", []string{"Function sleep takes 1 argument, got 0: sleep()"}},
+		{"numToStr(1, 2)", []string{"Function numToStr takes 1 argument, got 2: numToStr(1, 2)"}},
+		{"round(1.5, 1, 2)", []string{"Function round takes 1 to 2 arguments, got 3: round(1.5, 1, 2)"}},
+		{"push([])", []string{"Function push takes at least 2 arguments, got 1: push([])"}},
+		{"exec()", []string{"Function exec takes at least 1 argument, got 0: exec()"}},
+		{"time(1)", []string{"Function time takes 0 arguments, got 1: time(1)"}},
+		{"println()\nround(1.5)\nrange(5)\npush([], 1, 2)", nil},
+		{"let numToStr = 1;\nnumToStr", nil},
+		{"if (1) { 2 }", []string{"Condition must be a bool, got int: 1"}},
+		{"for (1) {}", []string{"Condition must be a bool, got int: 1"}},
+		{"for (let i = 0; \"x\"; i = i + 1) {}", []string{"Condition must be a bool, got string: \"x\""}},
+		{"if (null) { 1 }", []string{"Condition must be a bool, got null: null"}},
+		{"if (1 < 2) { 1 } elseif (\"a\") { 2 } else { 3 }", []string{"Condition must be a bool, got string: \"a\""}},
+		{"fn f(): int {\n    1\n}\nif (f()) { 2 }", []string{"Condition must be a bool, got int: f()"}},
+		{"let x = 1;\nif (x) { 2 }", nil},
+		{"if (true) { 1 } else { 2 }", nil},
+	}
+
+	for _, test := range tests {
+		if errors := typeErrors(t, test.source); !reflect.DeepEqual(errors, test.errors) {
+			t.Errorf("%q: expected %q, got %q", test.source, test.errors, errors)
+		}
+	}
+}
@@ -0,0 +1,757 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+// Compiler turns a resolved program into bytecode for the VM. Every node
+// leaves exactly one value on the stack like evaluate returns one, break
+// and continue are values checked by the loops as in the tree engine.
+//
+// depth tracks the values on the stack of the frame, loops and patterns
+// keep their state at known offsets below the values of their body.
+type Compiler struct {
+	chunk     *Chunk
+	depth     int
+	node      ast.Stmter
+	constants map[mapKey]int
+	err       *diag.CustomError
+	// failDepth is the depth the failed match pattern jumps restore
+	failDepth int
+}
+
+func newCompiler(node ast.Stmter) *Compiler {
+	return &Compiler{chunk: &Chunk{}, node: node, constants: make(map[mapKey]int)}
+}
+
+func CompileProgram(program *ast.Program) (*Chunk, *diag.CustomError) {
+	c := newCompiler(program)
+	c.compileBlock(program.Body)
+	c.emit(OpReturn)
+
+	return c.chunk, c.err
+}
+
+func (c *Compiler) fail(message string) {
+	if c.err == nil {
+		c.err = diag.NewCustomError(message)
+		if c.node != nil {
+			c.err.AddSpan(c.node.Pos(), c.node.End())
+		}
+	}
+}
+
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	offset := len(c.chunk.code)
+	c.chunk.mark(offset, c.node)
+	c.chunk.code = append(c.chunk.code, byte(op))
+
+	for _, operand := range operands {
+		if operand < 0 || operand > math.MaxUint16 {
+			c.fail(fmt.Sprintf("Program too large to compile, %s operand %d", op, operand))
+			operand = 0
+		}
+		c.chunk.code = append(c.chunk.code, byte(operand>>8), byte(operand))
+	}
+
+	c.depth += stackEffect(op, operands)
+
+	return offset
+}
+
+// stackEffect is the number of values an instruction adds to the stack,
+// negative when it removes values. Jumps taking another path are fixed up
+// where the paths join.
+func stackEffect(op Opcode, operands []int) int {
+	switch op {
+	case OpConstant, OpNull, OpDup, OpGetStack, OpGetLocal, OpGetName, OpObject, OpClosure, OpArg,
+		OpBreak, OpContinue, OpIsObject, OpArrayFits, OpImport, OpIterNext, OpFail:
+		return 1
+	case OpPatternProperty, OpPatternIndex:
+		return 2
+	case OpPop, OpSetStack, OpBinary, OpCompare, OpEqual, OpGetMember, OpReturn,
+		OpJumpIfFalse, OpJumpIfTrue, OpJumpIfNotTrue:
+		return -1
+	case OpSetProperty, OpSetMember:
+		return -2
+	case OpPopN, OpPopUnder, OpCall, OpTailCall:
+		return -operands[0]
+	case OpArray:
+		return 1 - operands[0]
+	}
+
+	return 0
+}
+
+// constant adds a value to the constants of the chunk, numbers and strings
+// are added once. Unlike object keys an integer and a float never share a
+// constant.
+func (c *Compiler) constant(value RuntimeVal) int {
+	var key *mapKey
+	switch v := value.(type) {
+	case *StringVal:
+		key = &mapKey{kind: ValueTypeString, str: v.Value}
+	case *IntVal:
+		key = &mapKey{kind: ValueTypeInteger, num: v.Value}
+	case *NumberVal:
+		key = &mapKey{kind: ValueTypeNumber, num: int64(math.Float64bits(v.Value))}
+	}
+
+	if key != nil {
+		if index, exist := c.constants[*key]; exist {
+			return index
+		}
+		c.constants[*key] = len(c.chunk.constants)
+	}
+
+	c.chunk.constants = append(c.chunk.constants, value)
+
+	return len(c.chunk.constants) - 1
+}
+
+func (c *Compiler) name(name string) int {
+	return c.constant(makeString(name))
+}
+
+// emitJump emits a jump to be patched once the target is known.
+func (c *Compiler) emitJump(op Opcode, operands ...int) int {
+	c.emit(op, append(operands, 0)...)
+
+	return len(c.chunk.code) - 2
+}
+
+func (c *Compiler) patch(jumps ...int) {
+	target := len(c.chunk.code)
+	if target > math.MaxUint16 {
+		c.fail("Program too large to compile, too much code to jump over")
+	}
+
+	for _, jump := range jumps {
+		c.chunk.code[jump] = byte(target >> 8)
+		c.chunk.code[jump+1] = byte(target)
+	}
+}
+
+// at sets the node the following instructions report errors with.
+func (c *Compiler) at(node ast.Stmter) func() {
+	outer := c.node
+	c.node = node
+
+	return func() { c.node = outer }
+}
+
+// compileBlock leaves the value of the last statement, null for no statements.
+func (c *Compiler) compileBlock(body []ast.Stmter) {
+	if len(body) == 0 {
+		c.emit(OpNull)
+		return
+	}
+
+	for index, statement := range body {
+		c.compile(statement)
+		if index < len(body)-1 {
+			c.emit(OpPop)
+		}
+	}
+}
+
+func (c *Compiler) compile(node ast.Stmter) {
+	defer c.at(node)()
+
+	switch n := node.(type) {
+	case *ast.NumericLiteral:
+		c.emit(OpConstant, c.constant(makeNumber(n.Value)))
+	case *ast.IntegerLiteral:
+		c.emit(OpConstant, c.constant(makeInteger(n.Value)))
+	case *ast.StringLiteral:
+		c.emit(OpConstant, c.constant(makeString(n.Value)))
+	case *ast.Program:
+		c.compileBlock(n.Body)
+	case *ast.Identifier:
+		c.load(n.Symbol, n.Slot)
+	case *ast.BinaryExpession:
+		c.compile(n.Left)
+		c.compile(n.Right)
+		c.emit(OpBinary, c.name(n.Operator))
+	case *ast.ConditionDeclaration:
+		c.compile(n.Left)
+		c.compile(n.Right)
+		c.emit(OpCompare, c.name(n.Operator))
+	case *ast.UnaryExpression:
+		c.compile(n.Operand)
+		c.emit(OpUnary, c.name(n.Operator))
+	case *ast.VariableDeclaration:
+		c.compileVarDeclaration(n)
+	case *ast.AssignmentExpr:
+		c.compileAssignment(n)
+	case *ast.ObjectLiteral:
+		c.compileObject(n)
+	case *ast.ArrayLiteral:
+		for _, element := range n.Elements {
+			c.compile(element)
+		}
+		c.emit(OpArray, len(n.Elements))
+	case *ast.MemberExpression:
+		c.compile(n.Object)
+		c.compileMemberKey(n)
+		c.emit(OpGetMember)
+	case *ast.CallExpression:
+		for _, arg := range n.Args {
+			c.compile(*arg)
+		}
+		c.compile(n.Caller)
+		if n.Tail {
+			c.emit(OpTailCall, len(n.Args))
+		} else {
+			c.emit(OpCall, len(n.Args))
+		}
+	case *ast.FunctionDeclaration:
+		c.compileFunction(n)
+	case *ast.IfExpression:
+		c.compileIf(n)
+	case *ast.ForExpression:
+		c.compileFor(n)
+	case *ast.ForEachExpression:
+		c.compileForEach(n)
+	case *ast.SwitchExpression:
+		c.compileSwitch(n)
+	case *ast.MatchExpression:
+		c.compileMatch(n)
+	case *ast.BreakExpression:
+		c.emit(OpBreak)
+	case *ast.ContinueExpression:
+		c.emit(OpContinue)
+	case *ast.ImportDeclaration:
+		c.chunk.imports = append(c.chunk.imports, n)
+		c.emit(OpImport, len(c.chunk.imports)-1)
+	case *ast.ExportDeclaration:
+		var names []RuntimeVal
+		for _, name := range declaredNames(n.Declaration) {
+			names = append(names, makeString(name))
+		}
+		c.emit(OpExport, c.constant(makeArray(names)))
+		c.compile(n.Declaration)
+	default:
+		c.emit(OpFail, c.name(fmt.Sprintf("This AST node has not yet been setup for interpretation %s", node.Kind())))
+	}
+}
+
+func (c *Compiler) load(name string, slot *ast.Slot) {
+	if slot == nil {
+		c.emit(OpGetName, c.name(name))
+		return
+	}
+
+	c.emit(OpGetLocal, slot.Depth, slot.Index, c.name(name))
+}
+
+func (c *Compiler) store(name string, slot *ast.Slot) {
+	if slot == nil {
+		c.emit(OpSetName, c.name(name))
+		return
+	}
+
+	c.emit(OpSetLocal, slot.Depth, slot.Index, c.name(name))
+}
+
+func (c *Compiler) declare(name string, slot *ast.Slot, constant bool) {
+	if slot == nil {
+		flag := 0
+		if constant {
+			flag = 1
+		}
+		c.emit(OpDeclareName, c.name(name), flag)
+		return
+	}
+
+	c.emit(OpDeclareLocal, slot.Index, c.name(name))
+}
+
+func (c *Compiler) compileVarDeclaration(declaration *ast.VariableDeclaration) {
+	if declaration.Value == nil {
+		c.emit(OpNull)
+		c.declare(declaration.Identifier, declaration.Slot, declaration.Constant)
+		return
+	}
+
+	c.compile(declaration.Value)
+	if declaration.Pattern != nil {
+		c.emit(OpDup)
+		c.compileBind(declaration.Pattern, func(target *ast.Identifier) {
+			c.declare(target.Symbol, target.Slot, declaration.Constant)
+		})
+		return
+	}
+
+	c.declare(declaration.Identifier, declaration.Slot, declaration.Constant)
+}
+
+func (c *Compiler) compileAssignment(assignment *ast.AssignmentExpr) {
+	switch target := assignment.Assigne.(type) {
+	case *ast.MemberExpression:
+		c.compile(target.Object)
+		c.compileMemberKey(target)
+		c.compile(assignment.Value)
+		c.emit(OpSetMember)
+	case *ast.DestructuringPattern:
+		c.compile(assignment.Value)
+		c.emit(OpDup)
+		c.compileBind(target, func(target *ast.Identifier) {
+			c.store(target.Symbol, target.Slot)
+		})
+	case *ast.Identifier:
+		c.compile(assignment.Value)
+		c.store(target.Symbol, target.Slot)
+	default:
+		c.emit(OpFail, c.name("Invalid LHS iside assignment expression"))
+	}
+}
+
+func (c *Compiler) compileMemberKey(member *ast.MemberExpression) {
+	if !member.Computed {
+		c.emit(OpConstant, c.name(member.Propert.(*ast.Identifier).Symbol))
+		return
+	}
+
+	c.compile(member.Propert)
+}
+
+func (c *Compiler) compileObject(object *ast.ObjectLiteral) {
+	c.emit(OpObject)
+
+	for _, property := range object.Properties {
+		if property.ComputedKey != nil {
+			c.compile(property.ComputedKey)
+		} else {
+			c.emit(OpConstant, c.name(property.Key))
+		}
+
+		if property.Value == nil {
+			c.load(property.Key, property.Slot)
+		} else {
+			c.compile(property.Value)
+		}
+
+		restore := c.at(property)
+		c.emit(OpSetProperty)
+		restore()
+	}
+}
+
+// compileFunction compiles the body in its own chunk, the code starts with
+// binding the arguments to the parameters.
+func (c *Compiler) compileFunction(fn *ast.FunctionDeclaration) {
+	body := newCompiler(fn)
+	for index, param := range fn.Parameters {
+		body.emit(OpArg, index)
+		body.compileBind(param, func(target *ast.Identifier) {
+			body.declare(target.Symbol, target.Slot, false)
+		})
+	}
+	body.compileBlock(fn.Body)
+	body.emit(OpReturn)
+
+	if body.err != nil && c.err == nil {
+		c.err = body.err
+	}
+
+	c.chunk.functions = append(c.chunk.functions, &FunctionProto{name: fn.Name, locals: fn.Locals, chunk: body.chunk})
+	c.emit(OpClosure, len(c.chunk.functions)-1)
+	c.declare(fn.Name, fn.Slot, true)
+}
+
+// compileBind binds the value on top of the stack to a destructuring
+// target and pops it, bind stores a single name.
+func (c *Compiler) compileBind(pattern ast.Stmter, bind func(target *ast.Identifier)) {
+	defer c.at(pattern)()
+
+	switch target := pattern.(type) {
+	case *ast.Identifier:
+		bind(target)
+		c.emit(OpPop)
+	case *ast.DestructuringPattern:
+		if target.Kind() == ast.NodeTypeObjectPattern {
+			c.emit(OpCheckObject)
+		} else {
+			c.emit(OpCheckArray)
+		}
+
+		for index, element := range target.Elements {
+			if target.Kind() == ast.NodeTypeObjectPattern {
+				c.emit(OpPatternProperty, c.name(element.Key))
+			} else {
+				c.emit(OpPatternIndex, index)
+			}
+			c.emit(OpPop)
+
+			// Missing parts and null take the default
+			if element.DefaultValue != nil {
+				skip := c.emitJump(OpJumpIfNotNull)
+				c.emit(OpPop)
+				c.compile(element.DefaultValue)
+				c.patch(skip)
+			}
+
+			c.compileBind(element.Target, bind)
+		}
+		c.emit(OpPop)
+	default:
+		c.emit(OpFail, c.name("Invalid destructuring target"))
+		c.emit(OpPopN, 2)
+	}
+}
+
+func (c *Compiler) compileIf(ifE *ast.IfExpression) {
+	if ifE.Condition == nil {
+		c.compileBlock(ifE.Body)
+		return
+	}
+
+	otherwise := c.compileCondition(ifE.Condition)
+	c.compileBlock(ifE.Body)
+	end := c.emitJump(OpJump)
+
+	c.patch(otherwise)
+	c.depth--
+	if ifE.ElseExpression != nil {
+		c.compileIf(ifE.ElseExpression.(*ast.IfExpression))
+	} else {
+		c.emit(OpNull)
+	}
+	c.patch(end)
+}
+
+// compileCondition compiles an if or for condition and the jump taken when
+// it is false, a condition which is not a bool is reported at it.
+func (c *Compiler) compileCondition(condition ast.Stmter) int {
+	c.compile(condition)
+	defer c.at(condition)()
+
+	return c.emitJump(OpJumpIfFalse)
+}
+
+// compileLoopBody compiles the statements of a loop body, result is the
+// offset keeping the value of the last statement. It returns the jumps
+// taken on break and continue, both with the statement value on top.
+func (c *Compiler) compileLoopBody(body []ast.Stmter, result int) ([]int, []int) {
+	var breaks, continues []int
+	for _, statement := range body {
+		c.compile(statement)
+		c.emit(OpDup)
+		c.emit(OpSetStack, result)
+		breaks = append(breaks, c.emitJump(OpJumpIfBreak))
+		continues = append(continues, c.emitJump(OpJumpIfContinue))
+		c.emit(OpPop)
+	}
+
+	return breaks, continues
+}
+
+// compileFor keeps a continued flag and the result below the body. Like
+// the tree engine, once the body continued the after condition and the
+// increment are skipped, and the value of the loop is the one of the last
+// statement run, break included.
+func (c *Compiler) compileFor(forE *ast.ForExpression) {
+	if forE.Declaration != nil {
+		c.compile(forE.Declaration)
+		c.emit(OpPop)
+	}
+
+	continued := c.depth
+	c.emit(OpConstant, c.constant(makeBool(false)))
+	result := c.depth
+	c.emit(OpNull)
+
+	top := len(c.chunk.code)
+	var exits []int
+	if forE.Condition != nil {
+		exits = append(exits, c.compileCondition(forE.Condition))
+	}
+
+	breaks, continues := c.compileLoopBody(forE.Body, result)
+
+	c.emit(OpGetStack, continued)
+	c.emit(OpJumpIfTrue, top)
+
+	if forE.AfterCondition != nil {
+		exits = append(exits, c.compileCondition(forE.AfterCondition))
+	}
+
+	if forE.IncrementalExpression != nil {
+		c.compile(forE.IncrementalExpression)
+		c.emit(OpPop)
+	}
+	c.emit(OpJump, top)
+
+	c.patch(continues...)
+	c.depth = result + 2
+	c.emit(OpPop)
+	c.emit(OpConstant, c.constant(makeBool(true)))
+	c.emit(OpSetStack, continued)
+	c.emit(OpJump, top)
+
+	c.patch(breaks...)
+	c.depth = result + 2
+	c.emit(OpPop)
+
+	c.patch(exits...)
+	c.depth = result + 1
+	c.emit(OpPopUnder, 1)
+}
+
+// compileForEach keeps the iterator and the result below the body, every
+// item runs in a fresh scope.
+func (c *Compiler) compileForEach(forE *ast.ForEachExpression) {
+	c.compile(forE.Iterable)
+	iterator := c.depth - 1
+	flag := 0
+	if forE.Keys {
+		flag = 1
+	}
+	c.emit(OpIterStart, flag)
+	result := c.depth
+	c.emit(OpNull)
+
+	top := len(c.chunk.code)
+	done := c.emitJump(OpIterNext, iterator)
+	c.emit(OpPushScope, forE.Locals)
+	c.declare(forE.Identifier, forE.Slot, forE.Constant)
+	c.emit(OpPop)
+
+	breaks, continues := c.compileLoopBody(forE.Body, result)
+	c.emit(OpPopScope)
+	c.emit(OpJump, top)
+
+	// break and continue leave null as the value of the loop
+	c.patch(continues...)
+	c.depth = result + 2
+	c.emit(OpPop)
+	c.emit(OpNull)
+	c.emit(OpSetStack, result)
+	c.emit(OpPopScope)
+	c.emit(OpJump, top)
+
+	c.patch(breaks...)
+	c.depth = result + 2
+	c.emit(OpPop)
+	c.emit(OpNull)
+	c.emit(OpSetStack, result)
+	c.emit(OpPopScope)
+	end := c.emitJump(OpJump)
+
+	c.patch(done, end)
+	c.depth = result + 1
+	c.emit(OpPopUnder, 1)
+}
+
+// compileSwitch checks every case in order, a matching case without break
+// goes on checking the following ones. The value of a switch is null.
+func (c *Compiler) compileSwitch(sw *ast.SwitchExpression) {
+	c.compile(sw.Value)
+	subject := c.depth - 1
+
+	var ends []int
+	for _, swcase := range sw.Body {
+		failed, scoped := c.compileCaseCondition(swcase.Condition, subject)
+
+		var breaks []int
+		for _, statement := range swcase.Body {
+			c.compile(statement)
+			breaks = append(breaks, c.emitJump(OpJumpIfBreak))
+			c.emit(OpPop)
+		}
+		if scoped {
+			c.emit(OpPopScope)
+		}
+		next := c.emitJump(OpJump)
+
+		c.patch(breaks...)
+		c.depth = subject + 2
+		c.emit(OpPop)
+		if scoped {
+			c.emit(OpPopScope)
+		}
+		ends = append(ends, c.emitJump(OpJump))
+
+		c.patch(append(failed, next)...)
+		c.depth = subject + 1
+	}
+
+	c.patch(ends...)
+	c.emit(OpPop)
+	c.emit(OpNull)
+}
+
+func (c *Compiler) compileMatch(match *ast.MatchExpression) {
+	c.compile(match.Value)
+	subject := c.depth - 1
+
+	var ends []int
+	for _, arm := range match.Arms {
+		failed, scoped := c.compileCaseCondition(arm.Condition, subject)
+		c.compile(arm.Body)
+		if scoped {
+			c.emit(OpPopScope)
+		}
+		c.emit(OpPopUnder, 1)
+		ends = append(ends, c.emitJump(OpJump))
+
+		c.patch(failed...)
+		c.depth = subject + 1
+	}
+
+	c.emit(OpMatchFail)
+	c.patch(ends...)
+}
+
+// compileCaseCondition checks a switch case or a match arm against the
+// subject at its offset. On a match the body follows in the scope of the
+// bound names, scoped tells whether one was pushed. The returned jumps are
+// taken when the case does not match, with the scope already popped.
+func (c *Compiler) compileCaseCondition(condition *ast.CaseCondition, subject int) ([]int, bool) {
+	matched := c.depth
+	c.emit(OpConstant, c.constant(makeBool(condition.Wildcard)))
+
+	// Values are compared in the enclosing scope, the first equal one wins
+	var found []int
+	for _, value := range condition.Values {
+		c.emit(OpGetStack, subject)
+		c.compile(value)
+		c.emit(OpEqual)
+		different := c.emitJump(OpJumpIfFalse)
+		c.emit(OpConstant, c.constant(makeBool(true)))
+		c.emit(OpSetStack, matched)
+		found = append(found, c.emitJump(OpJump))
+		c.patch(different)
+	}
+	c.patch(found...)
+
+	scoped := condition.Binding != "" || condition.Pattern != nil
+	if scoped {
+		c.emit(OpPushScope, condition.Locals)
+	}
+
+	if condition.Binding != "" {
+		c.emit(OpGetStack, subject)
+		c.declare(condition.Binding, condition.BindingSlot, false)
+		c.emit(OpPop)
+		c.emit(OpConstant, c.constant(makeBool(true)))
+		c.emit(OpSetStack, matched)
+	}
+
+	if condition.Pattern != nil {
+		c.emit(OpGetStack, subject)
+		c.compileMatchPattern(condition.Pattern)
+		c.emit(OpSetStack, matched)
+	}
+
+	c.emit(OpGetStack, matched)
+	failed := []int{c.emitJump(OpJumpIfFalse)}
+	if condition.Guard != nil {
+		c.compile(condition.Guard)
+		failed = append(failed, c.emitJump(OpJumpIfNotTrue))
+	}
+	c.emit(OpPop)
+	success := c.emitJump(OpJump)
+
+	c.patch(failed...)
+	c.depth = matched + 1
+	c.emit(OpPop)
+	if scoped {
+		c.emit(OpPopScope)
+	}
+	otherwise := c.emitJump(OpJump)
+
+	c.patch(success)
+	c.depth = matched
+
+	return []int{otherwise}, scoped
+}
+
+// compileMatchPattern replaces the value on top of the stack with whether
+// it has the shape of the pattern, captured names are declared on the way.
+func (c *Compiler) compileMatchPattern(pattern ast.Stmter) {
+	outer := c.failDepth
+	c.failDepth = c.depth - 1
+
+	var failed []int
+	c.matchPattern(pattern, &failed)
+	c.emit(OpConstant, c.constant(makeBool(true)))
+	end := c.emitJump(OpJump)
+
+	c.patch(failed...)
+	c.depth = c.failDepth
+	c.emit(OpConstant, c.constant(makeBool(false)))
+	c.patch(end)
+
+	c.failDepth = outer
+}
+
+// failUnless pops the bool on top of the stack, when false the values down
+// to failDepth are popped and the pattern fails.
+func (c *Compiler) failUnless(failed *[]int) {
+	ok := c.emitJump(OpJumpIfTrue)
+	depth := c.depth
+	if extra := c.depth - c.failDepth; extra > 0 {
+		c.emit(OpPopN, extra)
+	}
+	*failed = append(*failed, c.emitJump(OpJump))
+	c.patch(ok)
+	c.depth = depth
+}
+
+// matchPattern consumes the value on top of the stack when it matches.
+func (c *Compiler) matchPattern(pattern ast.Stmter, failed *[]int) {
+	defer c.at(pattern)()
+
+	switch target := pattern.(type) {
+	case *ast.Identifier:
+		switch target.Symbol {
+		case "_":
+			c.emit(OpPop)
+		case "true", "false", "null":
+			c.emit(OpGetName, c.name(target.Symbol))
+			c.emit(OpEqual)
+			c.failUnless(failed)
+		default:
+			c.declare(target.Symbol, target.Slot, false)
+			c.emit(OpPop)
+		}
+	case *ast.DestructuringPattern:
+		object := target.Kind() == ast.NodeTypeObjectPattern
+		if object {
+			c.emit(OpIsObject)
+		} else {
+			c.emit(OpArrayFits, len(target.Elements))
+		}
+		c.failUnless(failed)
+
+		for index, element := range target.Elements {
+			if object {
+				c.emit(OpPatternProperty, c.name(element.Key))
+			} else {
+				c.emit(OpPatternIndex, index)
+			}
+
+			if element.DefaultValue == nil {
+				c.failUnless(failed)
+			} else {
+				exist := c.emitJump(OpJumpIfTrue)
+				c.emit(OpPop)
+				c.compile(element.DefaultValue)
+				c.patch(exist)
+			}
+
+			c.matchPattern(element.Target, failed)
+		}
+		c.emit(OpPop)
+	default:
+		c.compile(pattern)
+		c.emit(OpEqual)
+		c.failUnless(failed)
+	}
+}
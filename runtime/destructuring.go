@@ -0,0 +1,188 @@
+package runtime
+
+import (
+	"fmt"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+// bindFunc declares or assigns a single name resolved from a pattern.
+type bindFunc func(target *ast.Identifier, value RuntimeVal) (RuntimeVal, *diag.CustomError)
+
+func (i *Interpreter) declarePattern(pattern ast.Stmter, value RuntimeVal, env *Environments, constant bool) *diag.CustomError {
+	return i.bindPattern(pattern, value, env, func(target *ast.Identifier, value RuntimeVal) (RuntimeVal, *diag.CustomError) {
+		return env.declareVarAt(target.Symbol, target.Slot, value, constant)
+	})
+}
+
+func (i *Interpreter) assignPattern(pattern ast.Stmter, value RuntimeVal, env *Environments) *diag.CustomError {
+	return i.bindPattern(pattern, value, env, func(target *ast.Identifier, value RuntimeVal) (RuntimeVal, *diag.CustomError) {
+		return env.assignVarAt(target.Symbol, target.Slot, value)
+	})
+}
+
+// bindPattern walks the pattern and the value together, defaults are
+// evaluated in env when the matching part of the value is missing or null.
+func (i *Interpreter) bindPattern(pattern ast.Stmter, value RuntimeVal, env *Environments, bind bindFunc) *diag.CustomError {
+	switch target := pattern.(type) {
+	case *ast.Identifier:
+		_, err := bind(target, value)
+		if err != nil {
+			return err.AddSpan(target.Pos(), target.End())
+		}
+		return nil
+	case *ast.DestructuringPattern:
+		if target.Kind() == ast.NodeTypeObjectPattern {
+			return i.bindObjectPattern(target, value, env, bind)
+		}
+		return i.bindArrayPattern(target, value, env, bind)
+	}
+
+	return diag.NewCustomError("Invalid destructuring target").AddSpan(pattern.Pos(), pattern.End())
+}
+
+func (i *Interpreter) bindObjectPattern(pattern *ast.DestructuringPattern, value RuntimeVal, env *Environments, bind bindFunc) *diag.CustomError {
+	object, ok := value.(*ObjectVal)
+	if !ok {
+		return diag.NewCustomError(fmt.Sprintf("Cannot destructure %s as object", displayValue(value))).AddSpan(pattern.Pos(), pattern.End())
+	}
+
+	for _, element := range pattern.Elements {
+		property, _ := object.getProperty(element.Key)
+		err := i.bindPatternElement(element, property, env, bind)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpreter) bindArrayPattern(pattern *ast.DestructuringPattern, value RuntimeVal, env *Environments, bind bindFunc) *diag.CustomError {
+	array, ok := value.(*ArrayVal)
+	if !ok {
+		return diag.NewCustomError(fmt.Sprintf("Cannot destructure %s as array", displayValue(value))).AddSpan(pattern.Pos(), pattern.End())
+	}
+
+	for index, element := range pattern.Elements {
+		var item RuntimeVal
+		if index < len(array.elements) {
+			item = array.elements[index]
+		}
+
+		err := i.bindPatternElement(element, item, env, bind)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpreter) bindPatternElement(element *ast.PatternElement, value RuntimeVal, env *Environments, bind bindFunc) *diag.CustomError {
+	if _, isNull := value.(*NullVal); value == nil || isNull {
+		value = makeNull()
+		if element.DefaultValue != nil {
+			var err *diag.CustomError
+			value, err = i.evaluate(element.DefaultValue, env)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return i.bindPattern(element.Target, value, env, bind)
+}
+
+// matchPattern checks the shape of value against a match arm pattern and
+// declares the captured names in scope. Object patterns require the listed
+// keys to exist, array patterns require the same length unless the missing
+// elements have defaults.
+func (i *Interpreter) matchPattern(pattern ast.Stmter, value RuntimeVal, scope *Environments) (bool, *diag.CustomError) {
+	switch target := pattern.(type) {
+	case *ast.Identifier:
+		switch target.Symbol {
+		case "_":
+			return true, nil
+		case "true", "false", "null":
+			literal, err := scope.lookupVar(target.Symbol)
+			if err != nil {
+				return false, err
+			}
+			return valuesEqual(value, literal), nil
+		}
+
+		_, err := scope.declareVarAt(target.Symbol, target.Slot, value, false)
+		if err != nil {
+			return false, err.AddSpan(target.Pos(), target.End())
+		}
+		return true, nil
+	case *ast.DestructuringPattern:
+		if target.Kind() == ast.NodeTypeObjectPattern {
+			return i.matchObjectPattern(target, value, scope)
+		}
+		return i.matchArrayPattern(target, value, scope)
+	default:
+		literal, err := i.evaluate(pattern, scope)
+		if err != nil {
+			return false, err
+		}
+		return valuesEqual(value, literal), nil
+	}
+}
+
+func (i *Interpreter) matchObjectPattern(pattern *ast.DestructuringPattern, value RuntimeVal, scope *Environments) (bool, *diag.CustomError) {
+	object, ok := value.(*ObjectVal)
+	if !ok {
+		return false, nil
+	}
+
+	for _, element := range pattern.Elements {
+		property, exist := object.getProperty(element.Key)
+		matched, err := i.matchPatternElement(element, property, exist, scope)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (i *Interpreter) matchArrayPattern(pattern *ast.DestructuringPattern, value RuntimeVal, scope *Environments) (bool, *diag.CustomError) {
+	array, ok := value.(*ArrayVal)
+	if !ok || len(array.elements) > len(pattern.Elements) {
+		return false, nil
+	}
+
+	for index, element := range pattern.Elements {
+		var item RuntimeVal
+		exist := index < len(array.elements)
+		if exist {
+			item = array.elements[index]
+		}
+
+		matched, err := i.matchPatternElement(element, item, exist, scope)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (i *Interpreter) matchPatternElement(element *ast.PatternElement, value RuntimeVal, exist bool, scope *Environments) (bool, *diag.CustomError) {
+	if !exist {
+		if element.DefaultValue == nil {
+			return false, nil
+		}
+
+		var err *diag.CustomError
+		value, err = i.evaluate(element.DefaultValue, scope)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return i.matchPattern(element.Target, value, scope)
+}
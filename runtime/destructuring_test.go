@@ -0,0 +1,39 @@
+package runtime
+
+import "testing"
+
+func TestDestructuringAssignmentDefaults(t *testing.T) {
+	tests := []struct {
+		source string
+		result string
+	}{
+		{"let x = 0;\nlet y = 0;\n[x, y = 7] = [5]\nlet r = [x, y];\nr", "[5, 7]"},
+		{"let x = 0;\nlet y = 0;\n[x, y = 7] = [5, 6]\nlet r = [x, y];\nr", "[5, 6]"},
+		{"let x = 0;\nlet y = 0;\n[x, y = x + 1] = [10]\nlet r = [x, y];\nr", "[10, 11]"},
+		{"let x = 0;\nlet y = 0;\nlet z = 0;\n[x = 1, [y, z = 3] = [8]] = []\nlet r = [x, y, z];\nr", "[1, 8, 3]"},
+		{"let x = 0;\nlet y = 0;\n{ a: x, b: y = 9 } = { a: 4 }\nlet r = [x, y];\nr", "[4, 9]"},
+		{"let x = 0;\nlet y = 0;\n{ a: [x, y = 2] = [6] } = {}\nlet r = [x, y];\nr", "[6, 2]"},
+	}
+
+	for _, engine := range engines {
+		for _, optimize := range []bool{false, true} {
+			for _, test := range tests {
+				result, err := runSource(t, engine, optimize, test.source)
+				if err != nil {
+					t.Errorf("%s optimize=%v %q: %v", engine, optimize, test.source, err)
+					continue
+				}
+				if got := displayValue(result); got != test.result {
+					t.Errorf("%s optimize=%v %q: expected %s, got %s", engine, optimize, test.source, test.result, got)
+				}
+			}
+		}
+	}
+}
+
+func TestDestructuringAssignmentInvalidTarget(t *testing.T) {
+	_, err := runSource(t, EngineTree, false, "let x = 0;\n[x, 1 = 2] = [5]")
+	if err == nil || err.Message != "Invalid destructuring assignment target" {
+		t.Errorf("expected an invalid target error, got %v", err)
+	}
+}
@@ -0,0 +1,390 @@
+package runtime
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+type Environments struct {
+	parent    *Environments
+	variables map[string]RuntimeVal
+	constants map[string]interface{}
+	// slots are the local variables placed by the resolver, the maps of a
+	// local scope are only made when a name without a slot is declared
+	slots  []taggedVal
+	module *Module
+	// permissions of the scripts run in a root environment, the natives
+	// declared in it check them
+	permissions *Permissions
+}
+
+// NewEnvironments makes a scope in parent, a root environment when parent
+// is nil, granting every capability.
+func NewEnvironments(parent *Environments) (*Environments, *diag.CustomError) {
+	if parent == nil {
+		return NewSandboxEnvironments(AllowAll())
+	}
+
+	return &Environments{
+		parent:    parent,
+		variables: make(map[string]RuntimeVal),
+		constants: make(map[string]interface{}),
+	}, nil
+}
+
+// NewSandboxEnvironments makes a root environment whose natives fail
+// unless permissions grant the capability they use.
+func NewSandboxEnvironments(permissions *Permissions) (*Environments, *diag.CustomError) {
+	e := &Environments{
+		variables:   make(map[string]RuntimeVal),
+		constants:   make(map[string]interface{}),
+		permissions: permissions,
+	}
+
+	err := e.declareDefaultEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// newLocalEnvironments makes the scope of a function call, an iteration or
+// a case with room for its resolved local variables.
+func newLocalEnvironments(parent *Environments, locals int) *Environments {
+	e := &Environments{parent: parent}
+	if locals > 0 {
+		e.slots = make([]taggedVal, locals)
+	}
+
+	return e
+}
+
+func (e *Environments) declareVar(varName string, value RuntimeVal, constant bool) (RuntimeVal, *diag.CustomError) {
+	if e.variables == nil {
+		e.variables = make(map[string]RuntimeVal)
+		e.constants = make(map[string]interface{})
+	}
+
+	_, exist := e.variables[varName]
+	if exist {
+		return nil, diag.NewCustomError(
+			fmt.Sprintf("Variable %s already exists", varName),
+		)
+	}
+	e.variables[varName] = value
+	if constant {
+		e.constants[varName] = nil
+	}
+
+	return value, nil
+}
+
+func (e *Environments) assignVar(varName string, value RuntimeVal) (RuntimeVal, *diag.CustomError) {
+	env, err := e.resolve(varName)
+	if err != nil {
+		return nil, err
+	}
+
+	_, exist := e.constants[varName]
+	if exist {
+		return nil, diag.NewCustomError(fmt.Sprintf("Constant variable %s cannot be updated", varName))
+	}
+
+	env.variables[varName] = value
+
+	return value, nil
+}
+
+func (e *Environments) resolve(varName string) (*Environments, *diag.CustomError) {
+	_, exist := e.variables[varName]
+	if exist {
+		return e, nil
+	}
+	if e.parent == nil {
+		return nil, diag.NewCustomError(fmt.Sprintf("Variable %s could not be resolved", varName))
+	}
+
+	return e.parent.resolve(varName)
+}
+
+func (e *Environments) root() *Environments {
+	if e.parent == nil {
+		return e
+	}
+
+	return e.parent.root()
+}
+
+func (e *Environments) lookupVar(varName string) (RuntimeVal, *diag.CustomError) {
+	env, err := e.resolve(varName)
+	if err != nil {
+		return nil, err
+	}
+
+	value := env.variables[varName]
+
+	return value, nil
+}
+
+// declareVarAt declares a variable in its slot, or by name when the
+// resolver did not place it. Constants of a slot are not recorded, the
+// resolver rejects assignments to them before the script runs.
+func (e *Environments) declareVarAt(varName string, slot *ast.Slot, value RuntimeVal, constant bool) (RuntimeVal, *diag.CustomError) {
+	if slot == nil {
+		return e.declareVar(varName, value, constant)
+	}
+
+	if err := e.declareSlot(varName, slot.Index, tag(value)); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (e *Environments) declareSlot(varName string, index int, value taggedVal) *diag.CustomError {
+	if !e.slots[index].empty() {
+		return diag.NewCustomError(fmt.Sprintf("Variable %s already exists", varName))
+	}
+	e.slots[index] = value
+
+	return nil
+}
+
+func (e *Environments) assignVarAt(varName string, slot *ast.Slot, value RuntimeVal) (RuntimeVal, *diag.CustomError) {
+	if slot == nil {
+		return e.assignVar(varName, value)
+	}
+
+	if err := e.ancestor(slot.Depth).assignSlot(varName, slot.Index, tag(value)); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (e *Environments) assignSlot(varName string, index int, value taggedVal) *diag.CustomError {
+	if e.slots[index].empty() {
+		return diag.NewCustomError(fmt.Sprintf("Variable %s could not be resolved", varName))
+	}
+	e.slots[index] = value
+
+	return nil
+}
+
+// lookupVarAt reads a variable from its slot, a slot is empty when the
+// declaration has not run yet, like a function called before a variable
+// it uses is declared. An inline number is boxed in its slot, the next
+// reads get the same box.
+func (e *Environments) lookupVarAt(varName string, slot *ast.Slot) (RuntimeVal, *diag.CustomError) {
+	if slot == nil {
+		return e.lookupVar(varName)
+	}
+
+	value := &e.ancestor(slot.Depth).slots[slot.Index]
+	if value.empty() {
+		return nil, diag.NewCustomError(fmt.Sprintf("Variable %s could not be resolved", varName))
+	}
+
+	return value.box(), nil
+}
+
+// lookupTaggedAt reads a variable like lookupVarAt, leaving numbers inline.
+func (e *Environments) lookupTaggedAt(varName string, slot *ast.Slot) (taggedVal, *diag.CustomError) {
+	if slot == nil {
+		value, err := e.lookupVar(varName)
+		return tag(value), err
+	}
+
+	value := e.ancestor(slot.Depth).slots[slot.Index]
+	if value.empty() {
+		return taggedVal{}, diag.NewCustomError(fmt.Sprintf("Variable %s could not be resolved", varName))
+	}
+
+	return value, nil
+}
+
+func (e *Environments) ancestor(depth int) *Environments {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.parent
+	}
+
+	return env
+}
+
+// nativeSignatures are the static types of the native functions declared
+// by declareDefaultEnv, the type checker uses them for calls. The result is
+// the type on success, most natives return null for invalid arguments.
+var nativeSignatures = map[string]NativeSignature{
+	"print":     {variadic: true, result: ast.TypeNull},
+	"println":   {variadic: true, result: ast.TypeNull},
+	"time":      {result: ast.TypeInt},
+	"numToStr":  {params: []ast.StaticType{ast.TypeNumber}, result: ast.TypeString},
+	"strToNum":  {params: []ast.StaticType{ast.TypeString}, result: ast.TypeNumber},
+	"input":     {result: ast.TypeString},
+	"int":       {params: []ast.StaticType{ast.TypeAny}, result: ast.TypeInt},
+	"float":     {params: []ast.StaticType{ast.TypeAny}, result: ast.TypeFloat},
+	"round":     {params: []ast.StaticType{ast.TypeNumber, ast.TypeNumber}, optional: 1, result: ast.TypeNumber},
+	"rand":      {params: []ast.StaticType{ast.TypeNumber}, optional: 1, result: ast.TypeInt},
+	"len":       {params: []ast.StaticType{ast.TypeAny}, result: ast.TypeInt},
+	"substr":    {params: []ast.StaticType{ast.TypeString, ast.TypeNumber, ast.TypeNumber}, result: ast.TypeString},
+	"range":     {params: []ast.StaticType{ast.TypeNumber, ast.TypeNumber, ast.TypeNumber}, optional: 2, result: ast.TypeRange},
+	"push":      {params: []ast.StaticType{ast.TypeArray, ast.TypeAny}, variadic: true, result: ast.TypeInt},
+	"keys":      {params: []ast.StaticType{ast.TypeObject}, result: ast.TypeArray},
+	"values":    {params: []ast.StaticType{ast.TypeObject}, result: ast.TypeArray},
+	"entries":   {params: []ast.StaticType{ast.TypeObject}, result: ast.TypeArray},
+	"has":       {params: []ast.StaticType{ast.TypeObject, ast.TypeAny}, result: ast.TypeBool},
+	"delete":    {params: []ast.StaticType{ast.TypeObject, ast.TypeAny}, result: ast.TypeBool},
+	"sleep":     {params: []ast.StaticType{ast.TypeNumber}, result: ast.TypeNull},
+	"fileRead":  {params: []ast.StaticType{ast.TypeString}, result: ast.TypeAny},
+	"fileWrite": {params: []ast.StaticType{ast.TypeString, ast.TypeString}, result: ast.TypeBool},
+	"getEnv":    {params: []ast.StaticType{ast.TypeString}, result: ast.TypeAny},
+	"exec":      {params: []ast.StaticType{ast.TypeString}, variadic: true, result: ast.TypeAny},
+}
+
+func (e *Environments) declareDefaultEnv() *diag.CustomError {
+	rand.Seed(time.Now().UnixNano())
+	p := e.permissions
+	_, err := e.declareVar("null", makeNull(), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("true", makeBool(true), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("false", makeBool(false), true)
+	if err != nil {
+		return err
+	}
+
+	// Define native function
+	_, err = e.declareVar("print", makeGuardedNativeFn(ntPrint, p.guard(CapStdout, "print")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("println", makeGuardedNativeFn(ntPrintLn, p.guard(CapStdout, "println")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("time", makeGuardedNativeFn(ntTime, p.guard(CapClock, "time")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("numToStr", makeNativeFn(ntNumToString), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("strToNum", makeNativeFn(ntStringToNum), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("input", makeGuardedNativeFn(ntInput, p.guard(CapStdin, "input")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("int", makeNativeFn(ntInt), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("float", makeNativeFn(ntFloat), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("round", makeNativeFn(ntRound), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("rand", makeGuardedNativeFn(ntRand, p.guard(CapRandom, "rand")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("len", makeNativeFn(ntLen), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("substr", makeNativeFn(ntSubstr), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("range", makeNativeFn(ntRange), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("push", makeNativeFn(ntPush), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("keys", makeNativeFn(ntKeys), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("values", makeNativeFn(ntValues), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("entries", makeNativeFn(ntEntries), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("has", makeNativeFn(ntHas), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("delete", makeNativeFn(ntDelete), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("sleep", makeContextNativeFn(ntSleep, p.guard(CapClock, "sleep")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("fileRead", makeGuardedNativeFn(ntFileRead, p.pathGuard(CapRead, "fileRead")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("fileWrite", makeGuardedNativeFn(ntFileWrite, p.pathGuard(CapWrite, "fileWrite")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("getEnv", makeGuardedNativeFn(ntGetEnv, p.guard(CapEnv, "getEnv")), true)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.declareVar("exec", makeContextNativeFn(ntExec, p.guard(CapExec, "exec")), true)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
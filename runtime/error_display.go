@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"aolbrich/lexer/diag"
+)
+
+const (
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorBlue   = "\033[34m"
+	colorBold   = "\033[1m"
+	colorReset  = "\033[0m"
+)
+
+// ErrorReporter renders errors like rustc or go vet, the location of the
+// error with the source line and a caret underline, followed by the user
+// functions the error went through.
+type ErrorReporter struct {
+	out     io.Writer
+	color   bool
+	modules *ModuleLoader
+}
+
+func NewErrorReporter(out io.Writer, color bool, modules *ModuleLoader) *ErrorReporter {
+	return &ErrorReporter{out: out, color: color, modules: modules}
+}
+
+// Report renders err, src is the source of trace entries without a file.
+func (r *ErrorReporter) Report(err *diag.CustomError, src string) {
+	r.render("error", colorRed, err, src)
+}
+
+// Warning renders a problem that does not stop the script.
+func (r *ErrorReporter) Warning(warning *diag.CustomError, src string) {
+	r.render("warning", colorYellow, warning, src)
+}
+
+func (r *ErrorReporter) render(level string, color string, err *diag.CustomError, src string) {
+	for _, e := range err.Errors {
+		r.render(level, color, e, src)
+	}
+
+	fmt.Fprintln(r.out)
+	fmt.Fprintln(r.out, r.paint(colorBold+color, level)+r.paint(colorBold, ": "+err.Message))
+
+	if len(err.Trace) > 0 {
+		r.snippet(err.Trace[0], src)
+	}
+
+	for _, frame := range r.dedupeStack(err.Stack) {
+		fmt.Fprintln(r.out, r.paint(colorBlue, "   = ")+frame)
+	}
+
+	fmt.Fprintln(r.out)
+}
+
+func (r *ErrorReporter) snippet(entry diag.TraceEntry, src string) {
+	source := r.source(entry, src)
+	line, column, text := diag.Locate(source, entry.Pos)
+
+	fmt.Fprintln(r.out, r.paint(colorBlue, "  --> ")+r.location(entry, line, column))
+
+	gutter := fmt.Sprintf("%d", line)
+	pad := strings.Repeat(" ", len(gutter))
+	fmt.Fprintln(r.out, r.paint(colorBlue, pad+" |"))
+	fmt.Fprintln(r.out, r.paint(colorBlue, gutter+" | ")+strings.TrimRight(text, "\r"))
+
+	width := entry.End - entry.Pos
+	lineLength := len([]rune(text))
+	if column-1+width > lineLength {
+		width = lineLength - column + 1
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	indent := []rune(text)
+	if column-1 < len(indent) {
+		indent = indent[:column-1]
+	}
+	// Tabs are kept so the caret lines up with the source line
+	marker := strings.Map(func(c rune) rune {
+		if c == '\t' {
+			return c
+		}
+		return ' '
+	}, string(indent))
+
+	fmt.Fprintln(r.out, r.paint(colorBlue, pad+" | ")+marker+r.paint(colorBold+colorRed, strings.Repeat("^", width)))
+}
+
+// dedupeStack lists the functions from the innermost, repeated calls of
+// the same function like recursion are collapsed into one line.
+func (r *ErrorReporter) dedupeStack(stack []diag.StackFrame) []string {
+	var lines []string
+	for i := 0; i < len(stack); {
+		j := i
+		for j < len(stack) && stack[j].Function == stack[i].Function {
+			j++
+		}
+
+		line := fmt.Sprintf("in fn %s", stack[i].Function)
+		if j-i > 1 {
+			line += fmt.Sprintf(" (%d recursive calls)", j-i)
+		}
+
+		// The outermost call of the group is where the chain started
+		call := stack[j-1].Call
+		callLine, column, _ := diag.Locate(r.source(call, ""), call.Pos)
+		line += ", called at " + r.location(call, callLine, column)
+
+		lines = append(lines, line)
+		i = j
+	}
+
+	return lines
+}
+
+func (r *ErrorReporter) source(entry diag.TraceEntry, src string) string {
+	if entry.File != "" && r.modules != nil {
+		if source, ok := r.modules.Sources[entry.File]; ok {
+			return source
+		}
+	}
+
+	return src
+}
+
+func (r *ErrorReporter) location(entry diag.TraceEntry, line, column int) string {
+	if entry.File == "" {
+		return fmt.Sprintf("%d:%d", line, column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", DisplayFileName(entry.File), line, column)
+}
+
+func (r *ErrorReporter) paint(color string, s string) string {
+	if !r.color {
+		return s
+	}
+
+	return color + s + colorReset
+}
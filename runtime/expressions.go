@@ -0,0 +1,1150 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+func (i *Interpreter) evalBinaryExpression(binop *ast.BinaryExpession, env *Environments) (taggedVal, *diag.CustomError) {
+	lhs, err := i.evaluateTagged(binop.Left, env)
+	if err != nil {
+		return taggedVal{}, i.formatError(err, binop)
+	}
+	rhs, err := i.evaluateTagged(binop.Right, env)
+	if err != nil {
+		return taggedVal{}, i.formatError(err, binop)
+	}
+
+	result, err := i.binaryTagged(lhs, rhs, binop.Operator)
+	if err != nil {
+		return taggedVal{}, i.formatError(err, binop)
+	}
+
+	return result, nil
+}
+
+// binaryOp applies an arithmetic, bitwise or concatenation operator, the
+// result is null for operands of other types.
+func (i *Interpreter) binaryOp(lhs RuntimeVal, rhs RuntimeVal, operator string) (RuntimeVal, *diag.CustomError) {
+	result, err := i.binaryTagged(tag(lhs), tag(rhs), operator)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.box(), nil
+}
+
+// binaryTagged is binaryOp on tagged values, integers and floats are
+// computed inline.
+func (i *Interpreter) binaryTagged(lhs taggedVal, rhs taggedVal, operator string) (taggedVal, *diag.CustomError) {
+	if lhs.tag == tagInt && rhs.tag == tagInt {
+		return i.evalIntegerBinaryExpr(lhs.int(), rhs.int(), operator)
+	}
+
+	// Mixing an integer with a float promotes the integer to float
+	if lhs.isNumber() && rhs.isNumber() {
+		return i.evalNumericBinaryExpr(lhs.float(), rhs.float(), operator)
+	}
+
+	if l, ok := lhs.ref.(*StringVal); ok {
+		if r, ok := rhs.ref.(*StringVal); ok {
+			result, err := i.evalStringBinaryExpr(*l, *r, operator)
+			if err != nil {
+				return taggedVal{}, err
+			}
+			return taggedVal{ref: result}, nil
+		}
+	}
+
+	return taggedVal{ref: makeNull()}, nil
+}
+
+func (i *Interpreter) evalIdentifier(ident *ast.Identifier, env *Environments) (RuntimeVal, *diag.CustomError) {
+	val, err := env.lookupVarAt(ident.Symbol, ident.Slot)
+	if err != nil {
+		return nil, i.formatError(err, ident)
+	}
+
+	return val, nil
+}
+
+func (i *Interpreter) evalIntegerBinaryExpr(l, r int64, operator string) (taggedVal, *diag.CustomError) {
+	var result int64
+	switch operator {
+	case "+":
+		result = l + r
+		if (result > l) != (r > 0) {
+			return taggedVal{}, i.overflowError(l, r, operator)
+		}
+	case "-":
+		result = l - r
+		if (result < l) != (r > 0) {
+			return taggedVal{}, i.overflowError(l, r, operator)
+		}
+	case "*":
+		if l != 0 && r != 0 {
+			result = l * r
+			if result/r != l || (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64) {
+				return taggedVal{}, i.overflowError(l, r, operator)
+			}
+		}
+	case "/":
+		// Division of integers produces a float, use ~/ for integer division
+		return i.evalNumericBinaryExpr(float64(l), float64(r), operator)
+	case "%", "~/":
+		if r == 0 {
+			return taggedVal{}, diag.NewCustomError("Division by 0")
+		}
+		if l == math.MinInt64 && r == -1 {
+			return taggedVal{}, i.overflowError(l, r, operator)
+		}
+		if operator == "%" {
+			result = l % r
+		} else {
+			result = l / r
+		}
+	case "**":
+		if r < 0 {
+			return i.evalNumericBinaryExpr(float64(l), float64(r), operator)
+		}
+		var err *diag.CustomError
+		result, err = i.intPow(l, r)
+		if err != nil {
+			return taggedVal{}, err
+		}
+	case "&", "|", "^", "<<", ">>":
+		var err *diag.CustomError
+		result, err = i.evalBitwiseBinaryExpr(l, r, operator)
+		if err != nil {
+			return taggedVal{}, err
+		}
+	default:
+		return taggedVal{}, diag.NewCustomError(fmt.Sprintf("Operator %s not implemented", operator))
+	}
+
+	return inlineInt(result), nil
+}
+
+func (i *Interpreter) intPow(base, exp int64) (int64, *diag.CustomError) {
+	var result int64 = 1
+	b := base
+	e := exp
+	for e > 0 {
+		if e&1 == 1 {
+			next := result * b
+			if b != 0 && next/b != result {
+				return 0, i.overflowError(base, exp, "**")
+			}
+			result = next
+		}
+		e >>= 1
+		if e > 0 {
+			next := b * b
+			if b != 0 && next/b != b {
+				return 0, i.overflowError(base, exp, "**")
+			}
+			b = next
+		}
+	}
+
+	return result, nil
+}
+
+func (i *Interpreter) overflowError(lhs, rhs int64, operator string) *diag.CustomError {
+	return diag.NewCustomError(fmt.Sprintf("Integer overflow in %d %s %d", lhs, operator, rhs))
+}
+
+func (i *Interpreter) evalNumericBinaryExpr(l, r float64, operator string) (taggedVal, *diag.CustomError) {
+	var result float64
+	switch operator {
+	case "+":
+		result = l + r
+	case "-":
+		result = l - r
+	case "*":
+		result = l * r
+	case "/":
+		if r == 0 {
+			return taggedVal{}, diag.NewCustomError("Division by 0")
+		}
+		result = l / r
+	case "%":
+		if r == 0 {
+			return taggedVal{}, diag.NewCustomError("Division by 0")
+		}
+		result = math.Mod(l, r)
+	case "~/":
+		if r == 0 {
+			return taggedVal{}, diag.NewCustomError("Division by 0")
+		}
+		result = math.Trunc(l / r)
+	case "**":
+		result = math.Pow(l, r)
+	case "&", "|", "^", "<<", ">>":
+		lBits, err := i.toBitwiseOperand(l, operator)
+		if err != nil {
+			return taggedVal{}, err
+		}
+
+		rBits, err := i.toBitwiseOperand(r, operator)
+		if err != nil {
+			return taggedVal{}, err
+		}
+
+		bits, err := i.evalBitwiseBinaryExpr(lBits, rBits, operator)
+		if err != nil {
+			return taggedVal{}, err
+		}
+
+		return inlineInt(bits), nil
+	default:
+		return taggedVal{}, diag.NewCustomError(fmt.Sprintf("Operator %s not implemented", operator))
+	}
+
+	return inlineFloat(result), nil
+}
+
+func (i *Interpreter) evalBitwiseBinaryExpr(l, r int64, operator string) (int64, *diag.CustomError) {
+	var result int64
+	switch operator {
+	case "&":
+		result = l & r
+	case "|":
+		result = l | r
+	case "^":
+		result = l ^ r
+	case "<<", ">>":
+		if r < 0 {
+			return 0, diag.NewCustomError(fmt.Sprintf("Negative shift count %d", r))
+		}
+		if operator == "<<" {
+			// Shifting out a set bit or the sign overflows like * 2 does
+			if r >= 64 || (l<<uint64(r))>>uint64(r) != l {
+				return 0, i.overflowError(l, r, operator)
+			}
+			result = l << uint64(r)
+		} else {
+			result = l >> uint64(r)
+		}
+	default:
+		return 0, diag.NewCustomError(fmt.Sprintf("Operator %s not implemented", operator))
+	}
+
+	return result, nil
+}
+
+func (i *Interpreter) toBitwiseOperand(n float64, operator string) (int64, *diag.CustomError) {
+	if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+		return 0, diag.NewCustomError(fmt.Sprintf("Operator %s requires integer operands, got %v", operator, n))
+	}
+
+	return int64(n), nil
+}
+
+func (i *Interpreter) evalUnaryExpression(unop *ast.UnaryExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	operand, err := i.evaluate(unop.Operand, env)
+	if err != nil {
+		return nil, i.formatError(err, unop)
+	}
+
+	result, err := i.unaryOp(operand, unop.Operator)
+	if err != nil {
+		return nil, i.formatError(err, unop)
+	}
+
+	return result, nil
+}
+
+func (i *Interpreter) unaryOp(operand RuntimeVal, operator string) (RuntimeVal, *diag.CustomError) {
+	switch operator {
+	case "~":
+		if n, ok := operand.(*IntVal); ok {
+			return makeInteger(^n.Value), nil
+		}
+
+		n, ok := operand.(*NumberVal)
+		if !ok {
+			return nil, diag.NewCustomError(fmt.Sprintf("Operator %s requires a number operand", operator))
+		}
+
+		v, err := i.toBitwiseOperand(n.Value, operator)
+		if err != nil {
+			return nil, err
+		}
+		return makeInteger(^v), nil
+	default:
+		return nil, diag.NewCustomError(fmt.Sprintf("Operator %s not implemented", operator))
+	}
+}
+
+func (i *Interpreter) evalStringBinaryExpr(lhs, rhs StringVal, operator string) (*StringVal, *diag.CustomError) {
+	var result string
+	switch operator {
+	case "+":
+		result = lhs.Value + rhs.Value
+	default:
+		return nil, diag.NewCustomError(fmt.Sprintf("Operator %s not implemented", operator))
+	}
+
+	if err := i.allocate(len(result)); err != nil {
+		return nil, err
+	}
+
+	return makeString(result), nil
+}
+
+func (i *Interpreter) evalAssignment(node *ast.AssignmentExpr, env *Environments) (taggedVal, *diag.CustomError) {
+	if node.Assigne.Kind() == ast.NodeTypeMemberExpression {
+		value, err := i.evalMemberAssignment(node, node.Assigne.(*ast.MemberExpression), env)
+		return tag(value), err
+	}
+
+	if node.Assigne.Kind() == ast.NodeTypeObjectPattern || node.Assigne.Kind() == ast.NodeTypeArrayPattern {
+		value, err := i.evaluate(node.Value, env)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, node)
+		}
+
+		err = i.assignPattern(node.Assigne, value, env)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, node)
+		}
+
+		return tag(value), nil
+	}
+
+	if node.Assigne.Kind() != ast.NodeTypeIdentifier {
+		return taggedVal{}, diag.NewCustomError("Invalid LHS iside assignment expression")
+	}
+
+	identifier := node.Assigne.(*ast.Identifier)
+	if identifier.Slot == nil {
+		evaulated, err := i.evaluate(node.Value, env)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, node)
+		}
+
+		result, err := env.assignVar(identifier.Symbol, evaulated)
+		if err != nil {
+			err.AddSpan(node.Pos(), node.End())
+			err.AddSpan(node.Assigne.Pos(), node.Assigne.End())
+		}
+
+		return tag(result), err
+	}
+
+	evaulated, err := i.evaluateTagged(node.Value, env)
+	if err != nil {
+		return taggedVal{}, i.formatError(err, node)
+	}
+
+	err = env.ancestor(identifier.Slot.Depth).assignSlot(identifier.Symbol, identifier.Slot.Index, evaulated)
+	if err != nil {
+		err.AddSpan(node.Pos(), node.End())
+		err.AddSpan(node.Assigne.Pos(), node.Assigne.End())
+		return taggedVal{}, err
+	}
+
+	return evaulated, nil
+}
+
+func (i *Interpreter) evalMemberAssignment(node *ast.AssignmentExpr, member *ast.MemberExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	object, err := i.evaluate(member.Object, env)
+	if err != nil {
+		return nil, i.formatError(err, node)
+	}
+
+	key, err := i.evalMemberKey(member, env)
+	if err != nil {
+		return nil, i.formatError(err, node)
+	}
+
+	value, err := i.evaluate(node.Value, env)
+	if err != nil {
+		return nil, i.formatError(err, node)
+	}
+
+	err = i.setMember(object, key, value)
+	if err != nil {
+		return nil, i.formatError(err, node)
+	}
+
+	return value, nil
+}
+
+func (i *Interpreter) setMember(object RuntimeVal, key RuntimeVal, value RuntimeVal) *diag.CustomError {
+	switch target := object.(type) {
+	case *ObjectVal:
+		return i.setProperty(target, key, value)
+	case *ArrayVal:
+		index, err := i.indexOf(key, len(target.elements))
+		if err != nil {
+			return err
+		}
+		target.elements[index] = value
+		return nil
+	}
+
+	return diag.NewCustomError(fmt.Sprintf("Cannot assign member of %s", displayValue(object)))
+}
+
+// setProperty sets a property of an object, counting a new one as an
+// allocation.
+func (i *Interpreter) setProperty(object *ObjectVal, key RuntimeVal, value RuntimeVal) *diag.CustomError {
+	count := len(object.keys)
+	if err := object.set(key, value); err != nil {
+		return err
+	}
+
+	if len(object.keys) > count {
+		return i.allocate(allocationSlot)
+	}
+
+	return nil
+}
+
+func (i *Interpreter) evalObjectExpr(node *ast.ObjectLiteral, env *Environments) (RuntimeVal, *diag.CustomError) {
+	object := makeObject()
+
+	for _, property := range node.Properties {
+		var key RuntimeVal = makeString(property.Key)
+		if property.ComputedKey != nil {
+			computed, err := i.evaluate(property.ComputedKey, env)
+			if err != nil {
+				return nil, i.formatError(err, node)
+			}
+			key = computed
+		}
+
+		var runtimeVal RuntimeVal
+		var err *diag.CustomError
+		if property.Value == nil {
+			runtimeVal, err = env.lookupVarAt(property.Key, property.Slot)
+		} else {
+			runtimeVal, err = i.evaluate(property.Value, env)
+		}
+		if err != nil {
+			return nil, i.formatError(err, node)
+		}
+
+		err = i.setProperty(object, key, runtimeVal)
+		if err != nil {
+			return nil, i.formatError(err, property)
+		}
+	}
+
+	return object, nil
+}
+
+func (i *Interpreter) evalArrayExpr(node *ast.ArrayLiteral, env *Environments) (RuntimeVal, *diag.CustomError) {
+	elements := make([]RuntimeVal, 0, len(node.Elements))
+
+	for _, element := range node.Elements {
+		value, err := i.evaluate(element, env)
+		if err != nil {
+			return nil, i.formatError(err, node)
+		}
+		elements = append(elements, value)
+	}
+
+	if err := i.allocate(len(elements) * allocationSlot); err != nil {
+		return nil, i.formatError(err, node)
+	}
+
+	return makeArray(elements), nil
+}
+
+func (i *Interpreter) evalMemberExpr(member *ast.MemberExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	object, err := i.evaluate(member.Object, env)
+	if err != nil {
+		return nil, i.formatError(err, member)
+	}
+
+	key, err := i.evalMemberKey(member, env)
+	if err != nil {
+		return nil, i.formatError(err, member)
+	}
+
+	value, err := i.getMember(object, key)
+	if err != nil {
+		return nil, i.formatError(err, member)
+	}
+
+	return value, nil
+}
+
+// getMember reads a property of an object, an element of an array or a
+// character of a string, missing object properties are null.
+func (i *Interpreter) getMember(object RuntimeVal, key RuntimeVal) (RuntimeVal, *diag.CustomError) {
+	switch target := object.(type) {
+	case *ObjectVal:
+		if value, ok := target.get(key); ok {
+			return value, nil
+		}
+		return makeNull(), nil
+	case *ArrayVal:
+		index, err := i.indexOf(key, len(target.elements))
+		if err != nil {
+			return nil, err
+		}
+		return target.elements[index], nil
+	case *StringVal:
+		// Strings are indexed by character like for of and for in walk them
+		index, err := i.indexOf(key, utf8.RuneCountInString(target.Value))
+		if err != nil {
+			return nil, err
+		}
+		return makeString(string(runeAt(target.Value, index))), nil
+	}
+
+	return nil, diag.NewCustomError(fmt.Sprintf("Cannot access member of %s", displayValue(object)))
+}
+
+// evalMemberKey returns the key of a member expression, obj.name uses the
+// identifier as a string key, obj[expr] evaluates the expression.
+func (i *Interpreter) evalMemberKey(member *ast.MemberExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	if !member.Computed {
+		return makeString(member.Propert.(*ast.Identifier).Symbol), nil
+	}
+
+	return i.evaluate(member.Propert, env)
+}
+
+func (i *Interpreter) indexOf(key RuntimeVal, length int) (int, *diag.CustomError) {
+	index, ok := key.(*IntVal)
+	if !ok {
+		return 0, diag.NewCustomError(fmt.Sprintf("Index must be an integer, got %s", displayValue(key)))
+	}
+
+	if index.Value < 0 || index.Value >= int64(length) {
+		return 0, diag.NewCustomError(fmt.Sprintf("Index %d out of range, length is %d", index.Value, length))
+	}
+
+	return int(index.Value), nil
+}
+
+// runeAt returns the character at index of text, counted in characters.
+func runeAt(text string, index int) rune {
+	for _, char := range text {
+		if index == 0 {
+			return char
+		}
+		index--
+	}
+
+	return utf8.RuneError
+}
+
+func (i *Interpreter) evalCallExpr(expr *ast.CallExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	var args []RuntimeVal
+
+	for _, arg := range expr.Args {
+		ev, err := i.evaluate(*arg, env)
+		if err != nil {
+			return nil, i.formatError(err, expr)
+		}
+		args = append(args, ev)
+	}
+
+	f, err := i.evaluate(expr.Caller, env)
+	if err != nil {
+		return nil, i.formatError(err, expr)
+	}
+
+	if fn, ok := f.(*NativeFnValue); ok {
+		result, err := i.callNative(fn, args, env)
+		if err != nil {
+			return nil, i.formatError(err, expr)
+		}
+		return result, nil
+	}
+
+	if fnc, ok := f.(*FnValue); ok {
+		if expr.Tail && fnc.compiled == nil {
+			return &tailCall{fn: fnc, args: args, pos: expr.Pos(), end: expr.End()}, nil
+		}
+		return i.callFunction(fnc, args, expr.Pos(), expr.End())
+	}
+
+	return nil, diag.NewCustomError("cannot call value which is not a function").AddSpan(expr.Pos(), expr.End())
+}
+
+// tailCall is the value of a call in tail position, the function making it
+// returns and callFunction runs the callee in its place, so recursion
+// through tail calls runs in constant stack.
+type tailCall struct {
+	fn   *FnValue
+	args []RuntimeVal
+	pos  int
+	end  int
+}
+
+// callFunction runs a user function, pos and end are the span of the call.
+// Functions compiled to bytecode run on the VM.
+func (i *Interpreter) callFunction(fnc *FnValue, args []RuntimeVal, pos int, end int) (RuntimeVal, *diag.CustomError) {
+	if fnc.compiled != nil {
+		return newVM(i).call(fnc, args, pos, end)
+	}
+
+	if err := i.enterCall(); err != nil {
+		return nil, err.AddSpan(pos, end)
+	}
+	defer i.leaveCall()
+
+	called, calledPos := fnc, pos
+	for {
+		result, err := i.runFunction(fnc, args, pos, end)
+		if err != nil {
+			return nil, addTailCallFrame(err, called, fnc, calledPos)
+		}
+
+		call, ok := result.(*tailCall)
+		if !ok {
+			return result, nil
+		}
+
+		fnc, args, pos, end = call.fn, call.args, call.pos, call.end
+	}
+}
+
+// addTailCallFrame adds the function first called to the chain of an error
+// raised by another function it tail called, the functions in between are
+// not kept.
+func addTailCallFrame(err *diag.CustomError, called *FnValue, failed *FnValue, pos int) *diag.CustomError {
+	if called == failed || called.name == failed.name {
+		return err
+	}
+
+	if module := called.declarationEnv.root().module; module != nil {
+		err.InFile(module.file)
+	}
+
+	return err.AddFrame(called.name, pos)
+}
+
+// runFunction runs the body of a function, a call in tail position is
+// returned as a tailCall without being made.
+func (i *Interpreter) runFunction(fnc *FnValue, args []RuntimeVal, pos int, end int) (RuntimeVal, *diag.CustomError) {
+	scope := newLocalEnvironments(fnc.declarationEnv, fnc.locals)
+
+	for ind, param := range fnc.paramaters {
+		// @TODO verify the airity of the function, missing arguments are null
+		var arg RuntimeVal = makeNull()
+		if ind < len(args) {
+			arg = args[ind]
+		}
+
+		err := i.declarePattern(param, arg, scope, false)
+		if err != nil {
+			return nil, err.AddSpan(pos, end)
+		}
+	}
+
+	result := taggedVal{ref: makeNull()}
+	for _, statement := range fnc.body {
+		var err *diag.CustomError
+		result, err = i.evaluateTagged(statement, scope)
+		if err != nil {
+			// The body may belong to another module than the caller
+			if module := fnc.declarationEnv.root().module; module != nil {
+				err.InFile(module.file)
+			}
+			return nil, err.AddFrame(fnc.name, pos)
+		}
+	}
+
+	return result.box(), nil
+}
+
+func (i *Interpreter) evalIntegerConditionExpr(lhs, rhs IntVal, operator string) (*BoolVal, *diag.CustomError) {
+	var result bool
+	switch operator {
+	case "=":
+		result = lhs.Value == rhs.Value
+	case ">":
+		result = lhs.Value > rhs.Value
+	case ">=":
+		result = lhs.Value >= rhs.Value
+	case "<":
+		result = lhs.Value < rhs.Value
+	case "<=":
+		result = lhs.Value <= rhs.Value
+	case "!=":
+		result = lhs.Value != rhs.Value
+	default:
+		return nil, diag.NewCustomError(fmt.Sprintf("Conditional Operator %s not implemented", operator))
+	}
+
+	return makeBool(result), nil
+}
+
+func (i *Interpreter) evalNumericConditionExpr(lhs, rhs NumberVal, operator string) (*BoolVal, *diag.CustomError) {
+	var result bool
+	switch operator {
+	case "=":
+		result = lhs.Value == rhs.Value
+	case ">":
+		result = lhs.Value > rhs.Value
+	case ">=":
+		result = lhs.Value >= rhs.Value
+	case "<":
+		result = lhs.Value < rhs.Value
+	case "<=":
+		result = lhs.Value <= rhs.Value
+	case "!=":
+		result = lhs.Value != rhs.Value
+	default:
+		return nil, diag.NewCustomError(fmt.Sprintf("Conditional Operator %s not implemented", operator))
+	}
+
+	return makeBool(result), nil
+}
+
+func (i *Interpreter) evalStringConditionExpr(lhs, rhs StringVal, operator string) (*BoolVal, *diag.CustomError) {
+	var result bool
+	switch operator {
+	case "=":
+		result = lhs.Value == rhs.Value
+	case ">":
+		result = lhs.Value > rhs.Value
+	case ">=":
+		result = lhs.Value >= rhs.Value
+	case "<":
+		result = lhs.Value < rhs.Value
+	case "<=":
+		result = lhs.Value <= rhs.Value
+	case "!=":
+		result = lhs.Value != rhs.Value
+	default:
+		return nil, diag.NewCustomError(fmt.Sprintf("String Conditional Operator %s not implemented", operator))
+	}
+
+	return makeBool(result), nil
+}
+
+func (i *Interpreter) evalBoolConditionExpr(lhs, rhs BoolVal, operator string) (*BoolVal, *diag.CustomError) {
+	var result bool
+	switch operator {
+	case "&":
+		result = lhs.Value && rhs.Value
+	case "|":
+		result = lhs.Value || rhs.Value
+	default:
+		return nil, diag.NewCustomError(fmt.Sprintf("Logical conditional operator %s not implemented", operator))
+	}
+
+	return makeBool(result), nil
+}
+
+func (i *Interpreter) evalIfExpr(ifE *ast.IfExpression, env *Environments) (taggedVal, *diag.CustomError) {
+	var cond RuntimeVal
+	var err *diag.CustomError
+	if ifE.Condition == nil {
+		cond = makeBool(true)
+	} else {
+		cond, err = i.evaluate(ifE.Condition, env)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, ifE)
+		}
+	}
+
+	isTrue, err := toCondition(cond)
+	if err != nil {
+		return taggedVal{}, i.formatError(i.formatError(err, ifE.Condition), ifE)
+	}
+
+	result := taggedVal{ref: makeNull()}
+	if isTrue {
+		for _, statement := range ifE.Body {
+			result, err = i.evaluateTagged(statement, env)
+			if err != nil {
+				return taggedVal{}, i.formatError(err, ifE)
+			}
+		}
+	} else if ifE.ElseExpression != nil {
+		return i.evalIfExpr(ifE.ElseExpression.(*ast.IfExpression), env)
+	}
+
+	return result, nil
+}
+
+// toCondition reads the value of an if or for condition, which must be a
+// bool.
+func toCondition(value RuntimeVal) (bool, *diag.CustomError) {
+	condition, ok := value.(*BoolVal)
+	if !ok {
+		return false, diag.NewCustomError(fmt.Sprintf("Condition must be a bool, got %s", displayNested(value)))
+	}
+
+	return condition.Value, nil
+}
+
+func (i *Interpreter) evalForExpr(forE *ast.ForExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	var err *diag.CustomError
+	result := taggedVal{ref: makeNull()}
+	braked := false
+	continued := false
+
+	if forE.Declaration != nil {
+		_, err := i.evaluate(forE.Declaration, env)
+		if err != nil {
+			return nil, i.formatError(err, forE)
+		}
+	}
+
+	for {
+		// An iteration is a step, a loop without a condition or a body
+		// evaluates nothing else which would count it
+		if err := i.step(); err != nil {
+			return nil, i.formatError(err, forE)
+		}
+
+		if forE.Condition != nil {
+			cond, err := i.evaluate(forE.Condition, env)
+			if err != nil {
+				return nil, i.formatError(err, forE)
+			}
+
+			isTrue, err := toCondition(cond)
+			if err != nil {
+				return nil, i.formatError(i.formatError(err, forE.Condition), forE)
+			}
+			if !isTrue {
+				break
+			}
+		}
+
+		for _, statement := range forE.Body {
+			result, err = i.evaluateTagged(statement, env)
+			if _, ok := result.ref.(*BreakVal); ok {
+				braked = true
+				break
+			}
+
+			if _, ok := result.ref.(*ContinueVal); ok {
+				continued = true
+				break
+			}
+			if err != nil {
+				return nil, i.formatError(err, forE)
+			}
+		}
+
+		if braked == true {
+			break
+		}
+
+		if continued == true {
+			continue
+		}
+
+		if forE.AfterCondition != nil {
+			cond, err := i.evaluate(forE.AfterCondition, env)
+			if err != nil {
+				return nil, i.formatError(err, forE)
+			}
+
+			isTrue, err := toCondition(cond)
+			if err != nil {
+				return nil, i.formatError(i.formatError(err, forE.AfterCondition), forE)
+			}
+			if !isTrue {
+				break
+			}
+		}
+
+		if forE.IncrementalExpression != nil {
+			_, err = i.evaluateTagged(forE.IncrementalExpression, env)
+			if err != nil {
+				return nil, i.formatError(err, forE)
+			}
+		}
+	}
+
+	return result.box(), nil
+}
+
+func (i *Interpreter) evalForEachExpr(forE *ast.ForEachExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	iterable, err := i.evaluate(forE.Iterable, env)
+	if err != nil {
+		return nil, i.formatError(err, forE)
+	}
+
+	result := taggedVal{ref: makeNull()}
+
+	// iterate runs the body for one item in a fresh scope, returns false on break
+	iterate := func(item RuntimeVal) (bool, *diag.CustomError) {
+		scope := newLocalEnvironments(env, forE.Locals)
+
+		_, err := scope.declareVarAt(forE.Identifier, forE.Slot, item, forE.Constant)
+		if err != nil {
+			return false, err
+		}
+
+		for _, statement := range forE.Body {
+			result, err = i.evaluateTagged(statement, scope)
+			if err != nil {
+				return false, err
+			}
+
+			if _, ok := result.ref.(*BreakVal); ok {
+				result = taggedVal{ref: makeNull()}
+				return false, nil
+			}
+
+			if _, ok := result.ref.(*ContinueVal); ok {
+				result = taggedVal{ref: makeNull()}
+				return true, nil
+			}
+		}
+
+		return true, nil
+	}
+
+	items, err := newIterator(iterable, forE.Keys)
+	if err != nil {
+		return nil, err.AddSpan(forE.Pos(), forE.End())
+	}
+
+	for {
+		if err := i.step(); err != nil {
+			return nil, i.formatError(err, forE)
+		}
+
+		item, ok := items.next()
+		if !ok {
+			break
+		}
+
+		next, err := iterate(item)
+		if err != nil {
+			return nil, i.formatError(err, forE)
+		}
+		if !next {
+			break
+		}
+	}
+
+	return result.box(), nil
+}
+
+// iterator yields the items of a for of, or the keys of a for in, next
+// returns false once there are no more items.
+type iterator struct {
+	next func() (RuntimeVal, bool)
+}
+
+// newIterator iterates ranges, arrays, strings by character and objects.
+// Arrays are read while iterating so pushed elements are visited, object
+// keys are taken upfront and keys deleted by the body are skipped.
+func newIterator(iterable RuntimeVal, keys bool) (*iterator, *diag.CustomError) {
+	switch collection := iterable.(type) {
+	case *RangeVal:
+		if keys {
+			return nil, diag.NewCustomError("Range can be iterated with for of only")
+		}
+
+		n := collection.start
+		done := false
+		return &iterator{next: func() (RuntimeVal, bool) {
+			if done || !collection.contains(n) {
+				return nil, false
+			}
+
+			item := makeInteger(n)
+			if collection.isLast(n) {
+				done = true
+			} else {
+				n += collection.step
+			}
+			return item, true
+		}}, nil
+	case *ArrayVal:
+		index := 0
+		return &iterator{next: func() (RuntimeVal, bool) {
+			if index >= len(collection.elements) {
+				return nil, false
+			}
+
+			var item RuntimeVal = makeInteger(int64(index))
+			if !keys {
+				item = collection.elements[index]
+			}
+			index++
+			return item, true
+		}}, nil
+	case *StringVal:
+		offset, index := 0, 0
+		return &iterator{next: func() (RuntimeVal, bool) {
+			if offset >= len(collection.Value) {
+				return nil, false
+			}
+
+			char, width := utf8.DecodeRuneInString(collection.Value[offset:])
+			var item RuntimeVal = makeInteger(int64(index))
+			if !keys {
+				item = makeString(string(char))
+			}
+			offset += width
+			index++
+			return item, true
+		}}, nil
+	case *ObjectVal:
+		objectKeys := collection.orderedKeys()
+		index := 0
+		return &iterator{next: func() (RuntimeVal, bool) {
+			for index < len(objectKeys) {
+				key := objectKeys[index]
+				index++
+
+				value, exist := collection.get(key)
+				if !exist {
+					continue
+				}
+
+				if keys {
+					return key, true
+				}
+				return value, true
+			}
+			return nil, false
+		}}, nil
+	}
+
+	return nil, diag.NewCustomError(fmt.Sprintf("Cannot iterate over %s", displayValue(iterable)))
+}
+
+func (i *Interpreter) evalBreakExpr(forE *ast.BreakExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	return makeBreak(), nil
+}
+
+func (i *Interpreter) evalContinueExpr(forE *ast.ContinueExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	return makeContinue(), nil
+}
+
+func (i *Interpreter) evalSwitchExpr(sw *ast.SwitchExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	cv, err := i.evaluate(sw.Value, env)
+	if err != nil {
+		err.AddSpan(sw.Pos(), sw.End())
+		return nil, err
+	}
+
+	// A matching case without break continues checking the following cases
+	for _, swcase := range sw.Body {
+		scope, matched, err := i.matchCase(swcase.Condition, cv, env)
+		if err != nil {
+			return nil, err.AddTrace(swcase.Pos)
+		}
+
+		if !matched {
+			continue
+		}
+
+		isBreak, err := i.evalBody(swcase.Body, scope)
+		if err != nil {
+			return nil, err
+		}
+		if isBreak {
+			break
+		}
+	}
+
+	return makeNull(), nil
+}
+
+func (i *Interpreter) evalMatchExpr(match *ast.MatchExpression, env *Environments) (RuntimeVal, *diag.CustomError) {
+	value, err := i.evaluate(match.Value, env)
+	if err != nil {
+		return nil, i.formatError(err, match)
+	}
+
+	for _, arm := range match.Arms {
+		scope, matched, err := i.matchCase(arm.Condition, value, env)
+		if err != nil {
+			return nil, err.AddTrace(arm.Pos)
+		}
+
+		if matched {
+			result, err := i.evaluate(arm.Body, scope)
+			if err != nil {
+				return nil, err.AddTrace(arm.Pos)
+			}
+			return result, nil
+		}
+	}
+
+	return nil, diag.NewCustomError(fmt.Sprintf("Non-exhaustive match, no arm matches %s", displayNested(value))).AddSpan(match.Pos(), match.End())
+}
+
+// matchCase checks a case condition against the value, it returns the scope
+// the body has to run in, a child scope when the condition binds a name.
+func (i *Interpreter) matchCase(condition *ast.CaseCondition, value RuntimeVal, env *Environments) (*Environments, bool, *diag.CustomError) {
+	scope := env
+	matched := condition.Wildcard
+
+	if condition.Binding != "" {
+		scope = newLocalEnvironments(env, condition.Locals)
+
+		_, err := scope.declareVarAt(condition.Binding, condition.BindingSlot, value, false)
+		if err != nil {
+			return nil, false, err
+		}
+		matched = true
+	}
+
+	if condition.Pattern != nil {
+		var err *diag.CustomError
+		scope = newLocalEnvironments(env, condition.Locals)
+
+		matched, err = i.matchPattern(condition.Pattern, value, scope)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	for _, caseValue := range condition.Values {
+		compare, err := i.evaluate(caseValue, env)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if valuesEqual(value, compare) {
+			matched = true
+			break
+		}
+	}
+
+	if !matched || condition.Guard == nil {
+		return scope, matched, nil
+	}
+
+	guard, err := i.evaluate(condition.Guard, scope)
+	if err != nil {
+		return nil, false, err
+	}
+
+	b, ok := guard.(*BoolVal)
+
+	return scope, ok && b.Value, nil
+}
+
+func (i *Interpreter) evalBody(items []ast.Stmter, env *Environments) (bool, *diag.CustomError) {
+	var lastRValue RuntimeVal
+	var err *diag.CustomError
+	for _, item := range items {
+		lastRValue, err = i.evaluate(item, env)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := lastRValue.(*BreakVal); ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+
+}
@@ -0,0 +1,167 @@
+package runtime
+
+import (
+	"testing"
+
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+)
+
+func TestShifts(t *testing.T) {
+	tests := []struct {
+		source string
+		result int64
+		err    string
+	}{
+		{"1 << 62", 1 << 62, ""},
+		{"(0 - 1) << 63", -1 << 63, ""},
+		{"3 >> 1", 1, ""},
+		{"(0 - 8) >> 70", -1, ""},
+		{"1 << 63", 0, "Integer overflow in 1 << 63"},
+		{"1 << 64", 0, "Integer overflow in 1 << 64"},
+		{"3 << 62", 0, "Integer overflow in 3 << 62"},
+		{"(0 - 3) << 62", 0, "Integer overflow in -3 << 62"},
+		{"1 << (0 - 1)", 0, "Negative shift count -1"},
+		{"1 >> (0 - 1)", 0, "Negative shift count -1"},
+	}
+
+	for _, engine := range engines {
+		for _, optimize := range []bool{false, true} {
+			for _, test := range tests {
+				result, err := runSource(t, engine, optimize, test.source)
+				if test.err != "" {
+					if err == nil || err.Message != test.err {
+						t.Errorf("%s optimize=%v %s: expected error %q, got %v %v", engine, optimize, test.source, test.err, displayValue(result), err)
+					}
+					continue
+				}
+				if n, ok := result.(*IntVal); err != nil || !ok || n.Value != test.result {
+					t.Errorf("%s optimize=%v %s: expected %d, got %v %v", engine, optimize, test.source, test.result, displayValue(result), err)
+				}
+			}
+		}
+	}
+}
+
+func TestTaggedValues(t *testing.T) {
+	tests := []struct {
+		source string
+		result string
+	}{
+		{"let x = 2000 + 3000;\nlet y = x * 0.5;\n[x, y]", "[5000, 2500]"},
+		{"let x = 2000;\nx = x + 1\nlet o = { x: x, y: x * 1.5 };\no.y", "3001.5"},
+		{"fn f(n) {\n    let m = n * 1000;\n    m + 0.5\n}\nf(3)", "3000.5"},
+		{"fn outer() {\n    let big = 1 << 40;\n    fn inner() {\n        big + 1\n    }\n    inner\n}\nlet g = outer();\ng()", "1099511627777"},
+		{"let x = 4000;\nlet y = 4000.0;\nx == y", "true"},
+		{"let x = 4000;\nnumToStr(x + 1)", "4001"},
+		{"let s = 0;\nfor (let i = 0; i < 5; i = i + 1) {\n    s = s + i * 1000\n}\ns", "10000"},
+		{"let a = [0, 0];\nlet n = 0;\nfor (let x of [1500, 2500]) {\n    let y = x + 0.25;\n    a[n] = y\n    n = n + 1\n}\na", "[1500.25, 2500.25]"},
+	}
+
+	for _, engine := range engines {
+		for _, optimize := range []bool{false, true} {
+			for _, test := range tests {
+				result, err := runSource(t, engine, optimize, test.source)
+				if err != nil || displayValue(result) != test.result {
+					t.Errorf("%s optimize=%v %q: expected %s, got %v %v", engine, optimize, test.source, test.result, displayValue(result), err)
+				}
+			}
+		}
+	}
+}
+
+func TestErrorSpans(t *testing.T) {
+	tests := []struct {
+		source string
+		span   string
+	}{
+		{"println(1 << 63)", "1 << 63"},
+		{"let big = 1 << 62;\nlet x = 1 + big * 2;\nx", "big * 2"},
+		{"let big = 1 << 62;\nbig + big > 1", "big + big"},
+		{"for (let i = 0; i < 2; i = i + 1) {\n    let y = i;\n    y\n}", "let y = i;"},
+	}
+
+	for _, engine := range engines {
+		for _, test := range tests {
+			_, err := runSource(t, engine, false, test.source)
+			if err == nil || len(err.Trace) == 0 {
+				t.Errorf("%s %q: expected an error at %q, got %v", engine, test.source, test.span, err)
+				continue
+			}
+			if span := test.source[err.Trace[0].Pos:err.Trace[0].End]; span != test.span {
+				t.Errorf("%s %q: expected the error at %q, got %q", engine, test.source, test.span, span)
+			}
+		}
+	}
+}
+
+func TestFloatDisplay(t *testing.T) {
+	tests := []struct {
+		source string
+		result string
+	}{
+		{"float(3)", "3"},
+		{"2.0 * 3", "6"},
+		{"1 / 3", "0.3333333333333333"},
+		{"7 / 2", "3.5"},
+		{"1e21", "1e+21"},
+	}
+
+	for _, engine := range engines {
+		for _, test := range tests {
+			result, err := runSource(t, engine, false, test.source)
+			if _, ok := result.(*NumberVal); err != nil || !ok || displayValue(result) != test.result {
+				t.Errorf("%s %s: expected the float %s, got %v %v", engine, test.source, test.result, displayValue(result), err)
+			}
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		source   string
+		result   string
+		overflow bool
+	}{
+		{"fn loop(n, total) {\n    if (n == 0) { total } else { loop(n - 1, total + n) }\n}\nloop(100000, 0)", "5000050000", false},
+		{"fn count(n, total) {\n    let next = total + 1;\n    if (n == 0) { next } else { count(n - 1, next) }\n}\ncount(100000, 0)", "100001", false},
+		{"fn even(n) {\n    if (n == 0) { true } else { odd(n - 1) }\n}\nfn odd(n) {\n    if (n == 0) { false } else { even(n - 1) }\n}\neven(100001)", "false", false},
+		{"fn down(n) {\n    match (n) {\n        0 => \"done\",\n        _ => down(n - 1),\n    }\n}\ndown(100000)", "done", false},
+		{"fn depth(n) {\n    if (n == 0) { 0 } else { 1 + depth(n - 1) }\n}\ndepth(100000)", "", true},
+		{"fn wrap(n) {\n    let r = wrap(n - 1);\n    r\n}\nwrap(100000)", "", true},
+	}
+
+	for _, engine := range engines {
+		for _, test := range tests {
+			program, err := parser.NewParser().ProduceAST(test.source)
+			if err != nil {
+				t.Fatalf("%q: %v", test.source, err)
+			}
+			env, err := NewEnvironments(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Tail calls do not count towards the depth, the others fail
+			// long before the recursion ends
+			i := NewInterpreter()
+			i.Engine = engine
+			i.MaxCallDepth = 100
+			if err := i.CheckProgram("", program, env); err != nil {
+				t.Fatalf("%q: %v", test.source, err)
+			}
+
+			result, err := i.Run(program, env)
+			if test.overflow {
+				if err == nil || err.Kind() != diag.ErrorStackOverflow {
+					t.Errorf("%s %q: expected a stack overflow, got %v %v", engine, test.source, displayValue(result), err)
+				}
+			} else if err != nil || displayValue(result) != test.result {
+				t.Errorf("%s %q: expected %s, got %v %v", engine, test.source, test.result, displayValue(result), err)
+			}
+			if i.callDepth != 0 {
+				t.Errorf("%s %q: expected no call in progress, got %d", engine, test.source, i.callDepth)
+			}
+		}
+	}
+}
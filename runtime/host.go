@@ -0,0 +1,501 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/lexer"
+)
+
+// hostCall runs a Go function registered by the host with the arguments
+// of the script, failing when they do not convert or the function fails.
+type hostCall func(args []RuntimeVal) (RuntimeVal, *diag.CustomError)
+
+var (
+	runtimeValType = reflect.TypeOf((*RuntimeVal)(nil)).Elem()
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func makeHostFn(call hostCall) *NativeFnValue {
+	return &NativeFnValue{
+		Type:     ValueNativeFunction,
+		hostCall: call,
+	}
+}
+
+// invoke runs a native, the natives of the interpreter never fail.
+func (fn *NativeFnValue) invoke(args []RuntimeVal, env *Environments) (RuntimeVal, *diag.CustomError) {
+	if fn.hostCall != nil {
+		return fn.hostCall(args)
+	}
+
+	return fn.call(args, env), nil
+}
+
+// Register declares a Go value as a constant of the root environment, the
+// scripts run in it and the modules they import see it. Functions become
+// natives converting their arguments from the values of the script and
+// their results back, a returned non nil error fails the script. Other
+// values are converted once by ToValue, maps and structs holding
+// functions make objects of natives.
+//
+// The arguments and results may be bool, integers, floats, string, slices,
+// arrays, maps, structs, pointers to them, RuntimeVal taking any value as
+// it is or interface{} taking it as GoValue converts it. Variadic functions
+// take the arguments past the others. The name must be an identifier of
+// the script, letters and underscores only.
+func (e *Environments) Register(name string, value interface{}) *diag.CustomError {
+	if !lexer.IsIdentifier(name) {
+		return diag.NewCustomError(fmt.Sprintf("Cannot register %q, the name must be letters and underscores and not a keyword", name))
+	}
+
+	converted, err := toValue(name, reflect.ValueOf(value))
+	if err != nil {
+		return err
+	}
+
+	_, err = e.root().declareVar(name, converted, true)
+
+	return err
+}
+
+// ToValue converts a Go value to the value of a script as Register does.
+func ToValue(value interface{}) (RuntimeVal, *diag.CustomError) {
+	return toValue("value", reflect.ValueOf(value))
+}
+
+// FromValue stores the value of a script in the Go variable target points
+// to, converting it as the arguments of registered functions are.
+func FromValue(value RuntimeVal, target interface{}) *diag.CustomError {
+	pointer := reflect.ValueOf(target)
+	if pointer.Kind() != reflect.Ptr || pointer.IsNil() {
+		return diag.NewCustomError(fmt.Sprintf("Cannot convert to %T, a pointer is expected", target))
+	}
+
+	converted, err := fromValue("Value", value, pointer.Type().Elem())
+	if err != nil {
+		return err
+	}
+	pointer.Elem().Set(converted)
+
+	return nil
+}
+
+// GoValue converts the value of a script to the Go value closest to it:
+// nil, int64, float64, string, bool, []interface{} for arrays and ranges
+// and map[string]interface{} for objects, their number keys formatted as
+// the script prints them. Functions are returned as they are.
+func GoValue(value RuntimeVal) interface{} {
+	switch v := value.(type) {
+	case *NullVal:
+		return nil
+	case *IntVal:
+		return v.Value
+	case *NumberVal:
+		return v.Value
+	case *StringVal:
+		return v.Value
+	case *BoolVal:
+		return v.Value
+	case *ArrayVal:
+		elements := make([]interface{}, len(v.elements))
+		for index, element := range v.elements {
+			elements[index] = GoValue(element)
+		}
+		return elements
+	case *RangeVal:
+		elements := []interface{}{}
+		for n := v.start; v.contains(n); n += v.step {
+			elements = append(elements, n)
+			if v.isLast(n) {
+				break
+			}
+		}
+		return elements
+	case *ObjectVal:
+		properties := make(map[string]interface{}, len(v.keys))
+		for _, key := range v.keys {
+			properties[displayValue(key.value())] = GoValue(v.properties[key])
+		}
+		return properties
+	}
+
+	return value
+}
+
+func toValue(name string, v reflect.Value) (RuntimeVal, *diag.CustomError) {
+	if !v.IsValid() {
+		return makeNull(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return makeNull(), nil
+		}
+	}
+
+	if value, ok := runtimeValue(v); ok {
+		return value, nil
+	}
+	if v.Type().Implements(errorType) {
+		return makeString(v.Interface().(error).Error()), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return toValue(name, v.Elem())
+	case reflect.Bool:
+		return makeBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return makeInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > 1<<63-1 {
+			return nil, diag.NewCustomError(fmt.Sprintf("Cannot convert %s, %d is past the largest integer", name, v.Uint()))
+		}
+		return makeInteger(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return makeNumber(v.Float()), nil
+	case reflect.String:
+		return makeString(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]RuntimeVal, v.Len())
+		for index := range elements {
+			element, err := toValue(fmt.Sprintf("%s[%d]", name, index), v.Index(index))
+			if err != nil {
+				return nil, err
+			}
+			elements[index] = element
+		}
+		return makeArray(elements), nil
+	case reflect.Map:
+		return mapToValue(name, v)
+	case reflect.Struct:
+		object := makeObject()
+		for index := 0; index < v.NumField(); index++ {
+			property, ok := propertyName(v.Type().Field(index))
+			if !ok {
+				continue
+			}
+			value, err := toValue(name+"."+property, v.Field(index))
+			if err != nil {
+				return nil, err
+			}
+			object.set(makeString(property), value)
+		}
+		return object, nil
+	case reflect.Func:
+		return hostFunction(name, v)
+	}
+
+	return nil, diag.NewCustomError(fmt.Sprintf("Cannot convert %s, %s has no value in scripts", name, v.Type()))
+}
+
+// runtimeValue returns the values of scripts held by v as they are.
+func runtimeValue(v reflect.Value) (RuntimeVal, bool) {
+	if !v.CanInterface() {
+		return nil, false
+	}
+
+	switch value := v.Interface().(type) {
+	case *NullVal, *IntVal, *NumberVal, *StringVal, *BoolVal, *ArrayVal, *ObjectVal, *RangeVal, *NativeFnValue, *FnValue:
+		return value, true
+	}
+
+	return nil, false
+}
+
+// mapToValue makes an object of a map with string or number keys, the
+// properties are sorted by key since maps have no order.
+func mapToValue(name string, v reflect.Value) (RuntimeVal, *diag.CustomError) {
+	keys := make([]RuntimeVal, 0, v.Len())
+	values := make(map[RuntimeVal]reflect.Value, v.Len())
+	for _, key := range v.MapKeys() {
+		converted, err := toValue(name, key)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := toMapKey(converted); err != nil {
+			return nil, diag.NewCustomError(fmt.Sprintf("Cannot convert %s, object keys can be string or number only, got %s", name, v.Type().Key()))
+		}
+		keys = append(keys, converted)
+		values[converted] = v.MapIndex(key)
+	}
+
+	sort.Slice(keys, func(a, b int) bool {
+		af, aNumber := toFloat(keys[a])
+		bf, bNumber := toFloat(keys[b])
+		if aNumber && bNumber {
+			return af < bf
+		}
+		if aNumber != bNumber {
+			return aNumber
+		}
+		return displayValue(keys[a]) < displayValue(keys[b])
+	})
+
+	object := makeObject()
+	for _, key := range keys {
+		value, err := toValue(name+"."+displayValue(key), values[key])
+		if err != nil {
+			return nil, err
+		}
+		object.set(key, value)
+	}
+
+	return object, nil
+}
+
+// propertyName is the property of an exported struct field, its name or
+// the one of its gl tag, fields tagged gl:"-" are left out.
+func propertyName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	tag := field.Tag.Get("gl")
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	}
+
+	return tag, true
+}
+
+// hostFunction makes a native of a Go function, the last result may be
+// an error, it fails the script when not nil.
+func hostFunction(name string, fn reflect.Value) (*NativeFnValue, *diag.CustomError) {
+	t := fn.Type()
+	results := t.NumOut()
+	failing := results > 0 && t.Out(results-1) == errorType
+	if failing {
+		results--
+	}
+	if results > 1 {
+		return nil, diag.NewCustomError(fmt.Sprintf("Cannot register %s, functions return a value and an error at most", name))
+	}
+
+	params := make([]reflect.Type, t.NumIn())
+	for index := range params {
+		params[index] = t.In(index)
+	}
+	variadic := t.IsVariadic()
+	if variadic {
+		params[len(params)-1] = params[len(params)-1].Elem()
+	}
+
+	return makeHostFn(func(args []RuntimeVal) (result RuntimeVal, err *diag.CustomError) {
+		if (!variadic && len(args) != len(params)) || (variadic && len(args) < len(params)-1) {
+			return nil, diag.NewCustomError(fmt.Sprintf("%s takes %s, got %d", name, arity(len(params), variadic), len(args)))
+		}
+
+		in := make([]reflect.Value, len(args))
+		for index, arg := range args {
+			param := params[len(params)-1]
+			if index < len(params) {
+				param = params[index]
+			}
+			converted, cErr := fromValue(fmt.Sprintf("Argument %d of %s", index+1, name), arg, param)
+			if cErr != nil {
+				return nil, cErr
+			}
+			in[index] = converted
+		}
+
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				result, err = nil, diag.NewCustomError(fmt.Sprintf("%s failed, %v", name, recovered))
+			}
+		}()
+		out := fn.Call(in)
+
+		if failing {
+			if goErr, _ := out[len(out)-1].Interface().(error); goErr != nil {
+				// A limit hit or a permission denied in a script the
+				// function called stops the run as it would have
+				var scriptErr *diag.CustomError
+				if errors.As(goErr, &scriptErr) && scriptErr.Kind() != diag.ErrorScript {
+					return nil, scriptErr
+				}
+				return nil, diag.NewCausedError(fmt.Sprintf("%s failed, %s", name, goErr), goErr)
+			}
+		}
+		if results == 0 {
+			return makeNull(), nil
+		}
+
+		return toValue("result of "+name, out[0])
+	}), nil
+}
+
+func arity(params int, variadic bool) string {
+	if variadic {
+		params--
+	}
+
+	s := fmt.Sprintf("%d arguments", params)
+	if params == 1 {
+		s = "1 argument"
+	}
+	if variadic {
+		s = "at least " + s
+	}
+
+	return s
+}
+
+// fromValue converts the value of a script to the Go type t, what names the
+// value in the errors.
+func fromValue(what string, value RuntimeVal, t reflect.Type) (reflect.Value, *diag.CustomError) {
+	if t == runtimeValType {
+		return reflect.ValueOf(&value).Elem(), nil
+	}
+
+	if _, null := value.(*NullVal); null {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	converted := reflect.New(t).Elem()
+	ok := true
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := fromValue(what, value, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		converted = reflect.New(t.Elem())
+		converted.Elem().Set(elem)
+	case reflect.Interface:
+		goValue := reflect.ValueOf(GoValue(value))
+		ok = goValue.IsValid() && goValue.Type().Implements(t)
+		if ok {
+			converted.Set(goValue)
+		}
+	case reflect.Bool:
+		var b *BoolVal
+		if b, ok = value.(*BoolVal); ok {
+			converted.SetBool(b.Value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, ok = toInteger(value)
+		ok = ok && !converted.OverflowInt(n)
+		if ok {
+			converted.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n int64
+		n, ok = toInteger(value)
+		ok = ok && n >= 0 && !converted.OverflowUint(uint64(n))
+		if ok {
+			converted.SetUint(uint64(n))
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, ok = toFloat(value); ok {
+			converted.SetFloat(f)
+		}
+	case reflect.String:
+		var s *StringVal
+		if s, ok = value.(*StringVal); ok {
+			converted.SetString(s.Value)
+		}
+	case reflect.Slice, reflect.Array:
+		var array *ArrayVal
+		array, ok = value.(*ArrayVal)
+		ok = ok && (t.Kind() == reflect.Slice || t.Len() == len(array.elements))
+		if !ok {
+			break
+		}
+		if t.Kind() == reflect.Slice {
+			converted = reflect.MakeSlice(t, len(array.elements), len(array.elements))
+		}
+		for index, element := range array.elements {
+			elem, err := fromValue(fmt.Sprintf("%s[%d]", what, index), element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			converted.Index(index).Set(elem)
+		}
+	case reflect.Map:
+		var object *ObjectVal
+		if object, ok = value.(*ObjectVal); !ok {
+			break
+		}
+		converted = reflect.MakeMapWithSize(t, len(object.keys))
+		for _, key := range object.keys {
+			k, err := fromValue(what+" key", key.value(), t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v, err := fromValue(what+"."+displayValue(key.value()), object.properties[key], t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			converted.SetMapIndex(k, v)
+		}
+	case reflect.Struct:
+		var object *ObjectVal
+		if object, ok = value.(*ObjectVal); !ok {
+			break
+		}
+		for index := 0; index < t.NumField(); index++ {
+			property, exported := propertyName(t.Field(index))
+			if !exported {
+				continue
+			}
+			propertyValue, exist := object.getProperty(property)
+			if !exist {
+				continue
+			}
+			field, err := fromValue(what+"."+property, propertyValue, t.Field(index).Type)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			converted.Field(index).Set(field)
+		}
+	default:
+		return reflect.Value{}, diag.NewCustomError(fmt.Sprintf("%s cannot be converted to %s", what, t))
+	}
+
+	if !ok {
+		return reflect.Value{}, diag.NewCustomError(fmt.Sprintf("%s must be %s, got %s", what, staticTypeOf(t), displayNested(value)))
+	}
+
+	return converted, nil
+}
+
+// staticTypeOf names the values of scripts a Go type is converted from.
+func staticTypeOf(t reflect.Type) ast.StaticType {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return staticTypeOf(t.Elem())
+	case reflect.Bool:
+		return ast.TypeBool
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ast.TypeInt
+	case reflect.Float32, reflect.Float64:
+		return ast.TypeNumber
+	case reflect.String:
+		return ast.TypeString
+	case reflect.Slice:
+		return ast.TypeArray
+	case reflect.Array:
+		return ast.StaticType(fmt.Sprintf("%s of %d elements", ast.TypeArray, t.Len()))
+	case reflect.Map, reflect.Struct:
+		return ast.TypeObject
+	}
+
+	return ast.StaticType(strings.ToLower(t.String()))
+}
@@ -0,0 +1,92 @@
+package runtime
+
+import (
+	"errors"
+	"testing"
+
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+)
+
+func runWithHost(t *testing.T, source string, name string, fn interface{}) *diag.CustomError {
+	env, err := NewEnvironments(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Register(name, fn); err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := parser.NewParser().ProduceAST(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewInterpreter().Run(program, env)
+
+	return err
+}
+
+func TestHostErrorKinds(t *testing.T) {
+	errNotFound := errors.New("not found")
+	limit := diag.NewKindError(diag.ErrorStepLimit, "Step limit exceeded, more than 10 steps")
+
+	tests := []struct {
+		name    string
+		fn      interface{}
+		kind    diag.ErrorKind
+		message string
+		cause   error
+	}{
+		{"plain", func() error { return errNotFound }, diag.ErrorScript, "lookup failed, not found", errNotFound},
+		{"limit", func() (int, error) { return 0, limit }, diag.ErrorStepLimit, limit.Message, nil},
+		{"script", func() error { return diag.NewCustomError("Variable x could not be resolved") }, diag.ErrorScript, "lookup failed, Variable x could not be resolved", nil},
+	}
+
+	for _, test := range tests {
+		err := runWithHost(t, "lookup()", "lookup", test.fn)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if err.Kind() != test.kind || err.Message != test.message {
+			t.Errorf("%s: got %q of kind %d, expected %q of kind %d", test.name, err.Message, err.Kind(), test.message, test.kind)
+		}
+		if test.cause != nil && !errors.Is(err, test.cause) {
+			t.Errorf("%s: %v does not wrap %v", test.name, err, test.cause)
+		}
+	}
+}
+
+func TestRegisterNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"lookup", true},
+		{"lookup_customer", true},
+		{"_private", true},
+		{"café", true},
+		{"u8", false},
+		{"", false},
+		{"two words", false},
+		{"a.b", false},
+		{"let", false},
+		{"match", false},
+	}
+
+	for _, test := range tests {
+		env, err := NewEnvironments(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = env.Register(test.name, 1)
+		if test.valid && err != nil {
+			t.Errorf("%q: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%q: expected an invalid name", test.name)
+		}
+	}
+}
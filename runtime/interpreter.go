@@ -0,0 +1,186 @@
+// Package runtime checks, optimizes, compiles and runs syntax trees on
+// the tree engine or the VM, with the natives, modules and permissions.
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+// Engine runs the programs of an interpreter, the tree engine evaluates the
+// syntax tree and the VM runs the bytecode compiled from it.
+type Engine string
+
+const (
+	EngineTree Engine = "tree"
+	EngineVM   Engine = "vm"
+)
+
+// DefaultMaxCallDepth is deep enough for recursive code and far from the
+// size of the Go stack the tree engine recurses on.
+const DefaultMaxCallDepth = 10000
+
+type Interpreter struct {
+	Modules *ModuleLoader
+	Engine  Engine
+	// Optimize runs the optimizer over scripts and modules once checked
+	Optimize bool
+	// callDepth counts the user function calls in progress, a call past
+	// MaxCallDepth fails with a stack overflow. Tail calls are not counted.
+	callDepth    int
+	MaxCallDepth int
+	// ctx, maxSteps and maxAlloc bound the runs of the interpreter, see
+	// limits.go, zero limits are unlimited
+	ctx context.Context
+	// outer are the contexts of the runs in progress when a host function
+	// started another run, which stops once any of them is done
+	outer []context.Context
+	// running counts the runs in progress, nested is set when the run
+	// about to start was started during one of them
+	running  int
+	nested   bool
+	steps    int64
+	MaxSteps int64
+	// stepsLeft counts down to the next check of the limits, which counts
+	// the stepsChecked steps since the previous one
+	stepsLeft    int64
+	stepsChecked int64
+	allocated    int64
+	MaxAlloc     int64
+}
+
+func NewInterpreter() *Interpreter {
+	return &Interpreter{Modules: newModuleLoader(), Engine: EngineTree, MaxCallDepth: DefaultMaxCallDepth, ctx: context.Background(), stepsLeft: 1, stepsChecked: 1}
+}
+
+// enterCall counts a user function call, the caller adds the call chain.
+func (i *Interpreter) enterCall() *diag.CustomError {
+	if i.callDepth >= i.MaxCallDepth {
+		return diag.NewKindError(diag.ErrorStackOverflow, fmt.Sprintf("Stack overflow, more than %d nested calls", i.MaxCallDepth))
+	}
+	i.callDepth++
+
+	return nil
+}
+
+func (i *Interpreter) leaveCall() {
+	i.callDepth--
+}
+
+// Run runs a program in env with the engine of the interpreter, imported
+// modules run with the same engine.
+func (i *Interpreter) Run(program *ast.Program, env *Environments) (RuntimeVal, *diag.CustomError) {
+	defer i.startRun()()
+
+	if i.Engine != EngineVM {
+		return i.evaluate(program, env)
+	}
+
+	chunk, err := CompileProgram(program)
+	if err != nil {
+		return nil, err
+	}
+
+	return newVM(i).run(chunk, env)
+}
+
+func (i *Interpreter) evaluate(astNode ast.Stmter, env *Environments) (RuntimeVal, *diag.CustomError) {
+	if err := i.step(); err != nil {
+		return nil, err
+	}
+
+	return i.evalNode(astNode, env)
+}
+
+// evaluateTagged evaluates a node like evaluate, the numbers of literals,
+// operators and local variables stay inline through it.
+func (i *Interpreter) evaluateTagged(astNode ast.Stmter, env *Environments) (taggedVal, *diag.CustomError) {
+	if err := i.step(); err != nil {
+		return taggedVal{}, err
+	}
+
+	switch node := astNode.(type) {
+	case *ast.IntegerLiteral:
+		return inlineInt(node.Value), nil
+	case *ast.NumericLiteral:
+		return inlineFloat(node.Value), nil
+	case *ast.Identifier:
+		value, err := env.lookupTaggedAt(node.Symbol, node.Slot)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, node)
+		}
+		return value, nil
+	case *ast.BinaryExpession:
+		return i.evalBinaryExpression(node, env)
+	case *ast.VariableDeclaration:
+		return i.evalVarDeclaration(node, env)
+	case *ast.AssignmentExpr:
+		return i.evalAssignment(node, env)
+	case *ast.IfExpression:
+		return i.evalIfExpr(node, env)
+	}
+
+	value, err := i.evalNode(astNode, env)
+
+	return tag(value), err
+}
+
+// evalNode evaluates a node once its step is counted.
+func (i *Interpreter) evalNode(astNode ast.Stmter, env *Environments) (RuntimeVal, *diag.CustomError) {
+	kind := astNode.Kind()
+	switch kind {
+	case ast.NodeTypeNumericLiteral:
+		return makeNumber(astNode.(*ast.NumericLiteral).Value), nil
+	case ast.NodeTypeIntegerLiteral:
+		return makeInteger(astNode.(*ast.IntegerLiteral).Value), nil
+	case ast.NodeTypeStringLIteral:
+		return makeString(astNode.(*ast.StringLiteral).Value), nil
+	case ast.NodeTypeBinaryExpession:
+		return boxed(i.evalBinaryExpression(astNode.(*ast.BinaryExpession), env))
+	case ast.NodeTypeUnaryExpression:
+		return i.evalUnaryExpression(astNode.(*ast.UnaryExpression), env)
+	case ast.NodeTypeProgram:
+		return i.evalProgram(astNode.(*ast.Program), env)
+	case ast.NodeTypeIdentifier:
+		return i.evalIdentifier(astNode.(*ast.Identifier), env)
+	case ast.NodeTypeVariableDeclaration:
+		return boxed(i.evalVarDeclaration(astNode.(*ast.VariableDeclaration), env))
+	case ast.NodeTypeAssigmentExpression:
+		return boxed(i.evalAssignment(astNode.(*ast.AssignmentExpr), env))
+	case ast.NodeTypeObjectLiteral:
+		return i.evalObjectExpr(astNode.(*ast.ObjectLiteral), env)
+	case ast.NodeTypeArrayLiteral:
+		return i.evalArrayExpr(astNode.(*ast.ArrayLiteral), env)
+	case ast.NodeTypeMemberExpression:
+		return i.evalMemberExpr(astNode.(*ast.MemberExpression), env)
+	case ast.NodeTypeCallExpression:
+		return i.evalCallExpr(astNode.(*ast.CallExpression), env)
+	case ast.NodeTypeFunctionDeclaration:
+		return i.evalFunctionDeclaration(astNode.(*ast.FunctionDeclaration), env)
+	case ast.NodeTypeConditionExpression:
+		return i.evalConditionDeclaration(astNode.(*ast.ConditionDeclaration), env)
+	case ast.NodeTypeIfExpression:
+		return boxed(i.evalIfExpr(astNode.(*ast.IfExpression), env))
+	case ast.NodeTypeForExpression:
+		return i.evalForExpr(astNode.(*ast.ForExpression), env)
+	case ast.NodeTypeForEachExpression:
+		return i.evalForEachExpr(astNode.(*ast.ForEachExpression), env)
+	case ast.NodeTypeMatchExpression:
+		return i.evalMatchExpr(astNode.(*ast.MatchExpression), env)
+	case ast.NodeTypeImportDeclaration:
+		return i.evalImportDeclaration(astNode.(*ast.ImportDeclaration), env)
+	case ast.NodeTypeExportDeclaration:
+		return i.evalExportDeclaration(astNode.(*ast.ExportDeclaration), env)
+	case ast.NodeTypeBreakExpression:
+		return i.evalBreakExpr(astNode.(*ast.BreakExpression), env)
+	case ast.NodeTypeContinueExpression:
+		return i.evalContinueExpr(astNode.(*ast.ContinueExpression), env)
+	case ast.NodeTypeSwitchExpression:
+		return i.evalSwitchExpr(astNode.(*ast.SwitchExpression), env)
+	default:
+		return nil, diag.NewCustomError(fmt.Sprintf("This AST node has not yet been setup for interpretation %s", kind)).AddSpan(astNode.Pos(), astNode.End())
+	}
+}
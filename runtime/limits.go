@@ -0,0 +1,207 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"aolbrich/lexer/diag"
+)
+
+// A run of untrusted code is bounded by the context of the interpreter, a
+// budget of steps and a budget of allocations. Every limit hit aborts the
+// run with an error of its own kind, so the host can tell it from an error
+// of the script.
+//
+// A step is a node evaluated by the tree engine or an instruction run by
+// the VM, the context is checked every contextCheckSteps steps. Natives
+// count as one step and are not interrupted, sleep and input run to the
+// end.
+//
+// Allocations are approximate, strings count their bytes and arrays and
+// objects allocationSlot bytes for every element or property, when made or
+// grown. Memory is counted once allocated and not given back when freed.
+const (
+	contextCheckSteps = 1024
+	allocationSlot    = 16
+)
+
+// WithContext makes the runs of the interpreter stop once ctx is done,
+// the step and allocation budgets start over. A run started by a host
+// function during another run stops once either context is done and
+// counts against the budgets of the run in progress instead.
+func (i *Interpreter) WithContext(ctx context.Context) *Interpreter {
+	if i.running > 0 {
+		i.outer = append(i.outer, i.ctx)
+		i.ctx = ctx
+		i.nested = true
+		return i
+	}
+
+	i.ctx = ctx
+	i.outer = nil
+	i.steps, i.allocated = 0, 0
+	i.stepsLeft, i.stepsChecked = 1, 1
+
+	return i
+}
+
+// startRun counts a run in progress until the function it returns is
+// called, which gives a nested run's context back to the outer run.
+func (i *Interpreter) startRun() func() {
+	nested := i.nested
+	i.nested = false
+	i.running++
+
+	return func() {
+		i.running--
+		if nested {
+			last := len(i.outer) - 1
+			i.ctx = i.outer[last]
+			i.outer = i.outer[:last]
+		}
+	}
+}
+
+// step counts a step of the run, the limits are checked once the steps
+// left before the next check run out.
+func (i *Interpreter) step() *diag.CustomError {
+	i.stepsLeft--
+	if i.stepsLeft > 0 {
+		return nil
+	}
+
+	return i.checkSteps()
+}
+
+// checkSteps fails past the step budget or once the context is done, then
+// counts down to the next check, no later than the step past the budget.
+func (i *Interpreter) checkSteps() *diag.CustomError {
+	i.steps += i.stepsChecked
+	if i.MaxSteps > 0 && i.steps > i.MaxSteps {
+		return diag.NewKindError(diag.ErrorStepLimit, fmt.Sprintf("Step limit exceeded, more than %d steps", i.MaxSteps))
+	}
+
+	if err := i.checkContext(); err != nil {
+		return err
+	}
+
+	i.stepsChecked = contextCheckSteps
+	if left := i.MaxSteps - i.steps; i.MaxSteps > 0 && left < i.stepsChecked {
+		i.stepsChecked = left
+		if left < 1 {
+			i.stepsChecked = 1
+		}
+	}
+	i.stepsLeft = i.stepsChecked
+
+	return nil
+}
+
+func (i *Interpreter) checkContext() *diag.CustomError {
+	err := i.ctx.Err()
+	for index := len(i.outer) - 1; err == nil && index >= 0; index-- {
+		err = i.outer[index].Err()
+	}
+
+	switch err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return diag.NewKindError(diag.ErrorDeadline, "Deadline exceeded")
+	}
+
+	return diag.NewKindError(diag.ErrorCanceled, "Run canceled")
+}
+
+// allocate counts size bytes allocated by the run, failing past the
+// allocation budget.
+func (i *Interpreter) allocate(size int) *diag.CustomError {
+	if i.MaxAlloc == 0 {
+		return nil
+	}
+
+	i.allocated += int64(size)
+	if i.allocated > i.MaxAlloc {
+		return diag.NewKindError(diag.ErrorAllocationLimit, fmt.Sprintf("Allocation limit exceeded, more than %d bytes allocated", i.MaxAlloc))
+	}
+
+	return nil
+}
+
+// allocateValue counts the allocation of a new string, array or object.
+func (i *Interpreter) allocateValue(value RuntimeVal) *diag.CustomError {
+	switch v := value.(type) {
+	case *StringVal:
+		return i.allocate(len(v.Value))
+	case *ArrayVal:
+		return i.allocate(len(v.elements) * allocationSlot)
+	case *ObjectVal:
+		return i.allocate(len(v.keys) * allocationSlot)
+	}
+
+	return nil
+}
+
+// callNative calls a native function once its guard let it, counting the
+// value it returns and the elements it added to the arrays it was given as
+// allocations.
+func (i *Interpreter) callNative(fn *NativeFnValue, args []RuntimeVal, env *Environments) (RuntimeVal, *diag.CustomError) {
+	if fn.guard != nil {
+		if err := fn.guard(args); err != nil {
+			return nil, err
+		}
+	}
+
+	if i.MaxAlloc == 0 {
+		return i.invoke(fn, args, env)
+	}
+
+	lengths := make([]int, len(args))
+	for index, arg := range args {
+		if array, ok := arg.(*ArrayVal); ok {
+			lengths[index] = len(array.elements)
+		}
+	}
+
+	result, err := i.invoke(fn, args, env)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, arg := range args {
+		if array, ok := arg.(*ArrayVal); ok && len(array.elements) > lengths[index] {
+			if err := i.allocate((len(array.elements) - lengths[index]) * allocationSlot); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, i.allocateValue(result)
+}
+
+// invoke runs a native, those waiting on the world outside return once the
+// run or a run around it is done, and the run fails.
+func (i *Interpreter) invoke(fn *NativeFnValue, args []RuntimeVal, env *Environments) (RuntimeVal, *diag.CustomError) {
+	if fn.contextCall == nil {
+		return fn.invoke(args, env)
+	}
+
+	ctx, cancel := context.WithCancel(i.ctx)
+	defer cancel()
+	for _, outer := range i.outer {
+		go func(outer context.Context) {
+			select {
+			case <-outer.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}(outer)
+	}
+
+	result := fn.contextCall(ctx, args)
+	if err := i.checkContext(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
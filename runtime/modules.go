@@ -0,0 +1,412 @@
+package runtime
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+)
+
+// stdlib holds the standard library modules written in the language itself,
+// `import "std/strings"` loads stdlib/std/strings.gl.
+//
+//go:embed stdlib
+var stdlib embed.FS
+
+const (
+	// SearchPathEnv lists directories searched for non relative imports
+	SearchPathEnv = "GLPATH"
+	// embeddedPrefix marks module files loaded from stdlib
+	embeddedPrefix = "embed:"
+	moduleExt      = ".gl"
+)
+
+// Module is a .gl file evaluated in its own root environment.
+type Module struct {
+	file    string
+	env     *Environments
+	exports map[string]bool
+	// names are the exports in the order they are declared
+	names []string
+}
+
+// export marks name as exported once.
+func (m *Module) export(name string) {
+	if m.exports[name] {
+		return
+	}
+	m.exports[name] = true
+	m.names = append(m.names, name)
+}
+
+// ModuleLoader evaluates every module once and caches it by resolved path,
+// loading holds the chain of modules being evaluated to detect cycles.
+// Imports not starting with ./ or ../ are looked up in searchPath first,
+// then in the embedded standard library.
+type ModuleLoader struct {
+	modules    map[string]*Module
+	Sources    map[string]string
+	loading    []string
+	SearchPath []string
+	// Warn receives the warnings of the resolver, nil ignores them
+	Warn func(warning *diag.CustomError)
+}
+
+func newModuleLoader() *ModuleLoader {
+	return &ModuleLoader{
+		modules:    make(map[string]*Module),
+		Sources:    make(map[string]string),
+		SearchPath: SplitSearchPath(os.Getenv(SearchPathEnv)),
+	}
+}
+
+func SplitSearchPath(list string) []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(list) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// EvaluateModule runs a parsed module in env, which becomes the module root.
+func (i *Interpreter) EvaluateModule(file string, source string, program *ast.Program, env *Environments) (RuntimeVal, *diag.CustomError) {
+	defer i.startRun()()
+
+	return i.enterModule(file, source, env, func() (RuntimeVal, *diag.CustomError) {
+		return i.Run(program, env)
+	})
+}
+
+// EvaluateCompiledModule runs a module loaded from its compiled program.
+func (i *Interpreter) EvaluateCompiledModule(file string, source string, chunk *Chunk, env *Environments) (RuntimeVal, *diag.CustomError) {
+	defer i.startRun()()
+
+	return i.enterModule(file, source, env, func() (RuntimeVal, *diag.CustomError) {
+		return newVM(i).run(chunk, env)
+	})
+}
+
+func (i *Interpreter) enterModule(file string, source string, env *Environments, run func() (RuntimeVal, *diag.CustomError)) (RuntimeVal, *diag.CustomError) {
+	l := i.Modules
+	for index, loading := range l.loading {
+		if loading == file {
+			chain := append(append([]string{}, l.loading[index:]...), file)
+			for c := range chain {
+				chain[c] = DisplayFileName(chain[c])
+			}
+			return nil, diag.NewCustomError("Cyclic import " + strings.Join(chain, " -> "))
+		}
+	}
+
+	module := &Module{file: file, env: env, exports: make(map[string]bool)}
+	env.module = module
+	l.Sources[file] = source
+
+	l.loading = append(l.loading, file)
+	defer func() {
+		l.loading = l.loading[:len(l.loading)-1]
+	}()
+
+	result, err := run()
+	if err != nil {
+		return nil, err.InFile(file)
+	}
+
+	// Cached once evaluated, a module still loading is reported as a cycle
+	l.modules[file] = module
+
+	return result, nil
+}
+
+// loadModule loads a module once, it runs with the permissions of the
+// script importing it and the files it is read from need the read
+// capability, the embedded standard library does not.
+func (i *Interpreter) loadModule(file string, permissions *Permissions) (*Module, *diag.CustomError) {
+	if module, ok := i.Modules.modules[file]; ok {
+		return module, nil
+	}
+
+	if !strings.HasPrefix(file, embeddedPrefix) {
+		if err := permissions.checkPath(CapRead, "import", file); err != nil {
+			return nil, err
+		}
+	}
+
+	source, readErr := readModuleSource(file)
+	if readErr != nil {
+		return nil, diag.NewCustomError(fmt.Sprintf("Cannot import %s, %s", DisplayFileName(file), readErr.Error()))
+	}
+
+	if chunk := i.LoadPrecompiled(file, source); chunk != nil {
+		env, err := NewSandboxEnvironments(permissions)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = i.EvaluateCompiledModule(file, source, chunk, env)
+		if err != nil {
+			return nil, err
+		}
+
+		return i.Modules.modules[file], nil
+	}
+
+	p := parser.NewParser()
+	program, err := p.ProduceAST(source)
+	if err != nil {
+		i.Modules.Sources[file] = source
+		return nil, err.InFile(file)
+	}
+
+	env, err := NewSandboxEnvironments(permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	i.Modules.Sources[file] = source
+	err = i.CheckProgram(file, program, env)
+	if err != nil {
+		return nil, err
+	}
+	i.OptimizeProgram(program)
+
+	_, err = i.EvaluateModule(file, source, program, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.Modules.modules[file], nil
+}
+
+// CheckProgram resolves and type checks a program about to run in env
+// before any of its statements is executed, warnings are passed to the
+// Warn hook. Types are checked once the names resolve.
+func (i *Interpreter) CheckProgram(file string, program *ast.Program, env *Environments) *diag.CustomError {
+	warnings, err := newResolver(env).resolveProgram(program)
+	if i.Modules.Warn != nil {
+		for _, warning := range warnings {
+			i.Modules.Warn(warning.InFile(file))
+		}
+	}
+
+	if err != nil {
+		return err.InFile(file)
+	}
+
+	err = newTypeChecker().checkProgram(program)
+	if err != nil {
+		return err.InFile(file)
+	}
+
+	return nil
+}
+
+// LoadPrecompiled returns the program compiled next to a source file when
+// the VM runs it and it was compiled from source, the module is not parsed
+// nor checked again. Stale and damaged files are reported as warnings.
+func (i *Interpreter) LoadPrecompiled(file string, source string) *Chunk {
+	if i.Engine != EngineVM || strings.HasPrefix(file, embeddedPrefix) {
+		return nil
+	}
+
+	compiledFile := CompiledPath(file)
+	compiled, err := ReadCompiled(compiledFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err == nil && !compiled.CompiledFrom(source) {
+		err = ErrStaleBytecode
+	}
+
+	if err != nil {
+		if i.Modules.Warn != nil {
+			i.Modules.Warn(diag.NewCustomError(fmt.Sprintf("Ignoring %s, %s, running the source", DisplayFileName(compiledFile), err)))
+		}
+		return nil
+	}
+
+	return compiled.Chunk
+}
+
+func readModuleSource(file string) (string, error) {
+	if strings.HasPrefix(file, embeddedPrefix) {
+		content, err := stdlib.ReadFile(strings.TrimPrefix(file, embeddedPrefix))
+		return string(content), err
+	}
+
+	return ReadFile(file)
+}
+
+// resolveModulePath resolves relative imports against the directory of the
+// importing module, or the working directory outside of modules, and other
+// imports against the search path and the embedded standard library.
+func (i *Interpreter) resolveModulePath(importPath string, env *Environments) (string, *diag.CustomError) {
+	if !strings.HasSuffix(importPath, moduleExt) {
+		importPath += moduleExt
+	}
+
+	if filepath.IsAbs(importPath) {
+		return filepath.Clean(importPath), nil
+	}
+
+	importer := ""
+	if module := env.root().module; module != nil {
+		importer = module.file
+	}
+
+	if strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../") {
+		if strings.HasPrefix(importer, embeddedPrefix) {
+			return embeddedPrefix + path.Join(path.Dir(strings.TrimPrefix(importer, embeddedPrefix)), importPath), nil
+		}
+
+		dir := "."
+		if importer != "" {
+			dir = filepath.Dir(importer)
+		}
+
+		resolved, err := filepath.Abs(filepath.Join(dir, importPath))
+		if err != nil {
+			return filepath.Join(dir, importPath), nil
+		}
+
+		return resolved, nil
+	}
+
+	for _, dir := range i.Modules.SearchPath {
+		candidate, err := filepath.Abs(filepath.Join(dir, importPath))
+		if err != nil {
+			continue
+		}
+
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	embedded := path.Join("stdlib", importPath)
+	if _, err := stdlib.Open(embedded); err == nil {
+		return embeddedPrefix + embedded, nil
+	}
+
+	return "", diag.NewCustomError(fmt.Sprintf("Module %s not found in the search path or the standard library", importPath))
+}
+
+func (i *Interpreter) evalImportDeclaration(declaration *ast.ImportDeclaration, env *Environments) (RuntimeVal, *diag.CustomError) {
+	file, err := i.resolveModulePath(declaration.Path, env)
+	if err != nil {
+		return nil, err.AddSpan(declaration.Pos(), declaration.End())
+	}
+
+	module, err := i.loadModule(file, env.root().permissions)
+	if err != nil {
+		return nil, err.AddSpan(declaration.Pos(), declaration.End())
+	}
+
+	if declaration.Namespace != "" {
+		namespace := makeObject()
+		for _, name := range module.names {
+			value, err := module.env.lookupVar(name)
+			if err != nil {
+				return nil, err.AddSpan(declaration.Pos(), declaration.End())
+			}
+			namespace.set(makeString(name), value)
+		}
+
+		_, err = env.declareVar(declaration.Namespace, namespace, true)
+		if err != nil {
+			return nil, err.AddSpan(declaration.Pos(), declaration.End())
+		}
+
+		return makeNull(), nil
+	}
+
+	for _, name := range declaration.Names {
+		if !module.exports[name.Name] {
+			return nil, diag.NewCustomError(fmt.Sprintf("Module %s does not export %s", declaration.Path, name.Name)).AddSpan(declaration.Pos(), declaration.End())
+		}
+
+		value, err := module.env.lookupVar(name.Name)
+		if err != nil {
+			return nil, err.AddSpan(declaration.Pos(), declaration.End())
+		}
+
+		_, err = env.declareVar(name.Alias, value, true)
+		if err != nil {
+			return nil, err.AddSpan(declaration.Pos(), declaration.End())
+		}
+	}
+
+	return makeNull(), nil
+}
+
+func (i *Interpreter) evalExportDeclaration(declaration *ast.ExportDeclaration, env *Environments) (RuntimeVal, *diag.CustomError) {
+	if env.module == nil {
+		return nil, diag.NewCustomError("Export is allowed at the top level of a module only").AddSpan(declaration.Pos(), declaration.End())
+	}
+
+	result, err := i.evaluate(declaration.Declaration, env)
+	if err != nil {
+		return nil, i.formatError(err, declaration)
+	}
+
+	for _, name := range declaredNames(declaration.Declaration) {
+		env.module.export(name)
+	}
+
+	return result, nil
+}
+
+// declaredNames lists the names a declaration introduces.
+func declaredNames(declaration ast.Stmter) []string {
+	switch d := declaration.(type) {
+	case *ast.FunctionDeclaration:
+		return []string{d.Name}
+	case *ast.VariableDeclaration:
+		if d.Pattern != nil {
+			return patternNames(d.Pattern)
+		}
+		return []string{d.Identifier}
+	}
+
+	return nil
+}
+
+func patternNames(pattern ast.Stmter) []string {
+	switch p := pattern.(type) {
+	case *ast.Identifier:
+		return []string{p.Symbol}
+	case *ast.DestructuringPattern:
+		var names []string
+		for _, element := range p.Elements {
+			names = append(names, patternNames(element.Target)...)
+		}
+		return names
+	}
+
+	return nil
+}
+
+func DisplayFileName(file string) string {
+	if strings.HasPrefix(file, embeddedPrefix) {
+		return strings.TrimPrefix(file, embeddedPrefix+"stdlib/")
+	}
+
+	if abs, err := filepath.Abs("."); err == nil {
+		if rel, err := filepath.Rel(abs, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+
+	return file
+}
@@ -0,0 +1,20 @@
+package runtime
+
+import "testing"
+
+func TestNamespaceKeepsExportOrder(t *testing.T) {
+	expected := `["repeat", "reverse", "indexOf", "contains", "startsWith", "endsWith", "padLeft", "padRight", "trim", "split", "join"]`
+
+	for _, engine := range engines {
+		// Maps are iterated in a random order, a few runs catch it
+		for run := 0; run < 5; run++ {
+			result, err := runSource(t, engine, false, "import \"std/strings\";\nkeys(strings)")
+			if err != nil {
+				t.Fatalf("%s: %v", engine, err)
+			}
+			if got := displayValue(result); got != expected {
+				t.Fatalf("%s: expected %s, got %s", engine, expected, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type FunctionCall func([]RuntimeVal, *Environments) RuntimeVal
+
+// contextCall is called instead of call for the natives waiting on the
+// world outside, they return once ctx is done and the run then fails.
+type contextCall func(ctx context.Context, args []RuntimeVal) RuntimeVal
+
+func ntPrint(args []RuntimeVal, env *Environments) RuntimeVal {
+	return ntPrinter(args, env, false)
+}
+
+func ntPrintLn(args []RuntimeVal, env *Environments) RuntimeVal {
+	return ntPrinter(args, env, true)
+}
+
+func ntPrinter(args []RuntimeVal, env *Environments, ln bool) RuntimeVal {
+	succ := false
+	for _, arg := range args {
+		if v, ok := arg.(*NumberVal); ok {
+			fmt.Print(v.Value)
+			succ = true
+		}
+
+		if v, ok := arg.(*IntVal); ok {
+			fmt.Print(v.Value)
+			succ = true
+		}
+
+		if v, ok := arg.(*StringVal); ok {
+			fmt.Print(v.Value)
+			succ = true
+		}
+
+		if v, ok := arg.(*NullVal); ok {
+			fmt.Print(v.Value)
+			succ = true
+		}
+
+		if v, ok := arg.(*BoolVal); ok {
+			fmt.Print(v.Value)
+			succ = true
+		}
+
+		if v, ok := arg.(*ObjectVal); ok {
+			fmt.Print(displayValue(v))
+			succ = true
+		}
+
+		if v, ok := arg.(*ArrayVal); ok {
+			fmt.Print(displayValue(v))
+			succ = true
+		}
+
+		if succ == false {
+			fmt.Print("Cannot print this data type")
+		}
+
+		if ln == true {
+			fmt.Println()
+		}
+
+	}
+
+	return makeNull()
+}
+
+func ntTime(args []RuntimeVal, env *Environments) RuntimeVal {
+	currentTime := time.Now().Unix()
+
+	return makeInteger(currentTime)
+}
+
+func ntNumToString(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) == 0 {
+		return makeNull()
+	}
+
+	if n, ok := args[0].(*IntVal); ok {
+		return makeString(strconv.FormatInt(n.Value, 10))
+	}
+
+	if n, ok := args[0].(*NumberVal); ok {
+		s := strconv.FormatFloat(n.Value, 'f', -1, 64)
+		return makeString(s)
+	}
+
+	return makeNull()
+}
+
+func ntStringToNum(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) == 0 {
+		return makeNull()
+	}
+
+	if s, ok := args[0].(*StringVal); ok {
+		if i, err := strconv.ParseInt(s.Value, 10, 64); err == nil {
+			return makeInteger(i)
+		}
+
+		n, err := strconv.ParseFloat(s.Value, 64)
+		if err == nil {
+			return makeNumber(n)
+		}
+
+	}
+
+	return makeNull()
+}
+
+func ntInput(args []RuntimeVal, env *Environments) RuntimeVal {
+	reader := bufio.NewReader(os.Stdin)
+	text, _ := reader.ReadString('\n')
+
+	return makeString(strings.TrimSuffix(text, "\n"))
+}
+
+func ntRound(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) == 0 {
+		return makeNull()
+	}
+
+	if n, ok := args[0].(*IntVal); ok {
+		return n
+	}
+
+	if n, ok := args[0].(*NumberVal); ok {
+		d := 1.0
+		if len(args) > 1 {
+			if n2, ok := toNumberVal(args[1]); ok {
+				d = n2.Value * 10
+			}
+		}
+		n := math.Round(n.Value*d) / d
+		return makeNumber(n)
+	}
+
+	return makeNull()
+}
+
+func ntRand(args []RuntimeVal, env *Environments) RuntimeVal {
+	rng := 100
+	if len(args) > 0 {
+		if n, ok := toNumberVal(args[0]); ok {
+			rng = int(n.Value)
+		}
+	}
+
+	return makeInteger(int64(rand.Intn(rng)))
+}
+
+func ntLen(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) == 0 {
+		return makeNull()
+	}
+
+	if s, ok := args[0].(*StringVal); ok {
+		n := utf8.RuneCountInString(s.Value)
+		return makeInteger(int64(n))
+	}
+
+	if a, ok := args[0].(*ArrayVal); ok {
+		return makeInteger(int64(len(a.elements)))
+	}
+
+	if o, ok := args[0].(*ObjectVal); ok {
+		return makeInteger(int64(len(o.keys)))
+	}
+
+	return makeNull()
+}
+
+func ntSubstr(args []RuntimeVal, env *Environments) RuntimeVal {
+
+	if len(args) < 3 {
+		return makeNull()
+	}
+
+	if s, ok := args[0].(*StringVal); ok {
+		if p1, ok := toNumberVal(args[1]); ok {
+			if p2, ok := toNumberVal(args[2]); ok {
+				chars := []rune(s.Value)
+				start, end := int(p1.Value), int(p2.Value)
+				if start < 0 || start > end || end > len(chars) {
+					return makeNull()
+				}
+				return makeString(string(chars[start:end]))
+			}
+		}
+	}
+
+	return makeNull()
+}
+
+// ntSleep waits for the milliseconds given or until the run is done.
+func ntSleep(ctx context.Context, args []RuntimeVal) RuntimeVal {
+	if len(args) == 0 {
+		return makeNull()
+	}
+
+	if d, ok := toNumberVal(args[0]); ok {
+		timer := time.NewTimer(time.Duration(d.Value * float64(time.Millisecond)))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	return makeNull()
+}
+
+func ntFileRead(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) == 0 {
+		return makeBool(false)
+	}
+
+	if fileName, ok := args[0].(*StringVal); ok {
+		fileData, err := ioutil.ReadFile(fileName.Value)
+		if err != nil {
+			return makeBool(false)
+		}
+		return makeString(string(fileData))
+	}
+
+	return makeBool(false)
+}
+
+func ntFileWrite(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) < 2 {
+		return makeBool(false)
+	}
+	if fileName, ok := args[0].(*StringVal); ok {
+		if fileContent, ok := args[1].(*StringVal); ok {
+			data := []byte(fileContent.Value)
+			err := ioutil.WriteFile(fileName.Value, data, 0644)
+			if err != nil {
+				return makeBool(false)
+			}
+			return makeBool(true)
+		}
+	}
+
+	return makeBool(false)
+}
+
+func ntInt(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) == 0 {
+		return makeNull()
+	}
+
+	switch v := args[0].(type) {
+	case *IntVal:
+		return v
+	case *NumberVal:
+		if math.IsNaN(v.Value) || v.Value < math.MinInt64 || v.Value >= math.MaxInt64 {
+			return makeNull()
+		}
+		return makeInteger(int64(v.Value))
+	case *StringVal:
+		if i, err := strconv.ParseInt(strings.TrimSpace(v.Value), 10, 64); err == nil {
+			return makeInteger(i)
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v.Value), 64); err == nil {
+			return ntInt([]RuntimeVal{makeNumber(f)}, env)
+		}
+	case *BoolVal:
+		if v.Value {
+			return makeInteger(1)
+		}
+		return makeInteger(0)
+	}
+
+	return makeNull()
+}
+
+func ntFloat(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) == 0 {
+		return makeNull()
+	}
+
+	switch v := args[0].(type) {
+	case *IntVal, *NumberVal:
+		n, _ := toNumberVal(v)
+		return n
+	case *StringVal:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v.Value), 64); err == nil {
+			return makeNumber(f)
+		}
+	case *BoolVal:
+		if v.Value {
+			return makeNumber(1)
+		}
+		return makeNumber(0)
+	}
+
+	return makeNull()
+}
+
+func ntKeys(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) > 0 {
+		if o, ok := args[0].(*ObjectVal); ok {
+			return makeArray(o.orderedKeys())
+		}
+	}
+
+	return makeNull()
+}
+
+func ntValues(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) > 0 {
+		if o, ok := args[0].(*ObjectVal); ok {
+			return makeArray(o.orderedValues())
+		}
+	}
+
+	return makeNull()
+}
+
+func ntEntries(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) > 0 {
+		if o, ok := args[0].(*ObjectVal); ok {
+			keys := o.orderedKeys()
+			values := o.orderedValues()
+			entries := make([]RuntimeVal, 0, len(keys))
+			for i, key := range keys {
+				entries = append(entries, makeArray([]RuntimeVal{key, values[i]}))
+			}
+			return makeArray(entries)
+		}
+	}
+
+	return makeNull()
+}
+
+func ntHas(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) < 2 {
+		return makeBool(false)
+	}
+
+	if o, ok := args[0].(*ObjectVal); ok {
+		_, exist := o.get(args[1])
+		return makeBool(exist)
+	}
+
+	return makeBool(false)
+}
+
+func ntDelete(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) < 2 {
+		return makeBool(false)
+	}
+
+	if o, ok := args[0].(*ObjectVal); ok {
+		return makeBool(o.remove(args[1]))
+	}
+
+	return makeBool(false)
+}
+
+func ntRange(args []RuntimeVal, env *Environments) RuntimeVal {
+	bounds := make([]int64, 0, 3)
+	for _, arg := range args {
+		n, ok := toInteger(arg)
+		if !ok {
+			return makeNull()
+		}
+		bounds = append(bounds, n)
+	}
+
+	switch len(bounds) {
+	case 1:
+		return makeRange(0, bounds[0], 1)
+	case 2:
+		return makeRange(bounds[0], bounds[1], 1)
+	case 3:
+		if bounds[2] == 0 {
+			return makeNull()
+		}
+		return makeRange(bounds[0], bounds[1], bounds[2])
+	}
+
+	return makeNull()
+}
+
+func ntPush(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) < 2 {
+		return makeNull()
+	}
+
+	if a, ok := args[0].(*ArrayVal); ok {
+		a.elements = append(a.elements, args[1:]...)
+		return makeInteger(int64(len(a.elements)))
+	}
+
+	return makeNull()
+}
+
+func ntGetEnv(args []RuntimeVal, env *Environments) RuntimeVal {
+	if len(args) > 0 {
+		if name, ok := args[0].(*StringVal); ok {
+			if value, exist := os.LookupEnv(name.Value); exist {
+				return makeString(value)
+			}
+		}
+	}
+
+	return makeNull()
+}
+
+// ntExec runs a command with the arguments given and returns its output,
+// false when it cannot run or fails. The command is killed once the run is
+// done.
+func ntExec(ctx context.Context, args []RuntimeVal) RuntimeVal {
+	if len(args) == 0 {
+		return makeBool(false)
+	}
+
+	command := make([]string, 0, len(args))
+	for _, arg := range args {
+		s, ok := arg.(*StringVal)
+		if !ok {
+			return makeBool(false)
+		}
+		command = append(command, s.Value)
+	}
+
+	output, err := exec.CommandContext(ctx, command[0], command[1:]...).Output()
+	if err != nil {
+		return makeBool(false)
+	}
+
+	return makeString(string(output))
+}
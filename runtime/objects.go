@@ -0,0 +1,168 @@
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"aolbrich/lexer/diag"
+)
+
+// mapKey is the normalised form of an object key, numbers with an integer
+// value share the same key whether they were stored as integer or float.
+type mapKey struct {
+	kind ValueType
+	str  string
+	num  int64
+	flt  float64
+}
+
+func makeObject() *ObjectVal {
+	return &ObjectVal{Type: ValueObject, properties: make(map[mapKey]RuntimeVal)}
+}
+
+func makeArray(elements []RuntimeVal) *ArrayVal {
+	return &ArrayVal{Type: ValueArray, elements: elements}
+}
+
+func toMapKey(key RuntimeVal) (mapKey, *diag.CustomError) {
+	switch k := key.(type) {
+	case *StringVal:
+		return mapKey{kind: ValueTypeString, str: k.Value}, nil
+	case *IntVal:
+		return mapKey{kind: ValueTypeInteger, num: k.Value}, nil
+	case *NumberVal:
+		if n, ok := toInteger(k); ok {
+			return mapKey{kind: ValueTypeInteger, num: n}, nil
+		}
+		return mapKey{kind: ValueTypeNumber, flt: k.Value}, nil
+	}
+
+	return mapKey{}, diag.NewCustomError(fmt.Sprintf("Object keys can be string or number only, got %s", displayValue(key)))
+}
+
+func (k mapKey) value() RuntimeVal {
+	switch k.kind {
+	case ValueTypeInteger:
+		return makeInteger(k.num)
+	case ValueTypeNumber:
+		return makeNumber(k.flt)
+	default:
+		return makeString(k.str)
+	}
+}
+
+func (o *ObjectVal) get(key RuntimeVal) (RuntimeVal, bool) {
+	k, err := toMapKey(key)
+	if err != nil {
+		return nil, false
+	}
+
+	v, ok := o.properties[k]
+
+	return v, ok
+}
+
+func (o *ObjectVal) getProperty(name string) (RuntimeVal, bool) {
+	v, ok := o.properties[mapKey{kind: ValueTypeString, str: name}]
+
+	return v, ok
+}
+
+func (o *ObjectVal) set(key RuntimeVal, value RuntimeVal) *diag.CustomError {
+	k, err := toMapKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, exist := o.properties[k]; !exist {
+		o.keys = append(o.keys, k)
+	}
+	o.properties[k] = value
+
+	return nil
+}
+
+func (o *ObjectVal) remove(key RuntimeVal) bool {
+	k, err := toMapKey(key)
+	if err != nil {
+		return false
+	}
+
+	if _, exist := o.properties[k]; !exist {
+		return false
+	}
+
+	delete(o.properties, k)
+	for i, existing := range o.keys {
+		if existing == k {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// orderedKeys returns the keys in insertion order.
+func (o *ObjectVal) orderedKeys() []RuntimeVal {
+	keys := make([]RuntimeVal, 0, len(o.keys))
+	for _, k := range o.keys {
+		keys = append(keys, k.value())
+	}
+
+	return keys
+}
+
+func (o *ObjectVal) orderedValues() []RuntimeVal {
+	values := make([]RuntimeVal, 0, len(o.keys))
+	for _, k := range o.keys {
+		values = append(values, o.properties[k])
+	}
+
+	return values
+}
+
+func displayValue(v RuntimeVal) string {
+	switch val := v.(type) {
+	case *NumberVal:
+		return fmt.Sprint(val.Value)
+	case *IntVal:
+		return strconv.FormatInt(val.Value, 10)
+	case *StringVal:
+		return val.Value
+	case *NullVal:
+		return val.Value
+	case *BoolVal:
+		return fmt.Sprint(val.Value)
+	case *ArrayVal:
+		items := make([]string, 0, len(val.elements))
+		for _, e := range val.elements {
+			items = append(items, displayNested(e))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *ObjectVal:
+		items := make([]string, 0, len(val.keys))
+		for _, k := range val.keys {
+			items = append(items, displayNested(k.value())+": "+displayNested(val.properties[k]))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	case *RangeVal:
+		return fmt.Sprintf("range(%d, %d, %d)", val.start, val.end, val.step)
+	case *FnValue:
+		return fmt.Sprintf("fn %s", val.name)
+	case *NativeFnValue:
+		return "native fn"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+// displayNested quotes strings so keys and values inside collections stay readable.
+func displayNested(v RuntimeVal) string {
+	if s, ok := v.(*StringVal); ok {
+		return strconv.Quote(s.Value)
+	}
+
+	return displayValue(v)
+}
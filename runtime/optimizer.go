@@ -0,0 +1,373 @@
+package runtime
+
+import (
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+// Optimizer rewrites a resolved program before it runs: binary and
+// comparison expressions of literals are folded into their result, `if`
+// branches behind a literal condition are dropped and constants bound to a
+// literal are replaced by the literal where they are read.
+//
+// The program must have been through the resolver, constants are matched to
+// their readers by slot like the interpreter does, so a shadowing name is
+// never replaced. Only constants declared directly in the body of their
+// scope are inlined, the ones of an if branch or a loop body may not exist.
+type Optimizer struct {
+	interpreter *Interpreter
+	// root holds the constants of the module root by name, scopes the ones
+	// of the function calls, iterations and match arms being walked by slot
+	root   map[string]ast.Stmter
+	scopes []map[int]ast.Stmter
+}
+
+func newOptimizer(i *Interpreter) *Optimizer {
+	return &Optimizer{interpreter: i, root: make(map[string]ast.Stmter)}
+}
+
+// OptimizeProgram optimizes program in place when the interpreter was asked
+// to, after the checks since the optimizer relies on the resolved slots.
+func (i *Interpreter) OptimizeProgram(program *ast.Program) {
+	if i.Optimize {
+		newOptimizer(i).optimizeProgram(program)
+	}
+}
+
+func (o *Optimizer) optimizeProgram(program *ast.Program) {
+	program.Body = o.optimizeBody(program.Body, true)
+}
+
+func (o *Optimizer) openScope() {
+	o.scopes = append(o.scopes, make(map[int]ast.Stmter))
+}
+
+func (o *Optimizer) closeScope() {
+	o.scopes = o.scopes[:len(o.scopes)-1]
+}
+
+// optimizeBody optimizes the statements of a block, top is set for the
+// body of a scope. Statements reduced to a literal have no effect and are
+// dropped, unless last since the last value is the value of the block.
+func (o *Optimizer) optimizeBody(body []ast.Stmter, top bool) []ast.Stmter {
+	optimized := body[:0]
+	for index, statement := range body {
+		statement = o.optimize(statement)
+		if top {
+			declaration := statement
+			if export, ok := statement.(*ast.ExportDeclaration); ok {
+				declaration = export.Declaration
+			}
+			if declaration, ok := declaration.(*ast.VariableDeclaration); ok {
+				o.declareConstant(declaration)
+			}
+		}
+
+		if index < len(body)-1 && isLiteral(statement) {
+			continue
+		}
+		optimized = append(optimized, statement)
+	}
+
+	return optimized
+}
+
+func (o *Optimizer) declareConstant(declaration *ast.VariableDeclaration) {
+	if !declaration.Constant || declaration.Pattern != nil || !isValueLiteral(declaration.Value) {
+		return
+	}
+
+	if declaration.Slot == nil {
+		o.root[declaration.Identifier] = declaration.Value
+		return
+	}
+
+	if len(o.scopes) > 0 && declaration.Slot.Depth == 0 {
+		o.scopes[len(o.scopes)-1][declaration.Slot.Index] = declaration.Value
+	}
+}
+
+// constant returns the literal of the constant an identifier reads, nil
+// when it reads anything else.
+func (o *Optimizer) constant(identifier *ast.Identifier) ast.Stmter {
+	if identifier.Slot == nil {
+		return o.root[identifier.Symbol]
+	}
+
+	scope := len(o.scopes) - 1 - identifier.Slot.Depth
+	if scope < 0 {
+		return nil
+	}
+
+	return o.scopes[scope][identifier.Slot.Index]
+}
+
+func (o *Optimizer) optimize(node ast.Stmter) ast.Stmter {
+	switch n := node.(type) {
+	case *ast.VariableDeclaration:
+		n.Value = o.optimize(n.Value)
+		o.optimizePattern(n.Pattern)
+	case *ast.FunctionDeclaration:
+		o.openScope()
+		for _, parameter := range n.Parameters {
+			o.optimizePattern(parameter)
+		}
+		n.Body = o.optimizeBody(n.Body, true)
+		o.closeScope()
+	case *ast.ExportDeclaration:
+		n.Declaration = o.optimize(n.Declaration)
+	case *ast.IfExpression:
+		return o.optimizeIf(n)
+	case *ast.ForExpression:
+		n.Declaration = o.optimize(n.Declaration)
+		n.Condition = o.optimize(n.Condition)
+		n.Body = o.optimizeBody(n.Body, false)
+		n.AfterCondition = o.optimize(n.AfterCondition)
+		n.IncrementalExpression = o.optimize(n.IncrementalExpression)
+	case *ast.ForEachExpression:
+		n.Iterable = o.optimize(n.Iterable)
+		o.openScope()
+		n.Body = o.optimizeBody(n.Body, true)
+		o.closeScope()
+	case *ast.SwitchExpression:
+		n.Value = o.optimize(n.Value)
+		for index := range n.Body {
+			swcase := &n.Body[index]
+			scoped := o.optimizeCaseCondition(swcase.Condition)
+			swcase.Body = o.optimizeBody(swcase.Body, false)
+			if scoped {
+				o.closeScope()
+			}
+		}
+	case *ast.MatchExpression:
+		n.Value = o.optimize(n.Value)
+		for _, arm := range n.Arms {
+			scoped := o.optimizeCaseCondition(arm.Condition)
+			arm.Body = o.optimize(arm.Body)
+			if scoped {
+				o.closeScope()
+			}
+		}
+	case *ast.AssignmentExpr:
+		n.Value = o.optimize(n.Value)
+		switch n.Assigne.(type) {
+		case *ast.Identifier:
+		case *ast.DestructuringPattern:
+			o.optimizePattern(n.Assigne)
+		default:
+			n.Assigne = o.optimize(n.Assigne)
+		}
+	case *ast.BinaryExpession:
+		n.Left = o.optimize(n.Left)
+		n.Right = o.optimize(n.Right)
+		return o.fold(n, n.Left, n.Right, func(lhs, rhs RuntimeVal) (RuntimeVal, *diag.CustomError) {
+			return o.interpreter.binaryOp(lhs, rhs, n.Operator)
+		})
+	case *ast.ConditionDeclaration:
+		n.Left = o.optimize(n.Left)
+		n.Right = o.optimize(n.Right)
+		return o.fold(n, n.Left, n.Right, func(lhs, rhs RuntimeVal) (RuntimeVal, *diag.CustomError) {
+			return o.interpreter.compareOp(lhs, rhs, n.Operator)
+		})
+	case *ast.UnaryExpression:
+		n.Operand = o.optimize(n.Operand)
+	case *ast.Identifier:
+		if constant := o.constant(n); constant != nil {
+			return copyLiteral(constant, n)
+		}
+	case *ast.ObjectLiteral:
+		for _, property := range n.Properties {
+			property.ComputedKey = o.optimize(property.ComputedKey)
+			property.Value = o.optimize(property.Value)
+		}
+	case *ast.ArrayLiteral:
+		for index, element := range n.Elements {
+			n.Elements[index] = o.optimize(element)
+		}
+	case *ast.CallExpression:
+		for _, arg := range n.Args {
+			*arg = o.optimize(*arg)
+		}
+		n.Caller = o.optimize(n.Caller)
+	case *ast.MemberExpression:
+		n.Object = o.optimize(n.Object)
+		if n.Computed {
+			n.Propert = o.optimize(n.Propert)
+		}
+	}
+
+	return node
+}
+
+// optimizeIf drops the branches a literal condition never takes, an if
+// without a branch left is null like an if whose condition does not hold.
+func (o *Optimizer) optimizeIf(n *ast.IfExpression) ast.Stmter {
+	n.Condition = o.optimize(n.Condition)
+	n.Body = o.optimizeBody(n.Body, false)
+
+	condition, known := boolLiteral(n.Condition)
+	switch {
+	case known && condition:
+		n.ElseExpression = nil
+		return n
+	case known && !condition:
+		if n.ElseExpression == nil {
+			return &ast.Identifier{Stmt: ast.NewStmt(ast.NodeTypeIdentifier, n.Pos(), n.End()), Symbol: "null"}
+		}
+		return o.optimize(n.ElseExpression)
+	}
+
+	if n.ElseExpression != nil {
+		elseExpression := o.optimize(n.ElseExpression)
+		if _, ok := elseExpression.(*ast.IfExpression); ok {
+			n.ElseExpression = elseExpression
+		} else {
+			// A dropped else if leaves nothing to run
+			n.ElseExpression = nil
+		}
+	}
+
+	return n
+}
+
+// optimizeCaseCondition returns true when it opened the scope of the names
+// the condition binds, like the resolver does.
+func (o *Optimizer) optimizeCaseCondition(condition *ast.CaseCondition) bool {
+	for index, value := range condition.Values {
+		condition.Values[index] = o.optimize(value)
+	}
+
+	scoped := condition.Binding != "" || condition.Pattern != nil
+	if scoped {
+		o.openScope()
+	}
+
+	if pattern, ok := condition.Pattern.(*ast.DestructuringPattern); ok {
+		o.optimizePattern(pattern)
+	}
+	condition.Guard = o.optimize(condition.Guard)
+
+	return scoped
+}
+
+// optimizePattern optimizes the default values of a destructuring pattern,
+// the names it binds are left alone.
+func (o *Optimizer) optimizePattern(pattern ast.Stmter) {
+	p, ok := pattern.(*ast.DestructuringPattern)
+	if !ok {
+		return
+	}
+
+	for _, element := range p.Elements {
+		element.DefaultValue = o.optimize(element.DefaultValue)
+		o.optimizePattern(element.Target)
+	}
+}
+
+// fold replaces an operation on two literals by its result, operations
+// failing or giving something other than a number, a string or a bool are
+// left to fail or run as they would.
+func (o *Optimizer) fold(node ast.Stmter, left ast.Stmter, right ast.Stmter, operation func(lhs, rhs RuntimeVal) (RuntimeVal, *diag.CustomError)) ast.Stmter {
+	lhs, ok := literalValue(left)
+	if !ok {
+		return node
+	}
+	rhs, ok := literalValue(right)
+	if !ok {
+		return node
+	}
+
+	result, err := operation(lhs, rhs)
+	if err != nil {
+		return node
+	}
+
+	pos, end := node.Pos(), node.End()
+	switch v := result.(type) {
+	case *IntVal:
+		return &ast.IntegerLiteral{Stmt: ast.NewStmt(ast.NodeTypeIntegerLiteral, pos, end), Value: v.Value}
+	case *NumberVal:
+		return &ast.NumericLiteral{Stmt: ast.NewStmt(ast.NodeTypeNumericLiteral, pos, end), Value: v.Value}
+	case *StringVal:
+		return &ast.StringLiteral{Stmt: ast.NewStmt(ast.NodeTypeStringLIteral, pos, end), Value: v.Value}
+	case *BoolVal:
+		symbol := "false"
+		if v.Value {
+			symbol = "true"
+		}
+		return &ast.Identifier{Stmt: ast.NewStmt(ast.NodeTypeIdentifier, pos, end), Symbol: symbol}
+	}
+
+	return node
+}
+
+// literalValue returns the value of a number or string literal, or of true
+// and false read from the module root.
+func literalValue(node ast.Stmter) (RuntimeVal, bool) {
+	switch n := node.(type) {
+	case *ast.IntegerLiteral:
+		return makeInteger(n.Value), true
+	case *ast.NumericLiteral:
+		return makeNumber(n.Value), true
+	case *ast.StringLiteral:
+		return makeString(n.Value), true
+	}
+
+	if value, ok := boolLiteral(node); ok {
+		return makeBool(value), true
+	}
+
+	return nil, false
+}
+
+func boolLiteral(node ast.Stmter) (bool, bool) {
+	identifier, ok := node.(*ast.Identifier)
+	if !ok || identifier.Slot != nil {
+		return false, false
+	}
+
+	switch identifier.Symbol {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+
+	return false, false
+}
+
+func isValueLiteral(node ast.Stmter) bool {
+	switch node.(type) {
+	case *ast.IntegerLiteral, *ast.NumericLiteral, *ast.StringLiteral:
+		return true
+	}
+
+	return false
+}
+
+// isLiteral tells whether evaluating node has no effect.
+func isLiteral(node ast.Stmter) bool {
+	if isValueLiteral(node) {
+		return true
+	}
+
+	identifier, ok := node.(*ast.Identifier)
+
+	return ok && identifier.Slot == nil && (identifier.Symbol == "true" || identifier.Symbol == "false" || identifier.Symbol == "null")
+}
+
+// copyLiteral copies a constant literal to where it is read, errors point
+// to the reader.
+func copyLiteral(literal ast.Stmter, at ast.Stmter) ast.Stmter {
+	stmt := ast.NewStmt(literal.Kind(), at.Pos(), at.End())
+	switch l := literal.(type) {
+	case *ast.IntegerLiteral:
+		return &ast.IntegerLiteral{Stmt: stmt, Value: l.Value}
+	case *ast.NumericLiteral:
+		return &ast.NumericLiteral{Stmt: stmt, Value: l.Value}
+	case *ast.StringLiteral:
+		return &ast.StringLiteral{Stmt: stmt, Value: l.Value}
+	}
+
+	return at
+}
@@ -0,0 +1,121 @@
+package runtime
+
+import (
+	"testing"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/parser"
+)
+
+// optimizeSource checks and optimizes a script, returns its last statement.
+func optimizeSource(t *testing.T, source string) ast.Stmter {
+	t.Helper()
+
+	program, err := parser.NewParser().ProduceAST(source)
+	if err != nil {
+		t.Fatalf("%q: %v", source, err)
+	}
+	env, err := NewEnvironments(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := NewInterpreter()
+	i.Optimize = true
+	if err := i.CheckProgram("", program, env); err != nil {
+		t.Fatalf("%q: %v", source, err)
+	}
+	i.OptimizeProgram(program)
+
+	return program.Body[len(program.Body)-1]
+}
+
+func TestFolding(t *testing.T) {
+	tests := []struct {
+		source string
+		// folded is the literal left of the last statement, empty when it
+		// is not folded
+		folded string
+	}{
+		{"60 * 60 * 24", "86400"},
+		{"\"prefix\" + \"suffix\"", "prefixsuffix"},
+		{"7 / 2", "3.5"},
+		{"1 < 2", "true"},
+		{"\"a\" == \"b\"", "false"},
+		{"const day = 86400;\nday * 2", "172800"},
+		{"const name = \"gl\";\nname + \"!\"", "gl!"},
+		{"if (false) { 1 } else { 2 }", ""},
+		{"1 << 63", ""},
+		{"1 / 0", ""},
+		{"let x = 2;\nx * 3", ""},
+		{"fn f(x) {\n    x\n}\nf(1) + 2", ""},
+	}
+
+	for _, test := range tests {
+		last := optimizeSource(t, test.source)
+		value, folded := literalValue(last)
+		switch {
+		case test.folded == "" && folded:
+			t.Errorf("%q: expected no folding, got %s", test.source, displayValue(value))
+		case test.folded != "" && (!folded || displayValue(value) != test.folded):
+			t.Errorf("%q: expected %s, got %s", test.source, test.folded, last.Kind())
+		}
+	}
+}
+
+func TestDeadBranches(t *testing.T) {
+	tests := []struct {
+		source string
+		kind   ast.NodeType
+		// branches is the number of branches left of an if
+		branches int
+	}{
+		{"if (true) { 1 } else { 2 }", ast.NodeTypeIfExpression, 1},
+		{"if (false) { 1 }", ast.NodeTypeIdentifier, 0},
+		{"if (false) { 1 } elseif (true) { 2 } else { 3 }", ast.NodeTypeIfExpression, 1},
+		{"let x = 1;\nif (x > 0) { 1 } else { 2 }", ast.NodeTypeIfExpression, 2},
+	}
+
+	for _, test := range tests {
+		last := optimizeSource(t, test.source)
+		if last.Kind() != test.kind {
+			t.Errorf("%q: expected %s, got %s", test.source, test.kind, last.Kind())
+			continue
+		}
+
+		branches := 0
+		for ifE, ok := last.(*ast.IfExpression); ok; ifE, ok = ifE.ElseExpression.(*ast.IfExpression) {
+			branches++
+		}
+		if branches != test.branches {
+			t.Errorf("%q: expected %d branches, got %d", test.source, test.branches, branches)
+		}
+	}
+}
+
+// TestConstantShadowing runs scripts reading a name shadowing an inlined
+// constant, with and without the optimizer on both engines.
+func TestConstantShadowing(t *testing.T) {
+	tests := []struct {
+		source string
+		result string
+	}{
+		{"const x = 1;\nfn f() {\n    let x = 2;\n    x\n}\nf() + x", "3"},
+		{"const x = 1;\nfn f(x) {\n    x * 10\n}\nf(5) + x", "51"},
+		{"const x = 1;\nlet total = 0;\nfor (const x of [5, 6]) {\n    total = total + x\n}\ntotal + x", "12"},
+		{"const n = 2;\nfn outer() {\n    const n = 3;\n    fn inner() {\n        n\n    }\n    inner()\n}\nouter() * n", "6"},
+		{"const x = 1;\nfn f() {\n    const x = \"inner\";\n    x\n}\nf() + numToStr(x)", "inner1"},
+		{"const x = 4;\nfn f() {\n    let { x } = { x: 7 };\n    x\n}\nf() - x", "3"},
+	}
+
+	for _, engine := range engines {
+		for _, optimize := range []bool{false, true} {
+			for _, test := range tests {
+				result, err := runSource(t, engine, optimize, test.source)
+				if err != nil || displayValue(result) != test.result {
+					t.Errorf("%s optimize=%v %q: expected %s, got %v %v", engine, optimize, test.source, test.result, displayValue(result), err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aolbrich/lexer/diag"
+)
+
+// Capability is an access to the world outside the script, natives using
+// one fail unless the root environment they were declared in grants it.
+type Capability string
+
+const (
+	CapRead   Capability = "read"
+	CapWrite  Capability = "write"
+	CapStdin  Capability = "stdin"
+	CapStdout Capability = "stdout"
+	CapEnv    Capability = "env"
+	CapExec   Capability = "exec"
+	CapClock  Capability = "clock"
+	CapRandom Capability = "random"
+)
+
+var Capabilities = []Capability{CapRead, CapWrite, CapStdin, CapStdout, CapEnv, CapExec, CapClock, CapRandom}
+
+// Permissions are the capabilities granted to the scripts run in a root
+// environment and to the modules they import. Reads and writes may be
+// limited to the files under a list of roots.
+type Permissions struct {
+	granted map[Capability]bool
+	// roots of the files read or written, a capability granted without
+	// roots reaches any file
+	roots map[Capability][]string
+}
+
+// AllowAll grants every capability, scripts run by the command have them
+// unless sandboxed.
+func AllowAll() *Permissions {
+	p := DenyAll()
+	for _, c := range Capabilities {
+		p.granted[c] = true
+	}
+
+	return p
+}
+
+func DenyAll() *Permissions {
+	return &Permissions{granted: make(map[Capability]bool), roots: make(map[Capability][]string)}
+}
+
+// Allow grants a capability, the paths given to read or write limit it to
+// the files under them. Granting it again adds to the roots, granting it
+// without paths lifts them.
+func (p *Permissions) Allow(c Capability, paths ...string) error {
+	if !isCapability(c) {
+		return fmt.Errorf("unknown capability %s", c)
+	}
+	if len(paths) > 0 && c != CapRead && c != CapWrite {
+		return fmt.Errorf("capability %s does not take paths", c)
+	}
+
+	if len(paths) == 0 {
+		p.granted[c] = true
+		delete(p.roots, c)
+		return nil
+	}
+
+	if p.granted[c] && len(p.roots[c]) == 0 {
+		// Already granted for any file
+		return nil
+	}
+
+	for _, path := range paths {
+		root, err := realPath(path)
+		if err != nil {
+			return err
+		}
+		p.roots[c] = append(p.roots[c], root)
+	}
+	p.granted[c] = true
+
+	return nil
+}
+
+// check fails unless the capability used by the native name is granted.
+func (p *Permissions) check(c Capability, name string) *diag.CustomError {
+	if p.granted[c] {
+		return nil
+	}
+
+	return newPermissionError(fmt.Sprintf("Permission denied, %s needs the %s capability", name, c))
+}
+
+// checkPath fails unless the file is under one of the roots the capability
+// was granted for, symbolic links are followed so none leads out of them.
+func (p *Permissions) checkPath(c Capability, name string, file string) *diag.CustomError {
+	if err := p.check(c, name); err != nil {
+		return err
+	}
+
+	roots := p.roots[c]
+	if len(roots) == 0 {
+		return nil
+	}
+
+	path, err := realPath(file)
+	if err == nil {
+		for _, root := range roots {
+			if isUnder(path, root) {
+				return nil
+			}
+		}
+	}
+
+	return newPermissionError(fmt.Sprintf("Permission denied, %s cannot %s %s outside of %s", name, c, file, strings.Join(roots, ", ")))
+}
+
+// nativeGuard runs before every call of a native, failing when the call
+// is not permitted.
+type nativeGuard func(args []RuntimeVal) *diag.CustomError
+
+// guard makes the natives using the capability check it on every call.
+func (p *Permissions) guard(c Capability, name string) nativeGuard {
+	return func(args []RuntimeVal) *diag.CustomError {
+		return p.check(c, name)
+	}
+}
+
+// pathGuard also checks the file named by the first argument.
+func (p *Permissions) pathGuard(c Capability, name string) nativeGuard {
+	return func(args []RuntimeVal) *diag.CustomError {
+		if len(args) > 0 {
+			if file, ok := args[0].(*StringVal); ok {
+				return p.checkPath(c, name, file.Value)
+			}
+		}
+
+		return p.check(c, name)
+	}
+}
+
+func isCapability(c Capability) bool {
+	for _, known := range Capabilities {
+		if c == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newPermissionError(m string) *diag.CustomError {
+	return diag.NewKindError(diag.ErrorPermission, m)
+}
+
+// maxLinks is the number of symbolic links realPath follows, past it the
+// path is refused like a loop of links.
+const maxLinks = 255
+
+// realPath makes a path absolute and follows its symbolic links one part
+// at a time like the system opening it does, so a ".." after a link leaves
+// the directory the link points to. The parts which do not exist are kept
+// as they are, a file about to be written does not exist yet, and a link to
+// a file not written yet is followed as well, writing through it creates
+// the file it points to.
+func realPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		// Not joined, joining would drop the ".." before the links are read
+		path = wd + string(filepath.Separator) + path
+	}
+
+	root := filepath.VolumeName(path) + string(filepath.Separator)
+	parts := splitPath(path[len(filepath.VolumeName(path)):])
+	resolved := root
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+
+		switch part {
+		case ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(next)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxLinks {
+			return "", fmt.Errorf("too many links in %s", path)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = filepath.VolumeName(target) + string(filepath.Separator)
+			target = target[len(filepath.VolumeName(target)):]
+		}
+		parts = append(splitPath(target), parts...)
+	}
+
+	return resolved, nil
+}
+
+// splitPath splits a path into its parts, without the empty ones.
+func splitPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
+
+func isUnder(path string, root string) bool {
+	rel, err := filepath.Rel(root, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
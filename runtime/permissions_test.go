@@ -0,0 +1,132 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+)
+
+func TestCheckPathFollowsLinks(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{data, outside} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		// dangling links, writing through them creates their target
+		"escape":         filepath.Join(outside, "escaped.txt"),
+		"relative":       "../outside/escaped.txt",
+		"chain":          "escape",
+		"inside":         filepath.Join(data, "new.txt"),
+		"outsideDir":     outside,
+		"loop":           "loop",
+		"existingInside": filepath.Join(data, "existing.txt"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(data, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(data, "existing.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := DenyAll()
+	if err := p.Allow(CapWrite, data); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file    string
+		allowed bool
+	}{
+		{filepath.Join(data, "file.txt"), true},
+		{filepath.Join(data, "sub", "file.txt"), true},
+		{filepath.Join(data, "existing.txt"), true},
+		{filepath.Join(data, "inside"), true},
+		{filepath.Join(data, "existingInside"), true},
+		{filepath.Join(data, "escape"), false},
+		{filepath.Join(data, "relative"), false},
+		{filepath.Join(data, "chain"), false},
+		{filepath.Join(data, "outsideDir", "file.txt"), false},
+		{filepath.Join(data, "loop"), false},
+		{filepath.Join(data, "..", "outside", "file.txt"), false},
+	}
+	for _, test := range tests {
+		err := p.checkPath(CapWrite, "fileWrite", test.file)
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("checkPath(%s) allowed %v, expected %v", test.file, allowed, test.allowed)
+		}
+	}
+
+	if _, err := os.Lstat(filepath.Join(outside, "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("escaped.txt was created outside of the root")
+	}
+}
+
+func TestCheckPathFollowsLinksBeforeParents(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	sub := filepath.Join(data, "sub")
+	nested := filepath.Join(dir, "outside", "nested")
+	for _, d := range []string{sub, nested} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(dir, "outside", "secret.txt"), filepath.Join(data, "public.txt")} {
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(nested, filepath.Join(data, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub", filepath.Join(data, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := DenyAll()
+	if err := p.Allow(CapRead, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Joined by hand, filepath.Join would drop the ".." before the links
+	sep := string(filepath.Separator)
+	tests := []struct {
+		file    string
+		allowed bool
+	}{
+		{data + sep + "link" + sep + ".." + sep + "secret.txt", false},
+		{data + sep + "link" + sep + ".." + sep + ".." + sep + "data" + sep + "public.txt", true},
+		{data + sep + "inside" + sep + ".." + sep + "public.txt", true},
+		{data + sep + "sub" + sep + ".." + sep + "public.txt", true},
+	}
+	for _, test := range tests {
+		for _, name := range []string{"fileRead", "import"} {
+			err := p.checkPath(CapRead, name, test.file)
+			if allowed := err == nil; allowed != test.allowed {
+				t.Errorf("%s of %s allowed %v, expected %v", name, test.file, allowed, test.allowed)
+			}
+		}
+	}
+
+	env, err := NewSandboxEnvironments(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := parser.NewParser().ProduceAST(`fileRead("` + filepath.ToSlash(tests[0].file) + `")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewInterpreter().Run(program, env); err == nil || err.Kind() != diag.ErrorPermission {
+		t.Errorf("expected a permission error reading through the link, got %v", err)
+	}
+}
@@ -1,8 +1,8 @@
-package main
+package runtime
 
 import "io/ioutil"
 
-func readFile(fileName string) (string, error) {
+func ReadFile(fileName string) (string, error) {
 	c, err := ioutil.ReadFile(fileName)
 
 	if err != nil {
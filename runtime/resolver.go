@@ -0,0 +1,480 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+// maxResolveErrors is the number of errors reported, the summary shows the
+// total.
+const maxResolveErrors = 20
+
+// Resolver checks a program before it runs, following the scopes the
+// interpreter creates: the module root, function calls, for of and for in
+// iterations and match bindings. If and for bodies share the enclosing
+// scope like they do at runtime.
+//
+// A name used in the same function it is declared in must be declared
+// before the use, a name used inside a function body may be declared
+// anywhere in the enclosing scopes since the body runs when called.
+//
+// Names declared outside the module root get a slot in their scope, the
+// identifiers using them are annotated with the slot and how many scopes
+// up it is, so the interpreter reads them without looking up the name.
+type Resolver struct {
+	scope    *resolverScope
+	errors   []*diag.CustomError
+	warnings []*diag.CustomError
+}
+
+type resolverScope struct {
+	parent   *resolverScope
+	names    map[string]*resolvedName
+	order    []*resolvedName
+	function bool
+	// slots are the indexes of the local variables, a name declared in
+	// several branches keeps one slot since only one of them runs
+	slots map[string]int
+	// pending are the names not found when used, checked once the scope
+	// has all its declarations
+	pending []*reference
+}
+
+type resolvedName struct {
+	name     string
+	constant bool
+	used     bool
+	// index is the slot of the name, -1 for names looked up by name
+	index int
+	// node is the declaration, nil for names declared before resolving
+	// like the native functions
+	node ast.Stmter
+}
+
+type reference struct {
+	name string
+	node ast.Stmter
+	// slot receives the place of the name once it is found depth scopes up
+	slot  **ast.Slot
+	depth int
+	// ordered is set while the reference is in the function it was made
+	// in, where the declaration must come first
+	ordered bool
+	assign  bool
+}
+
+// newResolver starts from the names of env and its parents, the defaults
+// of a root environment or the variables a prompt declared so far.
+func newResolver(env *Environments) *Resolver {
+	r := &Resolver{}
+	r.scope = &resolverScope{names: make(map[string]*resolvedName)}
+
+	for e := env; e != nil; e = e.parent {
+		for name := range e.variables {
+			if _, exist := r.scope.names[name]; exist {
+				continue
+			}
+			_, constant := e.constants[name]
+			r.scope.names[name] = &resolvedName{name: name, constant: constant, used: true, index: -1}
+		}
+	}
+
+	return r
+}
+
+// resolveProgram returns the warnings, and the errors as one error when
+// there is any, both in source order.
+func (r *Resolver) resolveProgram(program *ast.Program) ([]*diag.CustomError, *diag.CustomError) {
+	r.resolveBody(program.Body)
+	r.closeScope()
+
+	sortBySource(r.warnings)
+	if len(r.errors) > 0 {
+		sortBySource(r.errors)
+		total := len(r.errors)
+		if total > maxResolveErrors {
+			r.errors = r.errors[:maxResolveErrors]
+		}
+		return r.warnings, diag.NewErrorList(r.errors, total, "errors")
+	}
+
+	return r.warnings, nil
+}
+
+func sortBySource(errors []*diag.CustomError) {
+	sort.SliceStable(errors, func(a, b int) bool {
+		return errors[a].Trace[0].Pos < errors[b].Trace[0].Pos
+	})
+}
+
+func (r *Resolver) error(err *diag.CustomError) {
+	r.errors = append(r.errors, err)
+}
+
+func (r *Resolver) warn(message string, node ast.Stmter) {
+	r.warnings = append(r.warnings, diag.NewCustomError(message).AddSpan(node.Pos(), node.End()))
+}
+
+func (r *Resolver) openScope(function bool) {
+	r.scope = &resolverScope{parent: r.scope, names: make(map[string]*resolvedName), function: function, slots: make(map[string]int)}
+}
+
+// closeScope checks the pending references against the declarations of the
+// scope, the ones not found move on to the parent scope. Unused variables
+// are reported once the scope has been checked. It returns the number of
+// slots the scope needs.
+func (r *Resolver) closeScope() int {
+	scope := r.scope
+
+	for _, ref := range scope.pending {
+		declared, exist := scope.names[ref.name]
+		if !exist {
+			if scope.parent == nil {
+				r.error(diag.NewCustomError(fmt.Sprintf("Variable %s could not be resolved", ref.name)).AddSpan(ref.node.Pos(), ref.node.End()))
+				continue
+			}
+
+			ref.ordered = ref.ordered && !scope.function
+			ref.depth++
+			scope.parent.pending = append(scope.parent.pending, ref)
+			continue
+		}
+
+		if ref.ordered {
+			declared.used = true
+			r.error(diag.NewCustomError(fmt.Sprintf("Variable %s is used before its declaration", ref.name)).AddSpan(ref.node.Pos(), ref.node.End()))
+			continue
+		}
+
+		r.use(declared, ref)
+	}
+
+	for _, declared := range scope.order {
+		if !declared.used && declared.node != nil && !strings.HasPrefix(declared.name, "_") {
+			r.warn(fmt.Sprintf("Variable %s is declared but never used", declared.name), declared.node)
+		}
+	}
+
+	r.scope = scope.parent
+
+	return len(scope.slots)
+}
+
+// declare adds a name to the current scope, outside the module root the
+// name gets a slot stored in slot. A nil slot keeps the name looked up by
+// name, like imports.
+func (r *Resolver) declare(name string, node ast.Stmter, constant bool, warnUnused bool, slot **ast.Slot) {
+	if _, exist := r.scope.names[name]; exist {
+		r.error(diag.NewCustomError(fmt.Sprintf("Variable %s already exists", name)).AddSpan(node.Pos(), node.End()))
+		return
+	}
+
+	index := -1
+	if slot != nil && r.scope.parent != nil {
+		var placed bool
+		index, placed = r.scope.slots[name]
+		if !placed {
+			index = len(r.scope.slots)
+			r.scope.slots[name] = index
+		}
+		*slot = &ast.Slot{Index: index}
+	}
+
+	declared := &resolvedName{name: name, constant: constant, node: node, used: !warnUnused, index: index}
+	r.scope.names[name] = declared
+	r.scope.order = append(r.scope.order, declared)
+}
+
+func (r *Resolver) reference(name string, node ast.Stmter, assign bool, slot **ast.Slot) {
+	ref := &reference{name: name, node: node, ordered: true, assign: assign, slot: slot}
+
+	for scope := r.scope; scope != nil; scope = scope.parent {
+		if declared, exist := scope.names[name]; exist {
+			r.use(declared, ref)
+			return
+		}
+		ref.depth++
+	}
+	ref.depth = 0
+
+	r.scope.pending = append(r.scope.pending, ref)
+}
+
+func (r *Resolver) use(declared *resolvedName, ref *reference) {
+	if declared.index >= 0 {
+		*ref.slot = &ast.Slot{Depth: ref.depth, Index: declared.index}
+	}
+
+	if !ref.assign {
+		declared.used = true
+		return
+	}
+
+	if declared.constant {
+		r.error(diag.NewCustomError(fmt.Sprintf("Constant variable %s cannot be updated", ref.name)).AddSpan(ref.node.Pos(), ref.node.End()))
+	}
+}
+
+// resolveBody resolves the statements of a block, statements following a
+// break or continue in the same block are reported once.
+func (r *Resolver) resolveBody(body []ast.Stmter) {
+	for index, statement := range body {
+		r.resolve(statement)
+
+		kind := statement.Kind()
+		if (kind == ast.NodeTypeBreakExpression || kind == ast.NodeTypeContinueExpression) && index < len(body)-1 {
+			name := "break"
+			if kind == ast.NodeTypeContinueExpression {
+				name = "continue"
+			}
+			r.warn("Unreachable code after "+name, body[index+1])
+			for _, unreachable := range body[index+1:] {
+				r.resolve(unreachable)
+			}
+			return
+		}
+	}
+}
+
+// resolveBranches resolves blocks of which any may run, like the branches
+// of an if. They share the enclosing scope, so declarations of one branch
+// are hidden from the others and all of them are visible afterwards.
+func (r *Resolver) resolveBranches(branches ...func()) {
+	var declared []*resolvedName
+	for _, branch := range branches {
+		before := len(r.scope.order)
+		branch()
+
+		for _, name := range r.scope.order[before:] {
+			delete(r.scope.names, name.name)
+			declared = append(declared, name)
+		}
+		r.scope.order = r.scope.order[:before]
+	}
+
+	for _, name := range declared {
+		if _, exist := r.scope.names[name.name]; !exist {
+			r.scope.names[name.name] = name
+		}
+		r.scope.order = append(r.scope.order, name)
+	}
+}
+
+func (r *Resolver) resolve(node ast.Stmter) {
+	switch n := node.(type) {
+	case nil:
+		return
+	case *ast.VariableDeclaration:
+		r.resolve(n.Value)
+		if n.Pattern != nil {
+			r.declarePattern(n.Pattern, n.Constant, true)
+			return
+		}
+		r.declare(n.Identifier, n, n.Constant, true, &n.Slot)
+	case *ast.FunctionDeclaration:
+		r.declare(n.Name, n, true, false, &n.Slot)
+		r.openScope(true)
+		for _, param := range n.Parameters {
+			r.declarePattern(param, false, false)
+		}
+		r.resolveBody(n.Body)
+		n.Locals = r.closeScope()
+		if len(n.Body) > 0 {
+			markTailCalls(n.Body[len(n.Body)-1])
+		}
+	case *ast.IfExpression:
+		var branches []func()
+		for branch := n; branch != nil; {
+			current := branch
+			r.resolve(current.Condition)
+			branches = append(branches, func() { r.resolveBody(current.Body) })
+
+			next, _ := current.ElseExpression.(*ast.IfExpression)
+			branch = next
+		}
+		r.resolveBranches(branches...)
+	case *ast.ForExpression:
+		r.resolve(n.Declaration)
+		r.resolve(n.Condition)
+		r.resolveBranches(func() {
+			r.resolveBody(n.Body)
+			r.resolve(n.AfterCondition)
+			r.resolve(n.IncrementalExpression)
+		})
+	case *ast.ForEachExpression:
+		r.resolve(n.Iterable)
+		r.openScope(false)
+		r.declare(n.Identifier, n, n.Constant, false, &n.Slot)
+		r.resolveBody(n.Body)
+		n.Locals = r.closeScope()
+	case *ast.SwitchExpression:
+		r.resolve(n.Value)
+		var branches []func()
+		for _, swcase := range n.Body {
+			swcase := swcase
+			branches = append(branches, func() {
+				scoped := r.resolveCaseCondition(swcase.Condition)
+				r.resolveBody(swcase.Body)
+				if scoped {
+					swcase.Condition.Locals = r.closeScope()
+				}
+			})
+		}
+		r.resolveBranches(branches...)
+	case *ast.MatchExpression:
+		r.resolve(n.Value)
+		for _, arm := range n.Arms {
+			scoped := r.resolveCaseCondition(arm.Condition)
+			r.resolve(arm.Body)
+			if scoped {
+				arm.Condition.Locals = r.closeScope()
+			}
+		}
+	case *ast.ImportDeclaration:
+		if n.Namespace != "" {
+			r.declare(n.Namespace, n, true, false, nil)
+			return
+		}
+		for _, name := range n.Names {
+			r.declare(name.Alias, n, true, false, nil)
+		}
+	case *ast.ExportDeclaration:
+		r.resolve(n.Declaration)
+		for _, name := range declaredNames(n.Declaration) {
+			if declared, exist := r.scope.names[name]; exist {
+				declared.used = true
+			}
+		}
+	case *ast.AssignmentExpr:
+		r.resolve(n.Value)
+		r.resolveAssignTarget(n.Assigne)
+	case *ast.BinaryExpession:
+		r.resolve(n.Left)
+		r.resolve(n.Right)
+	case *ast.ConditionDeclaration:
+		r.resolve(n.Left)
+		r.resolve(n.Right)
+	case *ast.UnaryExpression:
+		r.resolve(n.Operand)
+	case *ast.Identifier:
+		r.reference(n.Symbol, n, false, &n.Slot)
+	case *ast.ObjectLiteral:
+		for _, property := range n.Properties {
+			r.resolve(property.ComputedKey)
+			if property.Value == nil && property.ComputedKey == nil {
+				r.reference(property.Key, property, false, &property.Slot)
+				continue
+			}
+			r.resolve(property.Value)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range n.Elements {
+			r.resolve(element)
+		}
+	case *ast.CallExpression:
+		for _, arg := range n.Args {
+			r.resolve(*arg)
+		}
+		r.resolve(n.Caller)
+	case *ast.MemberExpression:
+		r.resolve(n.Object)
+		if n.Computed {
+			r.resolve(n.Propert)
+		}
+	}
+}
+
+// markTailCalls marks the calls whose value is the value of the function,
+// the last statement of its body or of the branches and arms ending it.
+func markTailCalls(node ast.Stmter) {
+	switch n := node.(type) {
+	case *ast.CallExpression:
+		n.Tail = true
+	case *ast.IfExpression:
+		for branch := n; branch != nil; {
+			if len(branch.Body) > 0 {
+				markTailCalls(branch.Body[len(branch.Body)-1])
+			}
+
+			next, _ := branch.ElseExpression.(*ast.IfExpression)
+			branch = next
+		}
+	case *ast.MatchExpression:
+		for _, arm := range n.Arms {
+			markTailCalls(arm.Body)
+		}
+	}
+}
+
+func (r *Resolver) resolveAssignTarget(target ast.Stmter) {
+	switch t := target.(type) {
+	case *ast.Identifier:
+		r.reference(t.Symbol, t, true, &t.Slot)
+	case *ast.DestructuringPattern:
+		for _, element := range t.Elements {
+			r.resolve(element.DefaultValue)
+			r.resolveAssignTarget(element.Target)
+		}
+	default:
+		r.resolve(target)
+	}
+}
+
+func (r *Resolver) declarePattern(pattern ast.Stmter, constant bool, warnUnused bool) {
+	switch p := pattern.(type) {
+	case *ast.Identifier:
+		r.declare(p.Symbol, p, constant, warnUnused, &p.Slot)
+	case *ast.DestructuringPattern:
+		for _, element := range p.Elements {
+			r.resolve(element.DefaultValue)
+			r.declarePattern(element.Target, constant, warnUnused)
+		}
+	}
+}
+
+// resolveCaseCondition resolves a switch case or match arm condition, it
+// returns true when it opened a scope for the bound names.
+func (r *Resolver) resolveCaseCondition(condition *ast.CaseCondition) bool {
+	for _, value := range condition.Values {
+		r.resolve(value)
+	}
+
+	scoped := condition.Binding != "" || condition.Pattern != nil
+	if scoped {
+		r.openScope(false)
+	}
+
+	if condition.Binding != "" {
+		r.declare(condition.Binding, condition.Guard, false, false, &condition.BindingSlot)
+	}
+
+	if condition.Pattern != nil {
+		r.declareMatchPattern(condition.Pattern)
+	}
+
+	r.resolve(condition.Guard)
+
+	return scoped
+}
+
+// declareMatchPattern declares the names a match pattern captures, `_`,
+// true, false, null and literals compare instead.
+func (r *Resolver) declareMatchPattern(pattern ast.Stmter) {
+	switch p := pattern.(type) {
+	case *ast.Identifier:
+		switch p.Symbol {
+		case "_", "true", "false", "null":
+			return
+		}
+		r.declare(p.Symbol, p, false, false, &p.Slot)
+	case *ast.DestructuringPattern:
+		for _, element := range p.Elements {
+			r.resolve(element.DefaultValue)
+			r.declareMatchPattern(element.Target)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+)
+
+// spans lists errors as their message and the source they point to.
+func spans(source string, errors []*diag.CustomError) []string {
+	var listed []string
+	for _, err := range errors {
+		if len(err.Errors) > 0 {
+			listed = append(listed, spans(source, err.Errors)...)
+			continue
+		}
+
+		runes := []rune(source)
+		listed = append(listed, fmt.Sprintf("%s: %s", err.Message, string(runes[err.Trace[0].Pos:err.Trace[0].End])))
+	}
+
+	return listed
+}
+
+func TestResolver(t *testing.T) {
+	tests := []struct {
+		source   string
+		errors   []string
+		warnings []string
+	}{
+		{
+			"println(x)\nlet x = 1;",
+			[]string{"Variable x is used before its declaration: x"},
+			nil,
+		},
+		{
+			"println(y)",
+			[]string{"Variable y could not be resolved: y"},
+			nil,
+		},
+		{
+			"fn f() {\n    later()\n}\nfn later() {}\nf()",
+			nil,
+			nil,
+		},
+		{
+			"const limit = 3;\nlimit = 4\nlimit",
+			[]string{"Constant variable limit cannot be updated: limit"},
+			nil,
+		},
+		{
+			"const limit = 3;\nfn f() {\n    limit = 4\n}\nf()",
+			[]string{"Constant variable limit cannot be updated: limit"},
+			[]string{"Variable limit is declared but never used: const limit = 3;"},
+		},
+		{
+			"let x = 1;\nlet x = 2;\nx",
+			[]string{"Variable x already exists: let x = 2;"},
+			nil,
+		},
+		{
+			"let x = 1;\nfn f() {\n    let x = 2;\n    x\n}\nf() + x",
+			nil,
+			nil,
+		},
+		{
+			"fn f(a, a) {}\nf(1, 2)",
+			[]string{"Variable a already exists: a"},
+			nil,
+		},
+		{
+			"for (let i = 0; i < 3; i = i + 1) {\n    break\n    println(i)\n}",
+			nil,
+			[]string{"Unreachable code after break: println(i)"},
+		},
+		{
+			"for (let i = 0; i < 3; i = i + 1) {\n    continue\n    println(i)\n}",
+			nil,
+			[]string{"Unreachable code after continue: println(i)"},
+		},
+		{
+			"let unused = 1;",
+			nil,
+			[]string{"Variable unused is declared but never used: let unused = 1;"},
+		},
+		{
+			"println(a)\nprintln(b)",
+			[]string{"Variable a could not be resolved: a", "Variable b could not be resolved: b"},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		program, err := parser.NewParser().ProduceAST(test.source)
+		if err != nil {
+			t.Fatalf("%q: %v", test.source, err)
+		}
+		env, err := NewEnvironments(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		warnings, err := newResolver(env).resolveProgram(program)
+		var errors []string
+		if err != nil {
+			errors = spans(test.source, []*diag.CustomError{err})
+		}
+		if !reflect.DeepEqual(errors, test.errors) {
+			t.Errorf("%q: expected the errors %q, got %q", test.source, test.errors, errors)
+		}
+		if got := spans(test.source, warnings); !reflect.DeepEqual(got, test.warnings) {
+			t.Errorf("%q: expected the warnings %q, got %q", test.source, test.warnings, got)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package runtime
+
+import (
+	"aolbrich/lexer/ast"
+	"aolbrich/lexer/diag"
+)
+
+func (i *Interpreter) formatError(e *diag.CustomError, node ast.Stmter) *diag.CustomError {
+	return e.AddSpan(node.Pos(), node.End())
+}
+
+func (i *Interpreter) evalProgram(program *ast.Program, env *Environments) (RuntimeVal, *diag.CustomError) {
+	var lastEvaulatedValue RuntimeVal
+	lastEvaulatedValue = makeNull()
+
+	for _, statements := range program.Body {
+		evaluated, err := i.evaluate(statements, env)
+		if err != nil {
+			return nil, i.formatError(err, program)
+		}
+
+		lastEvaulatedValue = evaluated
+	}
+
+	return lastEvaulatedValue, nil
+}
+
+func (i *Interpreter) evalVarDeclaration(declaration *ast.VariableDeclaration, env *Environments) (taggedVal, *diag.CustomError) {
+	if declaration.Value == nil {
+		value, err := env.declareVarAt(declaration.Identifier, declaration.Slot, makeNull(), declaration.Constant)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, declaration)
+		}
+		return tag(value), nil
+	}
+
+	if declaration.Slot != nil && declaration.Pattern == nil {
+		value, err := i.evaluateTagged(declaration.Value, env)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, declaration)
+		}
+
+		err = env.declareSlot(declaration.Identifier, declaration.Slot.Index, value)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, declaration)
+		}
+		return value, nil
+	}
+
+	value, err := i.evaluate(declaration.Value, env)
+	if err != nil {
+		return taggedVal{}, i.formatError(err, declaration)
+	}
+
+	if declaration.Pattern != nil {
+		err = i.declarePattern(declaration.Pattern, value, env, declaration.Constant)
+		if err != nil {
+			return taggedVal{}, i.formatError(err, declaration)
+		}
+		return tag(value), nil
+	}
+
+	value, err = env.declareVarAt(declaration.Identifier, declaration.Slot, value, declaration.Constant)
+	if err != nil {
+		return taggedVal{}, i.formatError(err, declaration)
+	}
+
+	return tag(value), nil
+}
+
+func (i *Interpreter) evalConditionDeclaration(cnd *ast.ConditionDeclaration, env *Environments) (RuntimeVal, *diag.CustomError) {
+	lhs, err := i.evaluateTagged(cnd.Left, env)
+	if err != nil {
+		return nil, i.formatError(err, cnd)
+	}
+	rhs, err := i.evaluateTagged(cnd.Right, env)
+	if err != nil {
+		return nil, i.formatError(err, cnd)
+	}
+
+	result, err := i.compareTagged(lhs, rhs, cnd.Operator)
+	if err != nil {
+		return nil, i.formatError(err, cnd)
+	}
+
+	return result, nil
+}
+
+// compareOp compares numbers or strings, or combines booleans with & and |,
+// the result is null for operands of other types.
+func (i *Interpreter) compareOp(lhs RuntimeVal, rhs RuntimeVal, operator string) (RuntimeVal, *diag.CustomError) {
+	return i.compareTagged(tag(lhs), tag(rhs), operator)
+}
+
+// compareTagged is compareOp on tagged values, its result is a bool or
+// null which are shared.
+func (i *Interpreter) compareTagged(lhs taggedVal, rhs taggedVal, operator string) (RuntimeVal, *diag.CustomError) {
+	if lhs.tag == tagInt && rhs.tag == tagInt {
+		return i.evalIntegerConditionExpr(IntVal{Value: lhs.int()}, IntVal{Value: rhs.int()}, operator)
+	}
+
+	if lhs.isNumber() && rhs.isNumber() {
+		return i.evalNumericConditionExpr(NumberVal{Value: lhs.float()}, NumberVal{Value: rhs.float()}, operator)
+	}
+
+	switch l := lhs.ref.(type) {
+	case *StringVal:
+		if r, ok := rhs.ref.(*StringVal); ok {
+			return i.evalStringConditionExpr(*l, *r, operator)
+		}
+	case *BoolVal:
+		if r, ok := rhs.ref.(*BoolVal); ok {
+			return i.evalBoolConditionExpr(*l, *r, operator)
+		}
+	}
+
+	return makeNull(), nil
+}
+
+func (i *Interpreter) evalFunctionDeclaration(declaration *ast.FunctionDeclaration, env *Environments) (RuntimeVal, *diag.CustomError) {
+	fn := &FnValue{
+		Type:           ValueFunction,
+		name:           declaration.Name,
+		declarationEnv: env,
+		paramaters:     declaration.Parameters,
+		body:           declaration.Body,
+		locals:         declaration.Locals,
+	}
+
+	return env.declareVarAt(declaration.Name, declaration.Slot, fn, true)
+}
@@ -0,0 +1,43 @@
+package runtime
+
+import "testing"
+
+func TestStringsCountCharacters(t *testing.T) {
+	tests := []struct {
+		source string
+		result string
+	}{
+		{`len("héllo")`, "5"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[4]`, "o"},
+		{`substr("wörld", 1, 3)`, "ör"},
+		{`substr("wörld", 3, 9)`, "null"},
+		{"let s = \"añb\";\nlet indexes = [];\nfor (let i in s) {\n    push(indexes, i)\n}\nindexes", "[0, 1, 2]"},
+		{"let s = \"añb\";\nlet chars = \"\";\nfor (let i in s) {\n    chars = chars + s[i]\n}\nchars", "añb"},
+		{"import \"std/strings\";\nstrings.indexOf(\"é-ü\", \"ü\")", "2"},
+	}
+
+	for _, engine := range engines {
+		for _, optimize := range []bool{false, true} {
+			for _, test := range tests {
+				result, err := runSource(t, engine, optimize, test.source)
+				if err != nil {
+					t.Errorf("%s optimize=%v %q: %v", engine, optimize, test.source, err)
+					continue
+				}
+				if got := displayValue(result); got != test.result {
+					t.Errorf("%s optimize=%v %q: expected %s, got %s", engine, optimize, test.source, test.result, got)
+				}
+			}
+		}
+	}
+}
+
+func TestStringIndexOutOfRange(t *testing.T) {
+	for _, engine := range engines {
+		_, err := runSource(t, engine, false, `"héllo"[5]`)
+		if err == nil || err.Message != "Index 5 out of range, length is 5" {
+			t.Errorf("%s: expected an out of range error, got %v", engine, err)
+		}
+	}
+}
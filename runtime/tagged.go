@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"math"
+
+	"aolbrich/lexer/diag"
+)
+
+// taggedVal is a value as the engines hold it through the operators, on
+// the stack of the VM and in the slots of local variables. Integers and
+// floats are kept inline in bits so the operators make them without an
+// allocation, they are boxed into a RuntimeVal once they leave for an
+// array, an object, a variable looked up by name or a native. A boxed
+// number keeps its box in ref, boxing it again is free. The other values
+// are in ref, the zero taggedVal is an empty slot.
+type taggedVal struct {
+	tag  valueTag
+	bits uint64
+	ref  RuntimeVal
+}
+
+type valueTag uint8
+
+const (
+	tagRef valueTag = iota
+	tagInt
+	tagFloat
+)
+
+func inlineInt(n int64) taggedVal {
+	return taggedVal{tag: tagInt, bits: uint64(n)}
+}
+
+func inlineFloat(n float64) taggedVal {
+	return taggedVal{tag: tagFloat, bits: math.Float64bits(n)}
+}
+
+// tag wraps a value, numbers are read into bits and keep their box.
+func tag(value RuntimeVal) taggedVal {
+	switch n := value.(type) {
+	case *IntVal:
+		return taggedVal{tag: tagInt, bits: uint64(n.Value), ref: value}
+	case *NumberVal:
+		return taggedVal{tag: tagFloat, bits: math.Float64bits(n.Value), ref: value}
+	}
+
+	return taggedVal{ref: value}
+}
+
+// box returns the value as a RuntimeVal, an inline number is allocated
+// once and kept, nil for an empty slot.
+func (v *taggedVal) box() RuntimeVal {
+	if v.ref == nil {
+		switch v.tag {
+		case tagInt:
+			v.ref = makeInteger(v.int())
+		case tagFloat:
+			v.ref = makeNumber(v.float())
+		}
+	}
+
+	return v.ref
+}
+
+// boxed returns the value of a tagged evaluation as a RuntimeVal.
+func boxed(value taggedVal, err *diag.CustomError) (RuntimeVal, *diag.CustomError) {
+	if err != nil {
+		return nil, err
+	}
+
+	return value.box(), nil
+}
+
+func (v taggedVal) int() int64 {
+	return int64(v.bits)
+}
+
+// float returns a number as a float, integers are promoted.
+func (v taggedVal) float() float64 {
+	if v.tag == tagInt {
+		return float64(v.int())
+	}
+
+	return math.Float64frombits(v.bits)
+}
+
+func (v taggedVal) isNumber() bool {
+	return v.tag != tagRef
+}
+
+func (v taggedVal) empty() bool {
+	return v.tag == tagRef && v.ref == nil
+}
@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"testing"
+
+	"aolbrich/lexer/diag"
+	"aolbrich/lexer/parser"
+)
+
+var engines = []Engine{EngineTree, EngineVM}
+
+// runSource runs a script in a new root environment on the engine, once
+// optimized when optimize is set.
+func runSource(t *testing.T, engine Engine, optimize bool, source string) (RuntimeVal, *diag.CustomError) {
+	t.Helper()
+
+	env, err := NewEnvironments(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := parser.NewParser().ProduceAST(source)
+	if err != nil {
+		return nil, err
+	}
+
+	i := NewInterpreter()
+	i.Engine = engine
+	i.Optimize = optimize
+	if err := i.CheckProgram("", program, env); err != nil {
+		return nil, err
+	}
+	i.OptimizeProgram(program)
+
+	return i.Run(program, env)
+}
@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"fmt"
+
+	"aolbrich/lexer/ast"
+)
+
+// arithmeticType is the result of + - * ** % ~/ on two numbers, integers
+// stay integers and any float makes the result a float.
+func arithmeticType(left ast.StaticType, right ast.StaticType) ast.StaticType {
+	switch {
+	case left == ast.TypeInt && right == ast.TypeInt:
+		return ast.TypeInt
+	case left == ast.TypeFloat || right == ast.TypeFloat:
+		return ast.TypeFloat
+	}
+
+	return ast.TypeNumber
+}
+
+// NativeSignature is the static type of a native function, params are the
+// types of its arguments of which the last optional ones may be left out.
+// A variadic native takes any number of arguments past params, they are not
+// checked.
+type NativeSignature struct {
+	params   []ast.StaticType
+	optional int
+	variadic bool
+	result   ast.StaticType
+}
+
+// arity describes the number of arguments a native takes.
+func (s NativeSignature) arity() string {
+	required := len(s.params) - s.optional
+	switch {
+	case s.variadic:
+		return fmt.Sprintf("at least %d %s", required, plural(required, "argument"))
+	case s.optional > 0:
+		return fmt.Sprintf("%d to %d arguments", required, len(s.params))
+	}
+
+	return fmt.Sprintf("%d %s", required, plural(required, "argument"))
+}
+
+// accepts tells whether a call may pass count arguments.
+func (s NativeSignature) accepts(count int) bool {
+	if count < len(s.params)-s.optional {
+		return false
+	}
+
+	return s.variadic || count <= len(s.params)
+}
+
+func plural(count int, word string) string {
+	if count == 1 {
+		return word
+	}
+
+	return word + "s"
+}
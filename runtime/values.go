@@ -0,0 +1,297 @@
+package runtime
+
+import (
+	"math"
+
+	"aolbrich/lexer/ast"
+)
+
+type ValueType int
+
+const (
+	ValueTypeNull ValueType = iota
+	ValueTypeNumber
+	ValueTypeInteger
+	ValueTypeString
+	ValueBoolean
+	ValueObject
+	ValueArray
+	ValueRange
+	ValueNativeFunction
+	ValueFunction
+	ValueBreak
+	ValueContinue
+)
+
+type RuntimeVal interface {
+}
+
+type NullVal struct {
+	Type  ValueType
+	Value string
+}
+
+type NumberVal struct {
+	Type  ValueType
+	Value float64
+}
+
+type IntVal struct {
+	Type  ValueType
+	Value int64
+}
+
+type StringVal struct {
+	Type  ValueType
+	Value string
+}
+
+type BoolVal struct {
+	Type  ValueType
+	Value bool
+}
+
+type BreakVal struct {
+	Type ValueType
+}
+
+type ContinueVal struct {
+	Type ValueType
+}
+
+type ObjectVal struct {
+	Type       ValueType
+	properties map[mapKey]RuntimeVal
+	keys       []mapKey
+}
+
+type ArrayVal struct {
+	Type     ValueType
+	elements []RuntimeVal
+}
+
+// RangeVal is produced by range(), numbers are generated while iterating
+// so large ranges do not allocate.
+type RangeVal struct {
+	Type  ValueType
+	start int64
+	end   int64
+	step  int64
+}
+
+type NativeFnValue struct {
+	Type ValueType
+	call FunctionCall
+	// hostCall is called instead of call for the Go functions registered by
+	// the host, their errors fail the script
+	hostCall hostCall
+	// contextCall is called instead of call for the natives waiting on the
+	// world outside, with the context of the run
+	contextCall contextCall
+	// guard checks the call is permitted before it runs, nil for natives
+	// needing no capability
+	guard nativeGuard
+}
+
+type FnValue struct {
+	Type           ValueType
+	name           string
+	paramaters     []ast.Stmter
+	declarationEnv *Environments
+	body           []ast.Stmter
+	locals         int
+	// compiled is set for functions compiled to bytecode, they have no body
+	compiled *FunctionProto
+}
+
+// Values are never modified once made, so the ones without state are
+// shared instead of allocated by every operation: true, false, null, the
+// break and continue markers and the integers from smallIntMin up to
+// smallIntMax, the usual counters, indexes and remainders.
+const (
+	smallIntMin = -256
+	smallIntMax = 1024
+)
+
+var (
+	trueVal     = &BoolVal{Type: ValueBoolean, Value: true}
+	falseVal    = &BoolVal{Type: ValueBoolean, Value: false}
+	nullVal     = &NullVal{Type: ValueTypeNull, Value: "null"}
+	breakVal    = &BreakVal{Type: ValueBreak}
+	continueVal = &ContinueVal{Type: ValueContinue}
+	smallInts   = makeSmallInts()
+)
+
+func makeSmallInts() []IntVal {
+	ints := make([]IntVal, smallIntMax-smallIntMin)
+	for index := range ints {
+		ints[index] = IntVal{Type: ValueTypeInteger, Value: int64(index + smallIntMin)}
+	}
+
+	return ints
+}
+
+func makeNumber(n float64) *NumberVal {
+	return &NumberVal{Type: ValueTypeNumber, Value: n}
+}
+
+func makeInteger(n int64) *IntVal {
+	if n >= smallIntMin && n < smallIntMax {
+		return &smallInts[n-smallIntMin]
+	}
+
+	return &IntVal{Type: ValueTypeInteger, Value: n}
+}
+
+func makeString(s string) *StringVal {
+	return &StringVal{Type: ValueTypeString, Value: s}
+}
+
+func makeNull() *NullVal {
+	return nullVal
+}
+
+func makeBool(v bool) *BoolVal {
+	if v {
+		return trueVal
+	}
+
+	return falseVal
+}
+
+func makeBreak() *BreakVal {
+	return breakVal
+}
+
+func makeContinue() *ContinueVal {
+	return continueVal
+}
+
+func makeRange(start, end, step int64) *RangeVal {
+	return &RangeVal{Type: ValueRange, start: start, end: end, step: step}
+}
+
+func (r *RangeVal) contains(n int64) bool {
+	if r.step > 0 {
+		return n >= r.start && n < r.end
+	}
+
+	return n <= r.start && n > r.end
+}
+
+// isLast reports if stepping further from n would overflow int64.
+func (r *RangeVal) isLast(n int64) bool {
+	if r.step > 0 {
+		return n > math.MaxInt64-r.step
+	}
+
+	return n < math.MinInt64-r.step
+}
+
+func makeNativeFn(call FunctionCall) *NativeFnValue {
+	return &NativeFnValue{
+		Type: ValueNativeFunction,
+		call: call,
+	}
+}
+
+func makeGuardedNativeFn(call FunctionCall, guard nativeGuard) *NativeFnValue {
+	return &NativeFnValue{
+		Type:  ValueNativeFunction,
+		call:  call,
+		guard: guard,
+	}
+}
+
+func makeContextNativeFn(call contextCall, guard nativeGuard) *NativeFnValue {
+	return &NativeFnValue{
+		Type:        ValueNativeFunction,
+		contextCall: call,
+		guard:       guard,
+	}
+}
+
+// toNumberVal returns the value as a float number, integers are promoted.
+func toNumberVal(v RuntimeVal) (*NumberVal, bool) {
+	switch n := v.(type) {
+	case *NumberVal:
+		return n, true
+	case *IntVal:
+		return makeNumber(float64(n.Value)), true
+	}
+
+	return nil, false
+}
+
+// toFloat returns the value of a number as a float without allocating,
+// integers are promoted.
+func toFloat(v RuntimeVal) (float64, bool) {
+	switch n := v.(type) {
+	case *NumberVal:
+		return n.Value, true
+	case *IntVal:
+		return float64(n.Value), true
+	}
+
+	return 0, false
+}
+
+// toInteger returns the value as int64 if it is an integer or a float with
+// an integer value.
+func toInteger(v RuntimeVal) (int64, bool) {
+	switch n := v.(type) {
+	case *IntVal:
+		return n.Value, true
+	case *NumberVal:
+		if n.Value == math.Trunc(n.Value) && n.Value >= math.MinInt64 && n.Value < math.MaxInt64 {
+			return int64(n.Value), true
+		}
+	}
+
+	return 0, false
+}
+
+func isNumber(v RuntimeVal) bool {
+	switch v.(type) {
+	case *NumberVal, *IntVal:
+		return true
+	}
+
+	return false
+}
+
+// numbersEqual compares two numbers, integers are compared exactly.
+func numbersEqual(a, b RuntimeVal) bool {
+	ai, okA := a.(*IntVal)
+	bi, okB := b.(*IntVal)
+	if okA && okB {
+		return ai.Value == bi.Value
+	}
+
+	af, okA := toFloat(a)
+	bf, okB := toFloat(b)
+
+	return okA && okB && af == bf
+}
+
+// valuesEqual compares scalars by value, objects, arrays and functions by
+// identity, values of different types are never equal.
+func valuesEqual(a, b RuntimeVal) bool {
+	if isNumber(a) && isNumber(b) {
+		return numbersEqual(a, b)
+	}
+
+	switch av := a.(type) {
+	case *StringVal:
+		bv, ok := b.(*StringVal)
+		return ok && av.Value == bv.Value
+	case *BoolVal:
+		bv, ok := b.(*BoolVal)
+		return ok && av.Value == bv.Value
+	case *NullVal:
+		_, ok := b.(*NullVal)
+		return ok
+	}
+
+	return a == b
+}
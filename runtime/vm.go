@@ -0,0 +1,471 @@
+package runtime
+
+import (
+	"fmt"
+
+	"aolbrich/lexer/diag"
+)
+
+// VM runs the bytecode of the compiler on a value stack. Variables live in
+// environments like in the tree engine, locals in the slots placed by the
+// resolver and the others by name, so closures keep the environment they
+// were created in and natives get the environment of the call. Values on
+// the stack are tagged, numbers stay inline until they leave it.
+type VM struct {
+	interpreter *Interpreter
+	stack       []taggedVal
+	frames      []*frame
+}
+
+// frame is the program or a function call being run, base is where its
+// values start on the stack and call where the function was called.
+type frame struct {
+	chunk *Chunk
+	ip    int
+	env   *Environments
+	base  int
+	args  []RuntimeVal
+	fn    *FnValue
+	call  int
+	// called is the frame of the function first called when this one runs
+	// in its place through tail calls
+	called *frame
+}
+
+func newVM(i *Interpreter) *VM {
+	return &VM{interpreter: i}
+}
+
+// run runs a compiled program in env, the module root or the prompt scope.
+func (vm *VM) run(chunk *Chunk, env *Environments) (RuntimeVal, *diag.CustomError) {
+	vm.frames = append(vm.frames, &frame{chunk: chunk, env: env})
+
+	return vm.execute()
+}
+
+// call runs a compiled function, pos and end are the span of the call.
+func (vm *VM) call(fn *FnValue, args []RuntimeVal, pos int, end int) (RuntimeVal, *diag.CustomError) {
+	if err := vm.interpreter.enterCall(); err != nil {
+		return nil, err.AddSpan(pos, end)
+	}
+	vm.frames = append(vm.frames, vm.newFrame(fn, args, pos))
+
+	return vm.execute()
+}
+
+func (vm *VM) newFrame(fn *FnValue, args []RuntimeVal, pos int) *frame {
+	return &frame{
+		chunk: fn.compiled.chunk,
+		env:   newLocalEnvironments(fn.declarationEnv, fn.locals),
+		base:  len(vm.stack),
+		args:  args,
+		fn:    fn,
+		call:  pos,
+	}
+}
+
+func (f *frame) read() int {
+	operand := f.chunk.operand(f.ip)
+	f.ip += 2
+
+	return operand
+}
+
+func (f *frame) name() string {
+	return f.chunk.constants[f.read()].(*StringVal).Value
+}
+
+func (vm *VM) push(value RuntimeVal) {
+	vm.stack = append(vm.stack, tag(value))
+}
+
+func (vm *VM) pushTagged(value taggedVal) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() RuntimeVal {
+	value := vm.popTagged()
+
+	return value.box()
+}
+
+func (vm *VM) popTagged() taggedVal {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+
+	return value
+}
+
+// peek boxes the top of the stack in place, so it is boxed once.
+func (vm *VM) peek() RuntimeVal {
+	return vm.stack[len(vm.stack)-1].box()
+}
+
+// top returns the top of the stack for a type check without boxing it,
+// an inline number is nil.
+func (vm *VM) top() RuntimeVal {
+	return vm.stack[len(vm.stack)-1].ref
+}
+
+// boxAll returns the values of the stack as RuntimeVals.
+func boxAll(values []taggedVal) []RuntimeVal {
+	boxed := make([]RuntimeVal, len(values))
+	for index := range values {
+		boxed[index] = values[index].box()
+	}
+
+	return boxed
+}
+
+func (vm *VM) execute() (RuntimeVal, *diag.CustomError) {
+	i := vm.interpreter
+	f := vm.frames[len(vm.frames)-1]
+
+	for {
+		start := f.ip
+		op := Opcode(f.chunk.code[f.ip])
+		f.ip++
+
+		err := i.step()
+		if err != nil {
+			return nil, vm.unwind(err, start)
+		}
+
+		switch op {
+		case OpConstant:
+			vm.push(f.chunk.constants[f.read()])
+		case OpNull:
+			vm.push(makeNull())
+		case OpPop:
+			vm.popTagged()
+		case OpPopN:
+			vm.stack = vm.stack[:len(vm.stack)-f.read()]
+		case OpPopUnder:
+			count := f.read()
+			top := vm.popTagged()
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.pushTagged(top)
+		case OpDup:
+			vm.pushTagged(vm.stack[len(vm.stack)-1])
+		case OpGetStack:
+			vm.pushTagged(vm.stack[f.base+f.read()])
+		case OpSetStack:
+			offset := f.read()
+			vm.stack[f.base+offset] = vm.popTagged()
+
+		case OpGetLocal:
+			env := f.env.ancestor(f.read())
+			value := env.slots[f.read()]
+			name := f.name()
+			if value.empty() {
+				err = diag.NewCustomError(fmt.Sprintf("Variable %s could not be resolved", name))
+				break
+			}
+			vm.pushTagged(value)
+		case OpSetLocal:
+			env := f.env.ancestor(f.read())
+			slot := f.read()
+			name := f.name()
+			if env.slots[slot].empty() {
+				err = diag.NewCustomError(fmt.Sprintf("Variable %s could not be resolved", name))
+				break
+			}
+			env.slots[slot] = vm.stack[len(vm.stack)-1]
+		case OpDeclareLocal:
+			slot := f.read()
+			name := f.name()
+			if !f.env.slots[slot].empty() {
+				err = diag.NewCustomError(fmt.Sprintf("Variable %s already exists", name))
+				break
+			}
+			f.env.slots[slot] = vm.stack[len(vm.stack)-1]
+		case OpGetName:
+			var value RuntimeVal
+			value, err = f.env.lookupVar(f.name())
+			if err == nil {
+				vm.push(value)
+			}
+		case OpSetName:
+			_, err = f.env.assignVar(f.name(), vm.peek())
+		case OpDeclareName:
+			name := f.name()
+			_, err = f.env.declareVar(name, vm.peek(), f.read() == 1)
+
+		case OpBinary:
+			operator := f.name()
+			rhs := vm.popTagged()
+			lhs := vm.popTagged()
+			var result taggedVal
+			result, err = i.binaryTagged(lhs, rhs, operator)
+			vm.pushTagged(result)
+		case OpCompare:
+			operator := f.name()
+			rhs := vm.popTagged()
+			lhs := vm.popTagged()
+			var result RuntimeVal
+			result, err = i.compareTagged(lhs, rhs, operator)
+			vm.push(result)
+		case OpUnary:
+			var result RuntimeVal
+			result, err = i.unaryOp(vm.pop(), f.name())
+			vm.push(result)
+		case OpEqual:
+			rhs := vm.popTagged()
+			lhs := vm.popTagged()
+			if lhs.tag == tagInt && rhs.tag == tagInt {
+				vm.push(makeBool(lhs.int() == rhs.int()))
+			} else if lhs.isNumber() && rhs.isNumber() {
+				vm.push(makeBool(lhs.float() == rhs.float()))
+			} else {
+				vm.push(makeBool(valuesEqual(lhs.box(), rhs.box())))
+			}
+
+		case OpObject:
+			vm.push(makeObject())
+		case OpSetProperty:
+			value := vm.pop()
+			key := vm.pop()
+			err = i.setProperty(vm.peek().(*ObjectVal), key, value)
+		case OpArray:
+			count := f.read()
+			elements := boxAll(vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(makeArray(elements))
+			err = i.allocate(count * allocationSlot)
+		case OpGetMember:
+			key := vm.pop()
+			object := vm.pop()
+			var value RuntimeVal
+			value, err = i.getMember(object, key)
+			vm.push(value)
+		case OpSetMember:
+			value := vm.pop()
+			key := vm.pop()
+			object := vm.pop()
+			err = i.setMember(object, key, value)
+			vm.push(value)
+
+		case OpClosure:
+			proto := f.chunk.functions[f.read()]
+			vm.push(&FnValue{Type: ValueFunction, name: proto.name, declarationEnv: f.env, locals: proto.locals, compiled: proto})
+		case OpArg:
+			index := f.read()
+			if index < len(f.args) {
+				vm.push(f.args[index])
+			} else {
+				vm.push(makeNull())
+			}
+		case OpCall, OpTailCall:
+			count := f.read()
+			callee := vm.pop()
+			args := boxAll(vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+
+			switch fn := callee.(type) {
+			case *NativeFnValue:
+				var result RuntimeVal
+				result, err = i.callNative(fn, args, f.env)
+				vm.push(result)
+			case *FnValue:
+				pos, end := f.chunk.spanAt(start)
+				if fn.compiled == nil {
+					var result RuntimeVal
+					result, err = i.callFunction(fn, args, pos, end)
+					vm.push(result)
+					break
+				}
+
+				if op == OpTailCall {
+					// The values of the function are dropped with its frame
+					called := f.called
+					if called == nil {
+						called = f
+					}
+					vm.stack = vm.stack[:f.base]
+					f = vm.newFrame(fn, args, pos)
+					f.called = called
+					vm.frames[len(vm.frames)-1] = f
+					break
+				}
+
+				if err = i.enterCall(); err != nil {
+					break
+				}
+				f = vm.newFrame(fn, args, pos)
+				vm.frames = append(vm.frames, f)
+			default:
+				err = diag.NewCustomError("cannot call value which is not a function")
+			}
+		case OpReturn:
+			result := vm.popTagged()
+			vm.stack = vm.stack[:f.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if f.fn != nil {
+				i.leaveCall()
+			}
+			if len(vm.frames) == 0 {
+				return result.box(), nil
+			}
+			f = vm.frames[len(vm.frames)-1]
+			vm.pushTagged(result)
+
+		case OpJump:
+			f.ip = f.read()
+		case OpJumpIfFalse:
+			target := f.read()
+			var condition bool
+			condition, err = toCondition(vm.pop())
+			if err != nil {
+				break
+			}
+			if !condition {
+				f.ip = target
+			}
+		case OpJumpIfTrue:
+			target := f.read()
+			if isTrue(vm.pop()) {
+				f.ip = target
+			}
+		case OpJumpIfNotTrue:
+			target := f.read()
+			if !isTrue(vm.pop()) {
+				f.ip = target
+			}
+		case OpJumpIfNotNull:
+			target := f.read()
+			if _, null := vm.top().(*NullVal); !null {
+				f.ip = target
+			}
+		case OpJumpIfBreak:
+			target := f.read()
+			if _, ok := vm.top().(*BreakVal); ok {
+				f.ip = target
+			}
+		case OpJumpIfContinue:
+			target := f.read()
+			if _, ok := vm.top().(*ContinueVal); ok {
+				f.ip = target
+			}
+
+		case OpBreak:
+			vm.push(makeBreak())
+		case OpContinue:
+			vm.push(makeContinue())
+		case OpPushScope:
+			f.env = newLocalEnvironments(f.env, f.read())
+		case OpPopScope:
+			f.env = f.env.parent
+		case OpIterStart:
+			keys := f.read() == 1
+			var items *iterator
+			items, err = newIterator(vm.pop(), keys)
+			vm.push(items)
+		case OpIterNext:
+			items := vm.stack[f.base+f.read()].ref.(*iterator)
+			target := f.read()
+			if item, ok := items.next(); ok {
+				vm.push(item)
+			} else {
+				f.ip = target
+			}
+
+		case OpIsObject:
+			_, ok := vm.peek().(*ObjectVal)
+			vm.push(makeBool(ok))
+		case OpArrayFits:
+			length := f.read()
+			array, ok := vm.peek().(*ArrayVal)
+			vm.push(makeBool(ok && len(array.elements) <= length))
+		case OpCheckObject:
+			if _, ok := vm.peek().(*ObjectVal); !ok {
+				err = diag.NewCustomError(fmt.Sprintf("Cannot destructure %s as object", displayValue(vm.peek())))
+			}
+		case OpCheckArray:
+			if _, ok := vm.peek().(*ArrayVal); !ok {
+				err = diag.NewCustomError(fmt.Sprintf("Cannot destructure %s as array", displayValue(vm.peek())))
+			}
+		case OpPatternProperty:
+			property, exist := vm.peek().(*ObjectVal).getProperty(f.name())
+			if !exist {
+				property = makeNull()
+			}
+			vm.push(property)
+			vm.push(makeBool(exist))
+		case OpPatternIndex:
+			index := f.read()
+			array := vm.peek().(*ArrayVal)
+			exist := index < len(array.elements)
+			var element RuntimeVal = makeNull()
+			if exist {
+				element = array.elements[index]
+			}
+			vm.push(element)
+			vm.push(makeBool(exist))
+		case OpMatchFail:
+			err = diag.NewCustomError(fmt.Sprintf("Non-exhaustive match, no arm matches %s", displayNested(vm.peek())))
+
+		case OpImport:
+			var result RuntimeVal
+			result, err = i.evalImportDeclaration(f.chunk.imports[f.read()], f.env)
+			vm.push(result)
+		case OpExport:
+			names := f.chunk.constants[f.read()].(*ArrayVal)
+			if f.env.module == nil {
+				err = diag.NewCustomError("Export is allowed at the top level of a module only")
+				break
+			}
+			for _, name := range names.elements {
+				f.env.module.export(name.(*StringVal).Value)
+			}
+		case OpFail:
+			err = diag.NewCustomError(f.name())
+
+		default:
+			err = diag.NewCustomError(fmt.Sprintf("Unknown instruction %s", op))
+		}
+
+		if err != nil {
+			return nil, vm.unwind(err, start)
+		}
+	}
+}
+
+// unwind adds the span of the failed instruction and the function calls
+// it went through to the error, like the tree engine does on its way out.
+func (vm *VM) unwind(err *diag.CustomError, start int) *diag.CustomError {
+	offset := start
+	for index := len(vm.frames) - 1; index >= 0; index-- {
+		f := vm.frames[index]
+		err.AddSpan(f.chunk.spanAt(offset))
+
+		if f.fn != nil {
+			vm.interpreter.leaveCall()
+
+			// The body may belong to another module than the caller
+			if module := f.fn.declarationEnv.root().module; module != nil {
+				err.InFile(module.file)
+			}
+			err.AddFrame(f.fn.name, f.call)
+			if f.called != nil {
+				addTailCallFrame(err, f.called.fn, f.fn, f.called.call)
+			}
+		}
+
+		if index > 0 {
+			// Frames below are at their call instruction
+			caller := vm.frames[index-1]
+			offset = caller.ip - 3
+		}
+	}
+
+	vm.frames = nil
+	vm.stack = nil
+
+	return err
+}
+
+func isTrue(value RuntimeVal) bool {
+	b, ok := value.(*BoolVal)
+
+	return ok && b.Value
+}